@@ -0,0 +1,167 @@
+//-----------------------------------------------------------------------------
+/*
+
+Session Authentication
+
+An optional authenticator is run before the first prompt. The CLI only
+starts accepting commands once the authenticator has returned an identity.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"errors"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+
+// ErrAuth is the reason the CLI stopped if authentication failed.
+var ErrAuth = errors.New("authentication failed")
+
+// Authenticator checks the credentials of the user and returns their identity.
+type Authenticator func(c *CLI) (string, error)
+
+// default number of authentication attempts
+const authRetries = 3
+
+type auth struct {
+	fn       Authenticator           // authentication function
+	retries  int                     // number of attempts allowed
+	lockout  func(c *CLI, err error) // called when all attempts have failed
+	identity string                  // identity of the authenticated user
+	unlock   func(c *CLI) error      // unlock a locked session
+}
+
+//-----------------------------------------------------------------------------
+
+// PasswordAuthenticator returns an authenticator that prompts for a user name
+// and password and checks them with the check function.
+func PasswordAuthenticator(check func(user, password string) bool) Authenticator {
+	return func(c *CLI) (string, error) {
+		user, err := c.leafRead("login: ", "")
+		if err != nil {
+			return "", err
+		}
+		user = strings.TrimSpace(user)
+		password, err := c.ReadSecret("password: ", false)
+		if err != nil {
+			return "", err
+		}
+		if !check(user, password) {
+			return "", errors.New("login incorrect")
+		}
+		return user, nil
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// SetAuthenticator sets the function used to authenticate the user.
+func (c *CLI) SetAuthenticator(fn Authenticator) {
+	c.auth.fn = fn
+}
+
+// SetAuthRetries sets the number of authentication attempts allowed.
+func (c *CLI) SetAuthRetries(n int) {
+	c.auth.retries = n
+}
+
+// SetLockout sets a function called when all authentication attempts have failed.
+func (c *CLI) SetLockout(fn func(c *CLI, err error)) {
+	c.auth.lockout = fn
+}
+
+// SetUnlock sets the function used to unlock a locked session.
+// By default the authenticator is used.
+func (c *CLI) SetUnlock(fn func(c *CLI) error) {
+	c.auth.unlock = fn
+}
+
+// Identity returns the identity of the authenticated user.
+func (c *CLI) Identity() string {
+	return c.auth.identity
+}
+
+// authenticate runs the authenticator until it passes or the attempts are used up.
+func (c *CLI) authenticate() error {
+	if c.auth.fn == nil {
+		return nil
+	}
+	retries := c.auth.retries
+	if retries <= 0 {
+		retries = authRetries
+	}
+	var err error
+	for i := 0; i < retries; i++ {
+		var id string
+		id, err = c.auth.fn(c)
+		if err == nil {
+			c.auth.identity = id
+			return nil
+		}
+		if err == ErrQuit || err == ErrEOF {
+			return err
+		}
+		c.Put(c.colorString(err.Error(), theme.Error, true) + "\n")
+	}
+	if c.auth.lockout != nil {
+		c.auth.lockout(c, err)
+	}
+	return ErrAuth
+}
+
+//-----------------------------------------------------------------------------
+
+// LockHelp is help for the lock command.
+var LockHelp = []Help{
+	{"<cr>", "lock the session until the user authenticates again"},
+}
+
+// unlockAuth unlocks the session with the authenticator.
+// The user must have the same identity as before the lock.
+func (c *CLI) unlockAuth() error {
+	id, err := c.auth.fn(c)
+	if err != nil {
+		return err
+	}
+	if c.auth.identity != "" && id != c.auth.identity {
+		return errors.New("session is locked by another user")
+	}
+	return nil
+}
+
+// Lock the session. Return when the session has been unlocked.
+// The command line being edited and the history are preserved.
+// Exit the CLI if there's no way to unlock or the user quits.
+func (c *CLI) Lock() {
+	if c.ln.term != nil || isTerminal(stdoutFd) {
+		// clear the screen
+		c.ln.putTerm("\x1b[H\x1b[2J")
+	}
+	c.Put("session locked\n")
+	unlock := c.auth.unlock
+	if unlock == nil && c.auth.fn != nil {
+		unlock = (*CLI).unlockAuth
+	}
+	if unlock == nil {
+		c.running = false
+		return
+	}
+	for {
+		err := unlock(c)
+		if err == nil {
+			return
+		}
+		if err == ErrQuit || err == ErrEOF {
+			c.running = false
+			c.err = err
+			return
+		}
+		c.Put(c.colorString(err.Error(), theme.Error, true) + "\n")
+	}
+}
+
+//-----------------------------------------------------------------------------
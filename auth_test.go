@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_Authenticate(t *testing.T) {
+	c := NewCLI(&testUser{})
+	if c.authenticate() != nil {
+		t.Errorf("FAIL no authenticator should pass")
+	}
+
+	// pass on the second attempt
+	n := 0
+	c.SetAuthenticator(func(c *CLI) (string, error) {
+		n++
+		if n < 2 {
+			return "", errors.New("bad password")
+		}
+		return "admin", nil
+	})
+	if err := c.authenticate(); err != nil || c.Identity() != "admin" {
+		t.Errorf("FAIL expected (admin) != actual (%s, %v)", c.Identity(), err)
+	}
+
+	// always fail
+	locked := false
+	c.SetAuthenticator(func(c *CLI) (string, error) {
+		return "", errors.New("bad password")
+	})
+	c.SetAuthRetries(2)
+	c.SetLockout(func(c *CLI, err error) { locked = true })
+	if err := c.authenticate(); err != ErrAuth || !locked {
+		t.Errorf("FAIL expected (%v) != actual (%v)", ErrAuth, err)
+	}
+}
+
+func Test_Lock(t *testing.T) {
+	c := NewCLI(&testUser{})
+	n := 0
+	c.SetUnlock(func(c *CLI) error {
+		n++
+		if n < 3 {
+			return errors.New("bad password")
+		}
+		return nil
+	})
+	c.Lock()
+	if n != 3 || !c.Running() {
+		t.Errorf("FAIL expected (3) != actual (%d)", n)
+	}
+
+	// the session terminal is cleared
+	term := &testTerm{Reader: strings.NewReader("")}
+	c = NewCLI(&testUser{})
+	c.ln.SetTerminal(term)
+	c.SetUnlock(func(c *CLI) error { return nil })
+	c.Lock()
+	if term.out.String() != "\x1b[H\x1b[2J" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "\x1b[H\x1b[2J", term.out.String())
+	}
+
+	// no way to unlock
+	c = NewCLI(&testUser{})
+	c.Lock()
+	if c.Running() {
+		t.Errorf("FAIL locked cli without unlock is still running")
+	}
+}
+
+func Test_PasswordAuthenticator(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.ln.SetTerminal(&testTerm{Reader: strings.NewReader("ad?min\rsecret\r")})
+	var user, password string
+	auth := PasswordAuthenticator(func(u, p string) bool {
+		user, password = u, p
+		return true
+	})
+	// the help key is a literal character at the login prompt
+	id, err := auth(c)
+	if id != "ad?min" || user != "ad?min" || password != "secret" || err != nil {
+		t.Errorf("FAIL expected (ad?min, secret) != actual (%q, %q, %v)", user, password, err)
+	}
+}
@@ -0,0 +1,123 @@
+//-----------------------------------------------------------------------------
+/*
+
+Session Capabilities
+
+The color and character set capabilities of the terminal for a CLI session.
+A transport (Eg. telnet or ssh) can set them for each client so the styled
+output degrades per session. The process settings are used by default.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import "strings"
+
+//-----------------------------------------------------------------------------
+
+// Charset is the character set of a terminal.
+type Charset int
+
+// Terminal character sets
+const (
+	CharsetAuto  Charset = iota // from the process locale (LANG, etc.)
+	CharsetASCII                // ascii only
+	CharsetUTF8                 // utf-8 with unicode glyphs
+)
+
+// SetColor sets the use of ANSI colors for the session.
+// ColorAuto uses the process setting (see SetColorMode).
+func (c *CLI) SetColor(m ColorMode) {
+	c.color = m
+	c.ln.color = m
+}
+
+// SetCharset sets the terminal character set for the session.
+// CharsetAuto uses the process locale.
+func (c *CLI) SetCharset(cs Charset) {
+	c.charset = cs
+}
+
+// SetTermType sets the session color capability from a terminal type,
+// Eg. the TERM value sent by a telnet or ssh client.
+func (c *CLI) SetTermType(term string) {
+	if term == "" || unsupported[term] || strings.HasPrefix(term, "vt") {
+		c.SetColor(ColorOff)
+		return
+	}
+	c.SetColor(ColorOn)
+}
+
+// Return true if the session supports ANSI colors.
+func (c *CLI) colorEnabled() bool {
+	switch c.color {
+	case ColorOn:
+		return true
+	case ColorOff:
+		return false
+	}
+	return colorEnabled()
+}
+
+// Return the number of columns for the session. Assume defaultCols if unknown.
+func (c *CLI) columns() int {
+	cols := c.ln.Columns()
+	if cols <= 0 {
+		return defaultCols
+	}
+	return cols
+}
+
+// Return true if the session can display unicode glyphs.
+func (c *CLI) unicode() bool {
+	switch c.charset {
+	case CharsetASCII:
+		return false
+	case CharsetUTF8:
+		return true
+	}
+	return termUnicode()
+}
+
+// Return a string with ANSI color/bold styling (if the session supports it).
+func (c *CLI) colorString(s string, color int, bold bool) string {
+	return styleString(s, color, bold, c.colorEnabled())
+}
+
+// DiffString returns a unified diff of two strings like the DiffString
+// function, with colors if the session supports them.
+func (c *CLI) DiffString(a, b string) string {
+	return diffString(a, b, c.colorEnabled())
+}
+
+// BarChart returns a horizontal bar chart like the BarChart function,
+// scaled to the session width with unicode glyphs if the session supports them.
+func (c *CLI) BarChart(bars []Bar) string {
+	return barChart(bars, c.columns(), c.unicode())
+}
+
+// HexDump returns a hex dump like the HexDump function,
+// sized to the session width.
+func (c *CLI) HexDump(data []byte, baseAddr uint64) string {
+	return hexDump(data, baseAddr, c.columns())
+}
+
+// Sparkline returns a sparkline like the Sparkline function,
+// with unicode glyphs if the session supports them.
+func (c *CLI) Sparkline(values []float64) string {
+	return sparkline(values, c.unicode())
+}
+
+// Return true if the line editor session supports ANSI colors.
+func (l *Linenoise) colorEnabled() bool {
+	switch l.color {
+	case ColorOn:
+		return true
+	case ColorOff:
+		return false
+	}
+	return colorEnabled()
+}
+
+//-----------------------------------------------------------------------------
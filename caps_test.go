@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_SessionCaps(t *testing.T) {
+	tests := []struct {
+		term  string
+		color bool
+	}{
+		{"xterm-256color", true},
+		{"vt100", false},
+		{"dumb", false},
+		{"", false},
+	}
+	for i, v := range tests {
+		c := NewCLI(&testUser{}, WithTermType(v.term))
+		s := c.colorString("x", theme.Error, true)
+		if strings.Contains(s, "\033[") != v.color {
+			t.Errorf("%d: FAIL expected (%v) != actual (%q)", i, v.color, s)
+		}
+		d := c.DiffString("a\n", "b\n")
+		if strings.Contains(d, "\033[") != v.color {
+			t.Errorf("%d: FAIL expected (%v) != actual (%q)", i, v.color, d)
+		}
+	}
+	// the character set selects the glyphs
+	c := NewCLI(&testUser{}, WithCharset(CharsetASCII))
+	if s := c.Sparkline([]float64{0, 1}); s != sparkline([]float64{0, 1}, false) {
+		t.Errorf("FAIL ascii sparkline (%q)", s)
+	}
+	c.SetCharset(CharsetUTF8)
+	if s := c.Sparkline([]float64{0, 1}); s != sparkline([]float64{0, 1}, true) {
+		t.Errorf("FAIL unicode sparkline (%q)", s)
+	}
+	// the line editor follows the session
+	c.SetColor(ColorOff)
+	if c.ln.colorEnabled() {
+		t.Errorf("FAIL line editor color enabled")
+	}
+}
+
+func Test_SessionSize(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.ln.SetColumns(40)
+	c.ln.SetRows(12)
+	data := make([]byte, 32)
+	if s := c.HexDump(data, 0); s != hexDump(data, 0, 40) {
+		t.Errorf("FAIL hexdump not sized to the session (%q)", s)
+	}
+	bars := []Bar{{"a", 1}, {"b", 2}}
+	if s := c.BarChart(bars); s != barChart(bars, 40, c.unicode()) {
+		t.Errorf("FAIL bar chart not sized to the session (%q)", s)
+	}
+	if rows, cols := c.screenSize(); rows != 12 || cols != 40 {
+		t.Errorf("FAIL expected (12, 40) != actual (%d, %d)", rows, cols)
+	}
+	// the size can be set while it's read
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			c.ln.SetColumns(40 + i)
+			c.ln.SetRows(12 + i)
+		}
+		close(done)
+	}()
+	for i := 0; i < 100; i++ {
+		c.screenSize()
+	}
+	<-done
+}
@@ -17,10 +17,14 @@ Implements a CLI with:
 package cli
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mattn/go-runewidth"
 )
@@ -33,6 +37,12 @@ type Help struct {
 	Descr string // description
 }
 
+// Example is an example invocation of a command.
+type Example struct {
+	Cmd   string // command line
+	Descr string // description
+}
+
 // USER is an interface for low-level UI operations.
 // A user provide object with this interface is passed to each leaf function.
 type USER interface {
@@ -43,8 +53,19 @@ type USER interface {
 // {name string, submenu Menu, description string}: reference to submenu
 // {name string, leaf func}: leaf command with generic <cr> help
 // {name string, leaf func, help []Help}: leaf command with specific argument help
+// {name string, leaf func, help []Help, examples []Example}: as above with example invocations
+// An ItemFlags or Timeout value may be added after the leaf func of any leaf form.
 type MenuItem []interface{}
 
+// ItemFlags are optional flags for a leaf menu item.
+type ItemFlags int
+
+// Leaf menu item flags.
+const (
+	NoHistory     ItemFlags = 1 << iota // command lines are never added to history (Eg. passwords)
+	StateChanging                       // the leaf changes state, it calls Apply() before doing so
+)
+
 // Menu is a set of menu items.
 type Menu []MenuItem
 
@@ -63,8 +84,11 @@ var crHelp = []Help{
 
 var generalHelp = []Help{
 	{"?", "display command help - Eg. ?, show ?, s?"},
+	{"\\?", "a literal ? in an argument"},
 	{"<up>", "go backwards in command history"},
 	{"<dn>", "go forwards in command history"},
+	{"<ctrl-r>", "search backwards in command history"},
+	{"<ctrl-_>", "undo the last edit, <ctrl-^> to redo"},
 	{"<tab>", "auto complete commands"},
 	{"* note", "commands can be incomplete - Eg. sh = sho = show"},
 }
@@ -72,7 +96,9 @@ var generalHelp = []Help{
 // HistoryHelp is help for the history command.
 var HistoryHelp = []Help{
 	{"<cr>", "display all history"},
-	{"<index>", "recall history entry <index>"},
+	{"<index>", "recall history entry <index>, -1 is the previous command"},
+	{"delete <index>", "delete history entry <index>"},
+	{"clear", "clear all history"},
 }
 
 //-----------------------------------------------------------------------------
@@ -108,6 +134,20 @@ func UintArg(arg string, limits [2]uint, base int) (uint, error) {
 	return val, nil
 }
 
+// FloatArg converts a number string to a float.
+func FloatArg(arg string, limits [2]float64) (float64, error) {
+	// convert the float
+	val, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, errors.New("invalid argument")
+	}
+	// check the limits
+	if val < limits[0] || val > limits[1] {
+		return 0, errors.New("invalid argument, out of range")
+	}
+	return val, nil
+}
+
 // CheckArgc returns an error if the argument count is not in the valid set.
 func CheckArgc(args []string, valid []int) error {
 	argc := len(args)
@@ -158,21 +198,14 @@ func TableString(
 			}
 		}
 	}
-	// build the row format string
-	fmtCol := make([]string, ncols)
-	for i, n := range csize {
-		fmtCol[i] = fmt.Sprintf("%%-%ds", n)
-	}
-	fmtRow := strings.Join(fmtCol, "")
 	// generate the row strings
 	row := make([]string, nrows)
 	for i, l := range rows {
-		// convert []string to []interface{}
-		x := make([]interface{}, len(l))
+		var sb strings.Builder
 		for j, v := range l {
-			x[j] = v
+			sb.WriteString(PadWidth(v, csize[j]))
 		}
-		row[i] = fmt.Sprintf(fmtRow, x...)
+		row[i] = sb.String()
 	}
 	// return rows and columns
 	return strings.Join(row, "\n")
@@ -180,55 +213,126 @@ func TableString(
 
 //-----------------------------------------------------------------------------
 
-// Return a string that repeats the rune n times.
-func repeat(r rune, n int) string {
-	x := make([]rune, n)
-	for i := range x {
-		x[i] = r
+// ColorMode controls the use of ANSI colors for styled output.
+type ColorMode int
+
+// Color modes
+const (
+	ColorAuto ColorMode = iota // color if the output supports it (default)
+	ColorOn                    // always color
+	ColorOff                   // never color
+)
+
+var colorModeNames = []string{"auto", "on", "off"}
+
+// String returns the name of the color mode.
+func (m ColorMode) String() string {
+	if int(m) < len(colorModeNames) {
+		return colorModeNames[m]
 	}
-	return string(x)
+	return "unknown"
 }
 
-//-----------------------------------------------------------------------------
+var colorMode = ColorAuto
+
+// SetColorMode sets the process default use of ANSI colors for styled output.
+// A session can override it (see CLI.SetColor).
+func SetColorMode(m ColorMode) {
+	colorMode = m
+}
+
+// Return true if the output supports ANSI colors.
+func colorEnabled() bool {
+	switch colorMode {
+	case ColorOn:
+		return true
+	case ColorOff:
+		return false
+	}
+	return os.Getenv("NO_COLOR") == "" && !unsupportedTerm() && isTerminal(stdoutFd)
+}
+
+// Theme sets the ANSI colors used for styled output.
+// A color < 0 disables styling.
+type Theme struct {
+	Error    int // error messages
+	DiffAdd  int // added diff lines
+	DiffDel  int // deleted diff lines
+	DiffHunk int // diff hunk headers
+	Hint     int // argument hints
+}
+
+// DefaultTheme is the default output theme.
+var DefaultTheme = Theme{
+	Error:    31,
+	DiffAdd:  32,
+	DiffDel:  31,
+	DiffHunk: 36,
+	Hint:     35,
+}
+
+var theme = DefaultTheme
+
+// SetTheme sets the output theme.
+func SetTheme(t Theme) {
+	theme = t
+}
+
+// Return a string with ANSI color/bold styling if enabled.
+func styleString(s string, color int, bold, enabled bool) string {
+	if color < 0 || !enabled {
+		return s
+	}
+	return fmt.Sprintf("\033[%d;%d;49m%s\033[0m", btoi(bold), color, s)
+}
 
-// Split a string on whitespace and return the substring indices.
-func splitIndex(s string) [][2]int {
-	// start and end with whitespace
-	ws := true
-	s += " "
-	indices := make([][2]int, 0, 10)
-	var start int
-	for i, c := range s {
-		if !ws && c == ' ' {
-			// non-whitespace to whitespace
-			ws = true
-			indices = append(indices, [2]int{start, i})
-		} else if ws && c != ' ' {
-			// whitespace to non-whitespace
-			start = i
-			ws = false
+// CenterString centers each line of a string within a width.
+func CenterString(s string, width int) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		pad := (width - runewidth.StringWidth(l)) / 2
+		if pad > 0 && len(l) != 0 {
+			lines[i] = repeat(' ', pad) + l
 		}
 	}
-	return indices
+	return strings.Join(lines, "\n")
 }
 
 //-----------------------------------------------------------------------------
 
-// Return the list of line completions.
-func completions(line, cmd string, names []string, minlen int) []string {
-	// if we are completing a complete word then add a space
-	if cmd == "" && line != "" {
-		line += " "
+// TruncateWidth truncates a string to a display width of w terminal columns.
+func TruncateWidth(s string, w int) string {
+	return runewidth.Truncate(s, w, "")
+}
+
+// TruncateEllipsis truncates a string to a display width of w terminal
+// columns, a truncated string ends with "...".
+func TruncateEllipsis(s string, w int) string {
+	return runewidth.Truncate(s, w, "...")
+}
+
+// PadWidth pads a string with spaces to a display width of w terminal columns.
+// Strings at least w columns wide are returned as is.
+func PadWidth(s string, w int) string {
+	return runewidth.FillRight(s, w)
+}
+
+// Return a string that repeats the rune n times.
+func repeat(r rune, n int) string {
+	x := make([]rune, n)
+	for i := range x {
+		x[i] = r
 	}
-	lines := make([]string, len(names))
+	return string(x)
+}
+
+//-----------------------------------------------------------------------------
+
+// Pad line completions to a minimum length.
+// We don't want the cursor to move about unecessarily.
+func padCompletions(lines []string, minlen int) []string {
 	for i := range lines {
-		lines[i] = fmt.Sprintf("%s%s", line, names[i][len(cmd):])
-		// Pad the lines to a minimum length.
-		// We don't want the cursor to move about unecessarily.
-		pad := minlen - runewidth.StringWidth(lines[i])
-		if pad > 0 {
-			lines[i] += repeat(' ', pad)
-		}
+		lines[i] = PadWidth(lines[i], minlen)
 	}
 	return lines
 }
@@ -259,8 +363,8 @@ func (c *CLI) displayError(msg string, cmds []string, idx int) {
 	c.Put(s + "\n")
 }
 
-// display function help
-func (c *CLI) displayFunctionHelp(help []Help) {
+// return the function help string
+func functionHelpString(help []Help) string {
 	s := make([][]string, len(help))
 	for i := range s {
 		pStr := help[i].Parm
@@ -272,11 +376,16 @@ func (c *CLI) displayFunctionHelp(help []Help) {
 		}
 		s[i] = []string{"   ", pStr, dStr}
 	}
-	c.Put(TableString(s, []int{0, 16, 0}, 1) + "\n")
+	return TableString(s, []int{0, 16, 0}, 1) + "\n"
 }
 
-// display help results for a command at a menu level
-func (c *CLI) commandHelp(cmd string, menu Menu) {
+// display function help
+func (c *CLI) displayFunctionHelp(help []Help) {
+	c.Page(functionHelpString(help))
+}
+
+// return the help string for a command at a menu level
+func commandHelpString(cmd string, menu Menu) string {
 	s := make([][]string, 0, len(menu))
 	for _, item := range menu {
 		name := item[0].(string)
@@ -295,77 +404,483 @@ func (c *CLI) commandHelp(cmd string, menu Menu) {
 			s = append(s, []string{"  ", name, fmt.Sprintf(": %s", descr)})
 		}
 	}
-	c.Put(TableString(s, []int{0, 16, 0}, 1) + "\n")
+	return TableString(s, []int{0, 16, 0}, 1) + "\n"
+}
+
+// display help results for a command at a menu level
+func (c *CLI) commandHelp(cmd string, menu Menu) {
+	c.Page(commandHelpString(cmd, menu))
+}
+
+// return the help string for a leaf function
+func leafHelpString(item MenuItem) string {
+	help := itemHelp(item)
+	if help == nil {
+		help = crHelp
+	}
+	return functionHelpString(help) + examplesString(itemExamples(item))
 }
 
 // display help for a leaf function
 func (c *CLI) functionHelp(item MenuItem) {
-	var help []Help
-	if len(item) == 3 {
-		help = item[2].([]Help)
+	c.Page(leafHelpString(item))
+}
+
+// return the string for example invocations of a leaf function
+func examplesString(examples []Example) string {
+	if len(examples) == 0 {
+		return ""
+	}
+	s := make([][]string, len(examples))
+	for i, e := range examples {
+		s[i] = []string{"   ", e.Cmd, fmt.Sprintf(": %s", e.Descr)}
+	}
+	return "  Examples:\n" + TableString(s, []int{0, 16, 0}, 1) + "\n"
+}
+
+// Return the context sensitive help for the command line up to the cursor.
+func (c *CLI) helpCallback(cmdLine string) string {
+	cmdList := c.parser.Split(cmdLine)
+	// the last token is incomplete unless it is followed by a space
+	partial := ""
+	if len(cmdList) != 0 && !strings.HasSuffix(cmdLine, " ") {
+		partial = cmdList[len(cmdList)-1]
+		cmdList = cmdList[:len(cmdList)-1]
+	}
+	// trace each command through the menu tree
+	menu := c.root
+	for _, cmd := range cmdList {
+		matches := c.parser.Match(menu, cmd)
+		if len(matches) == 0 {
+			return c.colorString("unknown command", theme.Error, true) + "\n"
+		}
+		if len(matches) > 1 {
+			s := c.colorString("ambiguous command", theme.Error, true) + "\n"
+			return s + commandHelpString(cmd, Menu(matches))
+		}
+		item := matches[0]
+		if submenu, ok := item[1].(Menu); ok {
+			menu = submenu
+			continue
+		}
+		// past the leaf: argument help
+		return leafHelpString(item)
+	}
+	return commandHelpString(partial, menu)
+}
+
+// Return the next expected argument for a leaf command from its help.
+// Eg. HistoryHelp gives "<index>|delete|clear" for "history ".
+func argHint(item MenuItem, args []string) string {
+	var next []string
+	seen := make(map[string]bool)
+	for _, h := range itemHelp(item) {
+		parms := strings.Fields(h.Parm)
+		if len(parms) <= len(args) {
+			continue
+		}
+		// the typed arguments must match the keywords of the help form
+		match := true
+		for i, a := range args {
+			p := parms[i]
+			if !strings.HasPrefix(p, "<") && !strings.HasPrefix(p, "[") && !strings.HasPrefix(p, a) {
+				match = false
+				break
+			}
+		}
+		p := parms[len(args)]
+		if !match || p == "<cr>" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		next = append(next, p)
+	}
+	return strings.Join(next, "|")
+}
+
+// Return the argument hint for the command line.
+func (c *CLI) hintsCallback(cmdLine string) *Hint {
+	if !strings.HasSuffix(cmdLine, " ") {
+		// hint once the current token is complete
+		return nil
+	}
+	_, item, args, err := c.resolvePath(c.parser.Split(c.expandAlias(cmdLine)))
+	if err != nil {
+		return nil
+	}
+	s := argHint(item, args)
+	if s == "" {
+		return nil
+	}
+	return &Hint{s, theme.Hint, false}
+}
+
+// SetArgHints enables hints for the next expected argument of a leaf command.
+// The hints are taken from the help of the menu item.
+func (c *CLI) SetArgHints(enable bool) {
+	if enable {
+		c.ln.SetHintsCallback(c.hintsCallback)
 	} else {
-		help = crHelp
+		c.ln.SetHintsCallback(nil)
 	}
-	c.displayFunctionHelp(help)
 }
 
 // Return a slice of line completion strings for the command line.
 func (c *CLI) completionCallback(cmdLine string) []string {
-	line := ""
-	// split the command line into a list of command indices
-	cmdIndices := splitIndex(cmdLine)
-	// trace each command through the menu tree
-	menu := c.root
-	for _, index := range cmdIndices {
-		cmd := cmdLine[index[0]:index[1]]
-		line = cmdLine[:index[1]]
-		// How many items does this token match at this level of the menu?
-		matches := make([]MenuItem, 0, len(menu))
-		for _, item := range menu {
-			if strings.HasPrefix(item[0].(string), cmd) {
-				matches = append(matches, item)
+	lc := wordCompletions(cmdLine, c.menuWords)
+	if c.completionSpace && len(lc) == 1 {
+		// unique completion: add a space so the next word can be typed
+		lc[0] += " "
+	}
+	if !c.padDisplay {
+		// pad the completions to the length of the command line
+		padCompletions(lc, len(cmdLine))
+	}
+	c.historyMatches = nil
+	if c.historyComplete {
+		// history completions follow the menu completions
+		hc := c.historyCompletions(cmdLine, lc)
+		c.historyMatches = make(map[string]bool, len(hc))
+		for _, s := range hc {
+			c.historyMatches[s] = true
+		}
+		lc = append(lc, hc...)
+	}
+	return lc
+}
+
+// Return the label for a line completion. History completions are marked.
+func (c *CLI) completionLabel(s string) *Hint {
+	if !c.historyMatches[s] {
+		return nil
+	}
+	return &Hint{"  (history)", theme.Hint, false}
+}
+
+// Return the history entries that complete the command line, most recent first.
+// Entries already in the completion list are skipped.
+func (c *CLI) historyCompletions(cmdLine string, lc []string) []string {
+	if strings.TrimSpace(cmdLine) == "" {
+		return nil
+	}
+	minlen := len(cmdLine)
+	if c.padDisplay {
+		minlen = 0
+	}
+	seen := make(map[string]bool)
+	for _, s := range lc {
+		seen[strings.TrimRight(s, " ")] = true
+	}
+	var hc []string
+	h := c.ln.historyList()
+	for i := len(h) - 1; i >= 0; i-- {
+		s := h[i]
+		if len(s) <= len(cmdLine) || !strings.HasPrefix(s, cmdLine) || seen[s] {
+			continue
+		}
+		seen[s] = true
+		hc = append(hc, PadWidth(s, minlen))
+	}
+	return hc
+}
+
+// SetHistoryCompletion enables history entries as a completion source.
+// Matching prior command lines are offered after the menu completions.
+func (c *CLI) SetHistoryCompletion(enable bool) {
+	c.historyComplete = enable
+}
+
+// SetCompletionSpace enables a trailing space after a unique completion.
+func (c *CLI) SetCompletionSpace(enable bool) {
+	c.completionSpace = enable
+}
+
+// SetHelpChar sets the trailing character that asks for command help.
+// The default is '?', use 0 when the character is needed as a literal argument.
+func (c *CLI) SetHelpChar(r rune) {
+	c.helpChar = r
+	c.setHelpKey()
+}
+
+// Set the line editor help key to the help character.
+func (c *CLI) setHelpKey() {
+	if c.helpChar == 0 {
+		c.ln.SetHelpCallback(KeycodeNull, nil)
+		return
+	}
+	c.ln.SetHelpCallback(c.helpChar, c.helpCallback)
+}
+
+// Strip a trailing help character from a string.
+// Returns true if the string had an unescaped help character.
+func (c *CLI) helpSuffix(s string) (string, bool) {
+	if c.helpChar == 0 {
+		return s, false
+	}
+	hc := string(c.helpChar)
+	if !strings.HasSuffix(s, hc) || strings.HasSuffix(s, "\\"+hc) {
+		return s, false
+	}
+	return strings.TrimSuffix(s, hc), true
+}
+
+// Replace escaped help characters (Eg. \?) in the arguments with the
+// literal character.
+func (c *CLI) helpUnescape(args []string) []string {
+	if c.helpChar == 0 {
+		return args
+	}
+	hc := string(c.helpChar)
+	esc := "\\" + hc
+	var out []string
+	for i, arg := range args {
+		if strings.Contains(arg, esc) {
+			if out == nil {
+				out = append([]string{}, args...)
 			}
+			out[i] = strings.Replace(arg, esc, hc, -1)
 		}
-		if len(matches) == 0 {
-			// no matches, no completions
+	}
+	if out == nil {
+		return args
+	}
+	return out
+}
+
+// Return the menu names that complete the last word of the command line.
+func (c *CLI) menuWords(line, word string) []string {
+	// a trailing help character asks for help, complete the command without it
+	line, _ = c.helpSuffix(line)
+	word, _ = c.helpSuffix(word)
+	// trace the preceding words through the menu tree
+	menu := c.root
+	for i, cmd := range c.parser.Split(line[:len(line)-len(word)]) {
+		matches := c.parser.Match(menu, cmd)
+		if len(matches) != 1 {
+			// unknown or ambiguous command, no completions
 			return nil
-		} else if len(matches) == 1 {
-			item := matches[0]
-			if len(cmd) < len(item[0].(string)) {
-				// it's an unambiguous single match, but we still complete it
-				return completions(line, cmd, menuNames(matches), len(cmdLine))
-			}
-			// we have the whole command - is this a submenu or leaf?
-			if submenu, ok := item[1].(Menu); ok {
-				// submenu: switch to the submenu and continue parsing
-				menu = submenu
+		}
+		submenu, ok := matches[0][1].(Menu)
+		if !ok {
+			if i == 0 && matches[0][0].(string) == "help" {
+				// help takes a command path
+				menu = c.root
 				continue
-			} else {
-				// leaf function: no completions to offer
-				return nil
 			}
-		} else {
-			// Multiple matches at this level. Return the matches.
-			return completions(line, cmd, menuNames(matches), len(cmdLine))
+			// leaf function: no completions to offer
+			return nil
+		}
+		menu = submenu
+	}
+	// How many items does the word match at this level of the menu?
+	var names []string
+	var item MenuItem
+	for _, x := range menu {
+		if strings.HasPrefix(x[0].(string), word) {
+			names = append(names, x[0].(string))
+			item = x
+		}
+	}
+	if len(names) == 1 && names[0] == word {
+		// we have the whole command - is this a submenu or leaf?
+		submenu, ok := item[1].(Menu)
+		if !ok {
+			if c.completionSpace {
+				// leaf function: complete it with a trailing space
+				return names
+			}
+			// leaf function: no completions to offer
+			return nil
+		}
+		// the completions are all of the submenu items
+		names = menuNames(submenu)
+		for i := range names {
+			names[i] = word + " " + names[i]
 		}
 	}
-	// We've made it here without returning a completion list.
-	// The prior set of tokens have all matched single submenu items.
-	// The completions are all of the items at the current menu level.
-	return completions(line, "", menuNames(menu), len(cmdLine))
+	return names
+}
+
+//-----------------------------------------------------------------------------
+// menu tree introspection
+
+// Call a function for each item in a menu tree.
+func walkMenu(menu Menu, path []string, fn func(path []string, item MenuItem)) {
+	for _, item := range menu {
+		p := append(append([]string{}, path...), item[0].(string))
+		fn(p, item)
+		if submenu, ok := item[1].(Menu); ok {
+			walkMenu(submenu, p, fn)
+		}
+	}
+}
+
+// Walk calls a function for each menu item in the menu tree.
+// Items are visited in menu order, a submenu before its items.
+func (c *CLI) Walk(fn func(path []string, item MenuItem)) {
+	walkMenu(c.root, nil, fn)
+}
+
+// Find returns the menu item for a path of menu item names.
+func (c *CLI) Find(path []string) (MenuItem, error) {
+	if len(path) == 0 {
+		return nil, errors.New("empty path")
+	}
+	menu := c.root
+	for i, name := range path {
+		var found MenuItem
+		for _, item := range menu {
+			if item[0].(string) == name {
+				found = item
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("\"%s\" not found", strings.Join(path[:i+1], " "))
+		}
+		if i == len(path)-1 {
+			return found, nil
+		}
+		submenu, ok := found[1].(Menu)
+		if !ok {
+			return nil, fmt.Errorf("\"%s\" is not a menu", strings.Join(path[:i+1], " "))
+		}
+		menu = submenu
+	}
+	return nil, nil
+}
+
+// Return the argument help of a leaf menu item, nil for generic <cr> help.
+func itemHelp(item MenuItem) []Help {
+	for _, x := range item[2:] {
+		if help, ok := x.([]Help); ok {
+			return help
+		}
+	}
+	return nil
+}
+
+// Return the example invocations of a leaf menu item.
+func itemExamples(item MenuItem) []Example {
+	for _, x := range item[2:] {
+		if examples, ok := x.([]Example); ok {
+			return examples
+		}
+	}
+	return nil
+}
+
+// Return the flags of a leaf menu item.
+func itemFlags(item MenuItem) ItemFlags {
+	for _, x := range item[2:] {
+		if flags, ok := x.(ItemFlags); ok {
+			return flags
+		}
+	}
+	return 0
+}
+
+// Return the description of a menu item.
+func itemDescr(item MenuItem) string {
+	if leaf, ok := item[1].(Leaf); ok {
+		return leaf.Descr
+	}
+	return item[2].(string)
+}
+
+//-----------------------------------------------------------------------------
+
+// Return the menu items matching a command.
+// An exact match is returned as the single match.
+func menuMatches(menu Menu, cmd string) []MenuItem {
+	matches := make([]MenuItem, 0, len(menu))
+	for _, item := range menu {
+		if item[0].(string) == cmd {
+			// accept an exact match
+			return []MenuItem{item}
+		}
+		if strings.HasPrefix(item[0].(string), cmd) {
+			matches = append(matches, item)
+		}
+	}
+	return matches
+}
+
+// Resolve a command list to a leaf menu item and its arguments.
+func (c *CLI) resolve(cmdList []string) (MenuItem, []string, error) {
+	_, item, args, err := c.resolvePath(cmdList)
+	return item, args, err
+}
+
+// Resolve a command list to the menu path of a leaf menu item and its arguments.
+func (c *CLI) resolvePath(cmdList []string) ([]string, MenuItem, []string, error) {
+	menu := c.root
+	path := make([]string, 0, len(cmdList))
+	for idx, cmd := range cmdList {
+		matches := c.parser.Match(menu, cmd)
+		if len(matches) == 0 {
+			return nil, nil, nil, fmt.Errorf("unknown command \"%s\"", cmd)
+		}
+		if len(matches) > 1 {
+			return nil, nil, nil, fmt.Errorf("ambiguous command \"%s\"", cmd)
+		}
+		item := matches[0]
+		path = append(path, item[0].(string))
+		if submenu, ok := item[1].(Menu); ok {
+			// submenu, switch to the submenu and continue
+			menu = submenu
+			continue
+		}
+		// leaf function
+		return path, item, cmdList[idx+1:], nil
+	}
+	return nil, nil, nil, errors.New("additional input needed")
+}
+
+// Resolve returns the menu path of the leaf function a command line
+// dispatches to and the leaf function arguments. The leaf isn't called.
+func (c *CLI) Resolve(line string) ([]string, []string, error) {
+	path, _, args, err := c.resolvePath(c.parser.Split(c.expandAlias(line)))
+	return path, args, err
+}
+
+// Complete returns the completions offered by the tab key for a command line.
+func (c *CLI) Complete(line string) []string {
+	return c.completionCallback(line)
 }
 
 // Parse and process the current command line.
 // Return a string for the new command line.
 // The return string is generally empty, but may be non-empty for command history.
 func (c *CLI) parseCmdline(line string) string {
-	// scan the command line into a list of tokens
-	cmdList := make([]string, 0, 8)
-	for _, s := range strings.Split(line, " ") {
-		if len(s) != 0 {
-			cmdList = append(cmdList, s)
-		}
+	p := c.historyIgnore
+	if p == "" || !strings.HasPrefix(line, p) {
+		return c.parseLine(line)
+	}
+	// run the command without adding it to history
+	line = line[len(p):]
+	c.skipHistory = true
+	next := c.parseLine(line)
+	c.skipHistory = false
+	if next != "" && strings.HasPrefix(line, next) {
+		// keep the prefix on a recycled command line
+		next = p + next
 	}
+	return next
+}
+
+// Parse and process a command line.
+func (c *CLI) parseLine(line string) string {
+	c.result = nil
+	if c.isComment(line) {
+		// comments are kept in history and macros but not run
+		c.historyAdd(line)
+		c.macroRecord(c.macro.recording, strings.TrimSpace(line))
+		return ""
+	}
+	line = c.expandAlias(line)
+	// scan the command line into a list of tokens
+	cmdList := c.parser.Split(line)
 	// if there are no commands, print a new empty prompt
 	if len(cmdList) == 0 {
 		return ""
@@ -373,31 +888,21 @@ func (c *CLI) parseCmdline(line string) string {
 	// trace each command through the menu tree
 	menu := c.root
 	for idx, cmd := range cmdList {
-		// A trailing '?' means the user wants help for this command
-		if cmd[len(cmd)-1] == '?' {
-			// strip off the '?'
-			cmd = cmd[:len(cmd)-1]
-			c.commandHelp(cmd, menu)
-			// strip off the '?' and recycle the command
-			return line[:len(line)-1]
+		// A trailing help character means the user wants help for this command
+		if s, ok := c.helpSuffix(cmd); ok {
+			c.commandHelp(s, menu)
+			// strip off the help character and recycle the command
+			s, _ = c.helpSuffix(line)
+			return s
 		}
 		// try to match the cmd with a unique menu item
-		matches := make([]MenuItem, 0, len(menu))
-		for _, item := range menu {
-			if item[0].(string) == cmd {
-				// accept an exact match
-				matches = []MenuItem{item}
-				break
-			}
-			if strings.HasPrefix(item[0].(string), cmd) {
-				matches = append(matches, item)
-			}
-		}
+		matches := c.parser.Match(menu, cmd)
 		if len(matches) == 0 {
 			// no matches - unknown command
 			c.displayError("unknown command", cmdList, idx)
+			c.result = errors.New("unknown command")
 			// add it to history in case the user wants to edit this junk
-			c.ln.HistoryAdd(strings.TrimSpace(line))
+			c.historyAdd(line)
 			// go back to an empty prompt
 			return ""
 		}
@@ -412,16 +917,22 @@ func (c *CLI) parseCmdline(line string) string {
 				// leaf function - get the arguments
 				args := cmdList[idx+1:]
 				if len(args) != 0 {
-					lastArg := args[len(args)-1]
-					if lastArg[len(lastArg)-1] == '?' {
+					if _, ok := c.helpSuffix(args[len(args)-1]); ok {
 						c.functionHelp(item)
-						// strip off the '?', repeat the command
-						return line[:len(line)-1]
+						// strip off the help character, repeat the command
+						s, _ := c.helpSuffix(line)
+						return s
 					}
 				}
+				if !c.rateCheck() {
+					// too many commands, go back to an empty prompt
+					return ""
+				}
 				// call the leaf function
-				leaf := item[1].(Leaf).F
-				leaf(c, args)
+				recording := c.macro.recording
+				c.noHistory = false
+				c.runLeaf(item, c.helpUnescape(args))
+				c.macroRecord(recording, strings.TrimSpace(line))
 				// post leaf function actions
 				if c.nextLine != "" {
 					s := c.nextLine
@@ -429,43 +940,155 @@ func (c *CLI) parseCmdline(line string) string {
 					return s
 				}
 				// add the command to history
-				c.ln.HistoryAdd(strings.TrimSpace(line))
+				if itemFlags(item)&NoHistory == 0 && !c.noHistory {
+					c.historyAdd(line)
+				}
 				// return to an empty line
 				return ""
 			}
 		} else {
 			// multiple matches - ambiguous command
 			c.displayError("ambiguous command", cmdList, idx)
+			c.result = errors.New("ambiguous command")
+			// list the candidates so the user can disambiguate
+			c.commandHelp(cmd, Menu(matches))
 			return ""
 		}
 	}
 	// reached the end of the command list with no errors and no leaf function.
-	c.Put("additional input needed\n")
+	// display the submenu commands and keep the line for the user to complete
+	c.commandHelp("", menu)
+	c.result = errors.New("additional input needed")
 	return line
 }
 
+// Add a command line to history.
+// Lines run by scripts or with the history ignore prefix are not added.
+func (c *CLI) historyAdd(line string) {
+	if c.scriptDepth == 0 && !c.skipHistory {
+		c.ln.historyAdd(strings.TrimSpace(line), resultStatus(c.result))
+	}
+}
+
+// SetHistoryIgnorePrefix sets a prefix for command lines that are run but
+// not added to history (Eg. " " for commands containing secrets).
+// An empty prefix adds all command lines to history.
+func (c *CLI) SetHistoryIgnorePrefix(prefix string) {
+	c.historyIgnore = prefix
+}
+
+// SetCommentPrefix sets the prefix for comment lines. Comments are added to
+// history and recorded in macros but not run. The default prefix is "#",
+// an empty prefix disables comments.
+func (c *CLI) SetCommentPrefix(prefix string) {
+	c.commentPrefix = prefix
+}
+
+// Is the command line a comment?
+func (c *CLI) isComment(line string) bool {
+	return c.commentPrefix != "" && strings.HasPrefix(strings.TrimSpace(line), c.commentPrefix)
+}
+
+// SetResult sets the result of the command being run.
+// A leaf function uses it to report failure to scripts.
+func (c *CLI) SetResult(err error) {
+	c.result = err
+}
+
 //-----------------------------------------------------------------------------
 
+// IdleAction is the action taken when the CLI has been idle.
+type IdleAction int
+
+// Idle actions
+const (
+	IdleWarn IdleAction = iota // display a warning
+	IdleLock                   // lock the session until it is unlocked
+	IdleExit                   // exit the CLI
+)
+
+// keyboard polling interval for Sleep
+const sleepPoll = 20 * time.Millisecond
+
 // CLI stores the CLI state.
 type CLI struct {
-	User        USER       // user provided object
-	ln          *Linenoise // line editing object
-	root        Menu       // root of menu structure
-	currentLine string     // current command line
-	nextLine    string     // next line set by a leaf function
-	prompt      string     // cli prompt string
-	running     bool       // is the cli running?
+	User            USER              // user provided object
+	ln              *Linenoise        // line editing object
+	root            Menu              // root of menu structure
+	currentLine     string            // current command line
+	nextLine        string            // next line set by a leaf function
+	prompt          string            // cli prompt string
+	running         bool              // is the cli running?
+	err             error             // reason the cli stopped running
+	padDisplay      bool              // pad completions for display only
+	sharedLn        bool              // the line editor belongs to a parent cli
+	sched           *scheduler        // scheduled commands
+	outputMode      OutputMode        // table output encoding
+	banner          string            // banner displayed on startup
+	motd            func() string     // message of the day displayed on startup
+	started         bool              // has the cli started?
+	idleAction      IdleAction        // action for an idle timeout
+	auth            auth              // user authentication
+	prefsPath       string            // user preferences file
+	length          int               // lines per page, 0 = terminal height, < 0 = no paging
+	aliases         map[string]string // command aliases
+	dryRun          bool              // validate commands without running them
+	applied         bool              // Apply() has been called by the leaf function
+	macro           macros            // command macros
+	scriptDepth     int               // nesting depth of running scripts
+	result          error             // result of the last command
+	plugins         []Plugin          // registered plugins
+	events          *eventBus         // event subscriptions
+	segments        *promptSegments   // prompt status segments
+	status          *statusLine       // status line
+	putInterval     time.Duration     // minimum interval between output writes
+	out             *coalescer        // coalesced output of the running leaf function
+	historyComplete bool              // offer history entries as completions
+	historyMatches  map[string]bool   // history entries in the last completions
+	historyPath     string            // history file saved when the cli stops
+	completionSpace bool              // add a space after a unique completion
+	historyIgnore   string            // prefix for command lines not added to history
+	skipHistory     bool              // don't add the current command line to history
+	noHistory       bool              // the leaf function doesn't want its command line in history
+	startHooks      []func(*CLI)      // functions called when the cli starts
+	stopHooks       []func(*CLI)      // functions called when the cli stops
+	closed          bool              // has the cli been closed?
+	preRead         PreReadFunc       // called before each command line is read
+	color           ColorMode         // ANSI colors for the session, ColorAuto for the process setting
+	charset         Charset           // terminal character set for the session
+	limiter         *rateLimiter      // command rate limit
+	helpChar        rune              // trailing character that asks for help, 0 for none
+	parser          LineParser        // splits and matches command lines
+	commentPrefix   string            // prefix for comment lines, "" for none
+	cmdTimeout      time.Duration     // execution timeout for leaf commands, 0 for none
+	ctx             context.Context   // context of the running command, nil for none
+	work            *workQueue        // work for the goroutine running the cli
+	origin          *CLI              // the cli this session is a copy of, nil for none
 }
 
-// NewCLI returns a new CLI object.
-func NewCLI(user USER) *CLI {
+// NewCLI returns a new CLI object configured with the options.
+func NewCLI(user USER, opts ...Option) *CLI {
 	c := CLI{}
 	c.User = user
 	c.ln = NewLineNoise()
 	c.ln.SetCompletionCallback(c.completionCallback)
-	c.ln.SetHotkey('?')
+	c.ln.SetCompletionLabelCallback(c.completionLabel)
+	c.helpChar = '?'
+	c.parser = DefaultParser
+	c.commentPrefix = "#"
+	c.ln.SetHelpCallback(c.helpChar, c.helpCallback)
 	c.prompt = "> "
 	c.running = true
+	c.sched = &scheduler{jobs: make(map[int]*Job)}
+	c.events = &eventBus{}
+	c.segments = &promptSegments{}
+	c.AddPromptSegment("mode", c.editMode, -1)
+	c.ln.SetOverwriteCallback(c.overwriteCallback)
+	c.status = &statusLine{}
+	c.work = &workQueue{}
+	for _, opt := range opts {
+		opt(&c)
+	}
 	return &c
 }
 
@@ -479,6 +1102,69 @@ func (c *CLI) SetPrompt(prompt string) {
 	c.prompt = prompt
 }
 
+// SetCompletionPadding sets how completions are padded to the length of the
+// command line. If displayOnly is true the padding is only shown while cycling
+// through the completions and is not inserted into the line buffer.
+func (c *CLI) SetCompletionPadding(displayOnly bool) {
+	c.padDisplay = displayOnly
+	c.ln.SetCompletionPadding(displayOnly)
+}
+
+// SetOutputMode sets the encoding used for table output.
+func (c *CLI) SetOutputMode(mode OutputMode) {
+	c.outputMode = mode
+}
+
+// OutputMode returns the encoding used for table output.
+func (c *CLI) OutputMode() OutputMode {
+	return c.outputMode
+}
+
+// SetBanner sets a banner string displayed when the CLI starts.
+func (c *CLI) SetBanner(s string) {
+	c.banner = s
+}
+
+// SetMOTD sets a message of the day function.
+// The message is displayed after the banner when the CLI starts.
+func (c *CLI) SetMOTD(fn func() string) {
+	c.motd = fn
+}
+
+// Display the startup banner and message of the day.
+func (c *CLI) displayBanner() {
+	if c.banner != "" {
+		c.Put(strings.TrimSuffix(c.banner, "\n") + "\n")
+	}
+	if c.motd != nil {
+		if s := c.motd(); s != "" {
+			c.Put(strings.TrimSuffix(s, "\n") + "\n")
+		}
+	}
+}
+
+// SetIdleTimeout sets a timeout for command input and the action to take
+// when there has been no input for the duration. A zero duration disables
+// the timeout.
+func (c *CLI) SetIdleTimeout(d time.Duration, action IdleAction) {
+	c.ln.SetIdleTimeout(d)
+	c.idleAction = action
+}
+
+// Take the idle timeout action.
+func (c *CLI) idle() {
+	switch c.idleAction {
+	case IdleWarn:
+		c.Put("idle timeout\n")
+	case IdleLock:
+		c.Lock()
+	default:
+		c.Put("idle timeout, exiting\n")
+		c.running = false
+		c.err = ErrIdle
+	}
+}
+
 // SetLine sets the next command line.
 func (c *CLI) SetLine(line string) {
 	c.nextLine = line
@@ -486,22 +1172,199 @@ func (c *CLI) SetLine(line string) {
 
 // Loop is a passthrough to the wait for hotkey Loop().
 func (c *CLI) Loop(fn func() bool, exitKey rune) bool {
-	return c.ln.Loop(fn, exitKey)
+	return c.loopKeys(fn, []rune{exitKey})
+}
+
+// Call a loop function until it returns true, an exit key is pressed or the
+// leaf context is cancelled. Returns true if the loop function completed.
+func (c *CLI) loopKeys(fn func() bool, exitKeys []rune) bool {
+	if c.Context().Err() != nil {
+		return false
+	}
+	done := false
+	c.ln.LoopKeys(func() bool {
+		if c.Context().Err() != nil {
+			return true
+		}
+		done = fn()
+		return done
+	}, exitKeys)
+	return done
+}
+
+// ReadKey is a passthrough to the line editor ReadKey().
+// It's intended for use by leaf functions.
+func (c *CLI) ReadKey(timeout time.Duration) (KeyEvent, error) {
+	if c.out != nil {
+		// show pending output before waiting
+		c.out.flush()
+	}
+	return c.ln.ReadKeyContext(c.Context(), timeout)
+}
+
+// Read a line from within a leaf function.
+// Command completion and the help hotkey are disabled while reading.
+func (c *CLI) leafRead(prompt, init string) (string, error) {
+	if c.out != nil {
+		// show pending output before the prompt
+		c.out.flush()
+	}
+	settings := c.ln.saveSettings()
+	c.ln.SetCompletionCallback(nil)
+	c.ln.SetHelpCallback(KeycodeNull, nil)
+	c.ln.SetHintsCallback(nil)
+	c.ln.SetOverwriteCallback(nil)
+	defer c.ln.restoreSettings(settings)
+	return c.ln.ReadContext(c.Context(), prompt, init)
+}
+
+// ReadLineDefault prompts for a line of input with an editable default value.
+// It's intended for use by leaf functions.
+func (c *CLI) ReadLineDefault(prompt, def string) (string, error) {
+	return c.leafRead(prompt, def)
+}
+
+// ReadLineValid prompts for a line of input until the validation function
+// accepts it. Validation errors are displayed and the rejected line is
+// re-presented for editing. An error is returned if the user quits.
+func (c *CLI) ReadLineValid(prompt, def string, valid func(string) error) (string, error) {
+	for {
+		s, err := c.leafRead(prompt, def)
+		if err != nil {
+			return "", err
+		}
+		err = valid(s)
+		if err == nil {
+			return s, nil
+		}
+		c.Put(c.colorString(err.Error(), theme.Error, true) + "\n")
+		def = s
+	}
+}
+
+// ReadInt prompts for an integer within limits.
+// The number may have a base prefix (0b, 0o, 0x).
+func (c *CLI) ReadInt(prompt string, def int, limits [2]int) (int, error) {
+	var val int
+	_, err := c.ReadLineValid(prompt, strconv.Itoa(def), func(s string) error {
+		var err error
+		val, err = IntArg(strings.TrimSpace(s), limits, 0)
+		return err
+	})
+	return val, err
+}
+
+// ReadUint prompts for an unsigned integer within limits.
+// The number may have a base prefix (0b, 0o, 0x).
+func (c *CLI) ReadUint(prompt string, def uint, limits [2]uint) (uint, error) {
+	var val uint
+	_, err := c.ReadLineValid(prompt, strconv.FormatUint(uint64(def), 10), func(s string) error {
+		var err error
+		val, err = UintArg(strings.TrimSpace(s), limits, 0)
+		return err
+	})
+	return val, err
+}
+
+// ReadFloat prompts for a float within limits.
+func (c *CLI) ReadFloat(prompt string, def float64, limits [2]float64) (float64, error) {
+	var val float64
+	_, err := c.ReadLineValid(prompt, strconv.FormatFloat(def, 'g', -1, 64), func(s string) error {
+		var err error
+		val, err = FloatArg(strings.TrimSpace(s), limits)
+		return err
+	})
+	return val, err
+}
+
+// ReadSecret prompts for a secret (e.g. a password) with masked input.
+// If confirm is true the secret must be entered twice.
+func (c *CLI) ReadSecret(prompt string, confirm bool) (string, error) {
+	for {
+		s, err := c.ln.readPassword(c.Context(), prompt)
+		if err != nil || !confirm {
+			return s, err
+		}
+		s2, err := c.ln.readPassword(c.Context(), "repeat to confirm: ")
+		if err != nil {
+			return "", err
+		}
+		if s == s2 {
+			return s, nil
+		}
+		c.Put(c.colorString("entries do not match", theme.Error, true) + "\n")
+	}
+}
+
+// Sleep waits for a duration. The wait can be cut short with ctrl-C, or by
+// cancelling the leaf context.
+// Returns true if the full duration elapsed, false if it was interrupted.
+func (c *CLI) Sleep(d time.Duration) bool {
+	if c.ln.term == nil && !isTerminal(stdinFd) {
+		// not interactive: just sleep
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			return true
+		case <-c.Context().Done():
+			return false
+		}
+	}
+	end := time.Now().Add(d)
+	return c.Loop(func() bool {
+		remain := time.Until(end)
+		if remain <= 0 {
+			return true
+		}
+		// keep polling the keyboard
+		if remain > sleepPoll {
+			remain = sleepPoll
+		}
+		time.Sleep(remain)
+		return false
+	}, KeycodeCtrlC)
+}
+
+// PutAsync outputs a string above the command line being edited.
+// It's safe to call from other goroutines.
+func (c *CLI) PutAsync(s string) {
+	c.ln.PrintAsync(s)
 }
 
 // Put is a passthrough to the user provided Put().
 func (c *CLI) Put(s string) {
+	if c.out != nil {
+		c.out.write(s)
+		return
+	}
 	c.User.Put(s)
 }
 
 // GeneralHelp displays general help.
 func (c *CLI) GeneralHelp() {
-	c.displayFunctionHelp(generalHelp)
+	help := make([]Help, 0, len(generalHelp)+1)
+	if c.helpChar != 0 {
+		// show the configured help character
+		hc := string(c.helpChar)
+		help = append(help, Help{hc, fmt.Sprintf("display command help - Eg. %s, show %s, s%s", hc, hc, hc)})
+		help = append(help, Help{"\\" + hc, fmt.Sprintf("a literal %s in an argument", hc)})
+	}
+	n := len(generalHelp) - 1
+	help = append(help, generalHelp[2:n]...)
+	if c.commentPrefix != "" {
+		// show the configured comment prefix
+		help = append(help, Help{c.commentPrefix + " text", "a comment, added to history but not run"})
+	}
+	help = append(help, generalHelp[n:]...)
+	c.displayFunctionHelp(help)
 }
 
 // HistoryLoad loads command history from a file.
+// Macros are loaded from the macros directory next to the history file.
 func (c *CLI) HistoryLoad(path string) {
 	c.ln.HistoryLoad(path)
+	c.macroLoad(filepath.Join(filepath.Dir(path), "macros"))
 }
 
 // HistorySave saves command history to a file.
@@ -509,30 +1372,65 @@ func (c *CLI) HistorySave(path string) {
 	c.ln.HistorySave(path)
 }
 
+// Convert a history index argument for a history of length n.
+func historyIndex(arg string, n int) (int, error) {
+	idx, err := IntArg(arg, [2]int{-n, n - 1}, 10)
+	if err != nil {
+		return 0, err
+	}
+	// negative indices count backwards: -1 is the previous command
+	if idx < 0 {
+		idx = -idx - 1
+	}
+	return idx, nil
+}
+
 // DisplayHistory displays the command history.
 func (c *CLI) DisplayHistory(args []string) string {
 	// get the history
 	h := c.ln.historyList()
 	n := len(h)
+	if len(args) == 1 && args[0] == "clear" {
+		// clear all history, the command isn't added to the cleared history
+		c.ln.HistoryClear()
+		c.noHistory = true
+		return ""
+	}
+	if len(args) == 2 && args[0] == "delete" {
+		// delete a specific history entry
+		c.noHistory = true
+		idx, err := historyIndex(args[1], n)
+		if err != nil {
+			c.Put(fmt.Sprintf("%s\n", err))
+			return ""
+		}
+		c.ln.HistoryDelete(idx)
+		return ""
+	}
 	if len(args) == 1 {
 		// retrieve a specific history entry
-		idx, err := IntArg(args[0], [2]int{0, n - 1}, 10)
+		idx, err := historyIndex(args[0], n)
 		if err != nil {
-			c.User.Put(fmt.Sprintf("%s\n", err))
+			c.Put(fmt.Sprintf("%s\n", err))
 			return ""
 		}
-		// Return the next line buffer.
-		// Note: linenoise wants to add the line buffer as the zero-th history entry.
-		// It can only do this if it's unique- and this isn't because it's a prior
-		// history entry. Make it unique by adding a trailing whitespace. The other
-		// entries have been stripped prior to being added to history.
-		return h[n-idx-1] + " "
+		// preview the recalled command
+		c.Put(fmt.Sprintf("%-3d: %s\n", idx, h[n-idx-1]))
+		// return the next line buffer
+		return h[n-idx-1]
 	}
 	// display all history
 	if n > 0 {
 		s := make([]string, n)
-		for i := range s {
-			s[i] = fmt.Sprintf("%-3d: %s", n-i-1, h[i])
+		entries := c.ln.historyEntries()
+		// add a status column if commands have results
+		status := historyHasStatus(entries)
+		for i, e := range entries {
+			if status {
+				s[i] = fmt.Sprintf("%-3d: %s %s", n-i-1, c.statusString(e.status), e.line)
+			} else {
+				s[i] = fmt.Sprintf("%-3d: %s", n-i-1, e.line)
+			}
 		}
 		c.Put(strings.Join(s, "\n") + "\n")
 	} else {
@@ -541,17 +1439,172 @@ func (c *CLI) DisplayHistory(args []string) string {
 	return ""
 }
 
+// Return true if any history entry has a command result.
+func historyHasStatus(entries []historyEntry) bool {
+	for _, e := range entries {
+		if e.status != statusUnknown {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the status column string for a history entry.
+func (c *CLI) statusString(status historyStatus) string {
+	switch status {
+	case statusOK:
+		return "ok  "
+	case statusFailed:
+		return c.colorString("fail", theme.Error, false)
+	}
+	return "    "
+}
+
 // Run gets and processes a CLI command.
+// The banner and message of the day are displayed on the first call.
 func (c *CLI) Run() {
-	line, err := c.ln.Read(c.prompt, c.currentLine)
+	c.RunContext(context.Background())
+}
+
+// RunContext gets and processes a CLI command like Run.
+// If the context is cancelled while the command line is read the CLI stops
+// with ctx.Err() as the error. Leaf commands get the context from Context.
+func (c *CLI) RunContext(ctx context.Context) {
+	if c.workStart() {
+		defer c.workStop()
+	}
+	defer func() {
+		if !c.running {
+			c.Close()
+		}
+	}()
+	// the context of leaf commands
+	c.ctx = ctx
+	defer func() { c.ctx = nil }()
+	if !c.started {
+		c.started = true
+		c.displayBanner()
+		if err := c.authenticate(); err != nil {
+			c.running = false
+			c.err = err
+			return
+		}
+		for _, fn := range c.startHooks {
+			fn(c)
+		}
+	}
+	// work queued by other goroutines
+	c.doWork()
+	if !c.running {
+		return
+	}
+	prompt := c.fullPrompt()
+	if c.preRead != nil {
+		line, action := c.preRead(c, prompt)
+		switch action {
+		case ReadRun:
+			// echo and run the line without reading
+			c.Put(prompt + line + "\n")
+			c.currentLine = c.parseCmdline(line)
+			return
+		case ReadEdit:
+			c.currentLine = line
+		case ReadCancel:
+			return
+		}
+	}
+	line, err := c.ln.ReadContext(ctx, prompt, c.currentLine)
 	if err == nil {
 		c.currentLine = c.parseCmdline(line)
+	} else if err == ErrIdle {
+		// keep the partial line
+		c.currentLine = line
+		c.idle()
+	} else if err == ErrInterrupted {
+		// keep the partial line and do the queued work
+		c.currentLine = line
+		c.doWork()
 	} else {
-		// exit: ctrl-C/ctrl-D
+		// exit: ctrl-C/ctrl-D or the end of piped input
 		c.running = false
+		c.err = err
 	}
 }
 
+// ReadAction is the action returned by a pre-read function.
+type ReadAction int
+
+// Pre-read actions.
+const (
+	ReadContinue ReadAction = iota // read the command line as usual
+	ReadRun                        // run the returned line without reading
+	ReadEdit                       // read the command line with the returned line to edit
+	ReadCancel                     // don't read a command line, Run returns
+)
+
+// PreReadFunc is called by Run before each command line is read, after the
+// prompt has been computed. It can print queued output, run a pending line
+// (Eg. from a command queue), preload the line buffer or cancel the read.
+type PreReadFunc func(c *CLI, prompt string) (string, ReadAction)
+
+// SetPreRead sets the function called before each command line is read.
+func (c *CLI) SetPreRead(fn PreReadFunc) {
+	c.preRead = fn
+}
+
+// Close stops the CLI. The stop functions are called, the preferences and
+// history are saved and the plugins are closed. It's called by Run when the
+// CLI stops running and only has an effect once.
+func (c *CLI) Close() {
+	c.running = false
+	if c.closed {
+		return
+	}
+	c.closed = true
+	if c.started {
+		// stop functions in the reverse order of the start functions
+		for i := len(c.stopHooks) - 1; i >= 0; i-- {
+			c.stopHooks[i](c)
+		}
+	}
+	c.PrefsSave()
+	if c.historyPath != "" {
+		c.HistorySave(c.historyPath)
+	}
+	c.ClosePlugins()
+	c.SetStatus("")
+	if !c.sharedLn {
+		c.ln.Close()
+	}
+}
+
+// OnStart adds a function called when the CLI starts running, after the
+// banner and authentication. Start functions are called in the order added.
+func (c *CLI) OnStart(fn func(*CLI)) {
+	c.startHooks = append(c.startHooks, fn)
+}
+
+// OnStop adds a function called when a started CLI stops running.
+// Stop functions are called in the reverse order added.
+func (c *CLI) OnStop(fn func(*CLI)) {
+	c.stopHooks = append(c.stopHooks, fn)
+}
+
+// Shutdown stops the CLI from another goroutine.
+// A command line being edited is abandoned.
+func (c *CLI) Shutdown() {
+	c.ln.Shutdown()
+}
+
+// Err returns the reason the CLI stopped running.
+// It is ErrQuit if the user quit, ErrEOF at the end of piped input,
+// ErrIdle for an idle timeout exit, ErrAuth if authentication failed,
+// ErrShutdown after Shutdown(), a read error, or nil if the CLI is running
+// or Exit() was called.
+func (c *CLI) Err() error {
+	return c.err
+}
+
 // Running returns true if the CLI is running.
 func (c *CLI) Running() bool {
 	return c.running
@@ -562,4 +1615,32 @@ func (c *CLI) Exit() {
 	c.running = false
 }
 
+// ErrNoDryRun is the result of a state changing leaf that doesn't support dry-run mode.
+var ErrNoDryRun = errors.New("command doesn't support dry-run mode, state may have changed")
+
+// SetDryRun sets dry-run mode. In dry-run mode leaf functions validate their
+// arguments as usual but don't change any state. See Apply(). Leaves that
+// change state are flagged with StateChanging, a flagged leaf that returns
+// without calling Apply() or setting an error result is reported.
+func (c *CLI) SetDryRun(on bool) {
+	c.dryRun = on
+}
+
+// DryRun returns true if the CLI is in dry-run mode.
+func (c *CLI) DryRun() bool {
+	return c.dryRun
+}
+
+// Apply is called by a leaf function after validating its arguments and
+// before changing state. It returns true if the action should be performed.
+// In dry-run mode the action is displayed and false is returned.
+func (c *CLI) Apply(action string) bool {
+	c.applied = true
+	if c.dryRun {
+		c.Put(fmt.Sprintf("dry-run: %s\n", action))
+		return false
+	}
+	return true
+}
+
 //-----------------------------------------------------------------------------
@@ -1,6 +1,15 @@
 package cli
 
-import "testing"
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
 
 func Test_DisplayCols(t *testing.T) {
 	clist := [][]string{
@@ -13,34 +22,809 @@ func Test_DisplayCols(t *testing.T) {
 	t.Logf("\n%s\n", TableString(clist, nil, 1))
 }
 
-func indexCompare(a, b [][2]int) bool {
-	if len(a) != len(b) {
-		return false
+func Test_WidthHelpers(t *testing.T) {
+	tests := []struct {
+		s        string
+		w        int
+		trunc    string
+		ellipsis string
+		pad      string
+	}{
+		{"abc", 5, "abc", "abc", "abc  "},
+		{"abcdef", 5, "abcde", "ab...", "abcdef"},
+		{"世界世界", 5, "世界", "世...", "世界世界"},
+		{"世a", 4, "世a", "世a", "世a "},
+		{"", 2, "", "", "  "},
+	}
+	for i, v := range tests {
+		if s := TruncateWidth(v.s, v.w); s != v.trunc {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.trunc, s)
+		}
+		if s := TruncateEllipsis(v.s, v.w); s != v.ellipsis {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.ellipsis, s)
+		}
+		if s := PadWidth(v.s, v.w); s != v.pad {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.pad, s)
+		}
+	}
+	// wide characters are aligned by display width
+	s := TableString([][]string{{"世界", "x"}, {"ab", "y"}}, nil, 1)
+	if s != "世界 x \nab   y " {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "世界 x \nab   y ", s)
+	}
+}
+
+// testUser collects the output of the CLI.
+type testUser struct {
+	out strings.Builder
+}
+
+func (u *testUser) Put(s string) {
+	u.out.WriteString(s)
+}
+
+var testLeaf = Leaf{
+	Descr: "test leaf",
+	F:     func(c *CLI, args []string) {},
+}
+
+var testMenu = Menu{
+	{"show", testLeaf},
+	{"shutdown", Leaf{"shutdown the system", testLeaf.F}},
+	{"exit", testLeaf},
+}
+
+func Test_Ambiguous(t *testing.T) {
+	user := &testUser{}
+	c := NewCLI(user)
+	c.SetRoot(testMenu)
+	c.parseCmdline("sh")
+	out := user.out.String()
+	if !strings.HasPrefix(out, "ambiguous command\n") {
+		t.Errorf("FAIL missing error message: %q", out)
+	}
+	for _, s := range []string{"show", "test leaf", "shutdown", "shutdown the system"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("FAIL candidate %q not listed: %q", s, out)
+		}
+	}
+	if strings.Contains(out, "exit") {
+		t.Errorf("FAIL non-candidate listed: %q", out)
+	}
+}
+
+func Test_DisplayHistory(t *testing.T) {
+	user := &testUser{}
+	c := NewCLI(user)
+	for _, s := range []string{"cmd2", "cmd1", "cmd0"} {
+		c.ln.HistoryAdd(s)
+	}
+	tests := []struct {
+		arg  string
+		line string
+	}{
+		{"0", "cmd0"},
+		{"2", "cmd2"},
+		{"-1", "cmd0"},
+		{"-3", "cmd2"},
+		{"3", ""},
+		{"-4", ""},
+	}
+	for i, v := range tests {
+		line := c.DisplayHistory([]string{v.arg})
+		if line != v.line {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.line, line)
+		}
+	}
+}
+
+func Test_HistoryIgnorePrefix(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.SetRoot(testMenu)
+	c.SetHistoryIgnorePrefix(" ")
+	tests := []struct {
+		line    string
+		next    string
+		history string
+	}{
+		{"show", "", "show"},
+		{" exit", "", "show"},
+		{" bogus", "", "show"},
+		{" shutdown?", " shutdown", "show"},
+		{"exit", "", "show,exit"},
+	}
+	for i, v := range tests {
+		next := c.parseCmdline(v.line)
+		if next != v.next {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.next, next)
+		}
+		h := strings.Join(c.ln.historyList(), ",")
+		if h != v.history {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.history, h)
+		}
+	}
+}
+
+func Test_NoHistory(t *testing.T) {
+	user := &testUser{}
+	c := NewCLI(user)
+	c.SetRoot(Menu{
+		{"login", testLeaf, NoHistory},
+		{"passwd", testLeaf, []Help{{"<password>", "set the password"}}, NoHistory},
+		{"show", testLeaf},
+	})
+	for _, line := range []string{"show", "login", "passwd secret", "pass secret", "show 1"} {
+		c.parseCmdline(line)
+	}
+	h := strings.Join(c.ln.historyList(), ",")
+	if h != "show,show 1" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "show,show 1", h)
+	}
+	c.parseCmdline("passwd ?")
+	if out := user.out.String(); !strings.Contains(out, "set the password") {
+		t.Errorf("FAIL missing argument help: %q", out)
+	}
+}
+
+func Test_HistoryStatus(t *testing.T) {
+	user := &testUser{}
+	c := NewCLI(user)
+	c.SetRoot(Menu{
+		{"show", testLeaf},
+		{"fail", Leaf{"fail", func(c *CLI, args []string) { c.SetResult(errors.New("failed")) }}},
+	})
+	for _, line := range []string{"show", "fail", "bogus"} {
+		c.parseCmdline(line)
+	}
+	c.ln.HistoryAdd("added")
+	user.out.Reset()
+	c.DisplayHistory(nil)
+	expected := "3  : ok   show\n2  : fail fail\n1  : fail bogus\n0  :      added\n"
+	if out := user.out.String(); out != expected {
+		t.Errorf("FAIL expected (%q) != actual (%q)", expected, out)
+	}
+}
+
+func Test_HistoryDelete(t *testing.T) {
+	user := &testUser{}
+	c := NewCLI(user)
+	for _, s := range []string{"cmd3", "cmd2", "cmd1", "cmd0"} {
+		c.ln.HistoryAdd(s)
+	}
+	c.DisplayHistory([]string{"delete", "1"})
+	c.DisplayHistory([]string{"delete", "-1"})
+	h := strings.Join(c.ln.historyList(), " ")
+	if h != "cmd3 cmd2" {
+		t.Errorf("FAIL expected (cmd3 cmd2) != actual (%s)", h)
+	}
+	c.DisplayHistory([]string{"delete", "2"})
+	if !strings.Contains(user.out.String(), "out of range") {
+		t.Errorf("FAIL out of range index accepted")
+	}
+	c.DisplayHistory([]string{"clear"})
+	if len(c.ln.historyList()) != 0 {
+		t.Errorf("FAIL history not cleared")
+	}
+	// the history commands that change history aren't added to it
+	c.SetRoot(Menu{{"history", CmdHistory, HistoryHelp}})
+	for _, line := range []string{"history", "history delete 0", "history delete 9", "history clear"} {
+		c.parseCmdline(line)
+	}
+	if len(c.ln.historyList()) != 0 {
+		t.Errorf("FAIL expected () != actual (%q)", c.ln.historyList())
+	}
+	c.parseCmdline("history")
+	h = strings.Join(c.ln.historyList(), " ")
+	if h != "history" {
+		t.Errorf("FAIL expected (history) != actual (%s)", h)
+	}
+}
+
+func Test_FloatArg(t *testing.T) {
+	tests := []struct {
+		arg string
+		val float64
+		ok  bool
+	}{
+		{"1.5", 1.5, true},
+		{"-2", -2, true},
+		{"10.1", 0, false},
+		{"abc", 0, false},
+	}
+	for i, v := range tests {
+		val, err := FloatArg(v.arg, [2]float64{-5, 10})
+		if (err == nil) != v.ok || val != v.val {
+			t.Errorf("%d: FAIL expected (%v, %t) != actual (%v, %v)", i, v.val, v.ok, val, err)
+		}
+	}
+}
+
+func Test_CenterString(t *testing.T) {
+	s := CenterString("ab\n\nabcd", 8)
+	expected := "   ab\n\n  abcd"
+	if s != expected {
+		t.Errorf("FAIL expected (%q) != actual (%q)", expected, s)
+	}
+}
+
+func Test_FunctionHelpExamples(t *testing.T) {
+	user := &testUser{}
+	c := NewCLI(user)
+	c.functionHelp(MenuItem{"history", testLeaf, HistoryHelp, []Example{
+		{"history -1", "recall the previous command"},
+	}})
+	out := user.out.String()
+	for _, s := range []string{"delete <index>", "Examples:", "history -1", "recall the previous command"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("FAIL %q not in help: %q", s, out)
+		}
+	}
+}
+
+func Test_HelpCallback(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.SetRoot(Menu{
+		{"show", Menu{{"stats", testLeaf, HistoryHelp}}, "show functions"},
+		{"shutdown", testLeaf},
+	})
+	tests := []struct {
+		line   string
+		has    []string
+		hasNot []string
+	}{
+		{"", []string{"show", "shutdown"}, nil},
+		{"sho", []string{"show functions"}, []string{"shutdown"}},
+		{"show ", []string{"stats"}, []string{"shutdown"}},
+		{"sh st", []string{"ambiguous", "show", "shutdown"}, nil},
+		{"show stats ", []string{"delete <index>"}, nil},
+		{"show stats 1", []string{"delete <index>"}, nil},
+		{"bogus ", []string{"unknown command"}, nil},
+	}
+	for i, v := range tests {
+		s := c.helpCallback(v.line)
+		for _, x := range v.has {
+			if !strings.Contains(s, x) {
+				t.Errorf("%d: FAIL %q not in help %q", i, x, s)
+			}
+		}
+		for _, x := range v.hasNot {
+			if strings.Contains(s, x) {
+				t.Errorf("%d: FAIL %q in help %q", i, x, s)
+			}
+		}
+	}
+}
+
+func Test_CompletionHelpSuffix(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.SetRoot(testMenu)
+	tests := []struct {
+		line string
+		lc   []string
+	}{
+		{"sh", []string{"show", "shutdown"}},
+		{"sh?", []string{"show", "shutdown"}},
+		{"e?", []string{"exit"}},
+		{"?", []string{"show", "shutdown", "exit"}},
+	}
+	for i, v := range tests {
+		lc := c.completionCallback(v.line)
+		if strings.Join(lc, ",") != strings.Join(v.lc, ",") {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.lc, lc)
+		}
+	}
+}
+
+func Test_HistoryCompletion(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.SetRoot(testMenu)
+	for _, s := range []string{"show stats", "exit", "shutdown now", "show stats"} {
+		c.ln.HistoryAdd(s)
+	}
+	tests := []struct {
+		line string
+		lc   []string
+	}{
+		{"sh", []string{"show", "shutdown", "show stats", "shutdown now"}},
+		{"show s", []string{"show stats"}},
+		{"show stats", nil},
+		{"", []string{"show", "shutdown", "exit"}},
+	}
+	c.SetHistoryCompletion(true)
+	for i, v := range tests {
+		lc := c.completionCallback(v.line)
+		if strings.Join(lc, ",") != strings.Join(v.lc, ",") {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.lc, lc)
+		}
+	}
+	// history completions are labelled
+	c.completionCallback("sh")
+	if c.completionLabel("show") != nil || c.completionLabel("show stats") == nil {
+		t.Errorf("FAIL history completions not labelled")
+	}
+	var out bytes.Buffer
+	line, _ := c.ln.Edit(NewByteSource([]byte("sh\t\t\t\r")), &out, "> ", "")
+	if line != "show stats" || !strings.Contains(out.String(), "(history)") {
+		t.Errorf("FAIL expected (show stats) != actual (%q) %q", line, out.String())
+	}
+	c.SetHistoryCompletion(false)
+	if lc := c.completionCallback("show s"); len(lc) != 0 {
+		t.Errorf("FAIL history completions when disabled (%q)", lc)
+	}
+}
+
+func Test_CompletionSpace(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.SetRoot(Menu{
+		{"show", Menu{
+			{"status", testLeaf},
+			{"stats", testLeaf},
+			{"version", testLeaf},
+		}, "show menu"},
+		{"exit", testLeaf},
+	})
+	c.SetCompletionPadding(true)
+	tests := []struct {
+		line string
+		lc   []string
+	}{
+		{"e", []string{"exit "}},
+		{"exit", []string{"exit "}},
+		{"sho", []string{"show "}},
+		{"show ", []string{"show status", "show stats", "show version"}},
+		{"show st", []string{"show status", "show stats"}},
+		{"show v", []string{"show version "}},
+		{"x", nil},
+	}
+	c.SetCompletionSpace(true)
+	for i, v := range tests {
+		lc := c.completionCallback(v.line)
+		if strings.Join(lc, ",") != strings.Join(v.lc, ",") {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.lc, lc)
+		}
+	}
+	c.SetCompletionSpace(false)
+	if lc := c.completionCallback("sho"); strings.Join(lc, ",") != "show" {
+		t.Errorf("FAIL space added when disabled (%q)", lc)
+	}
+}
+
+func Test_ArgHints(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.SetRoot(Menu{
+		{"history", testLeaf, HistoryHelp},
+		{"set", Menu{
+			{"speed", testLeaf, []Help{{"<rate> [duplex]", "set the link speed"}}},
+		}, "set menu"},
+		{"exit", testLeaf},
+		{"secret", testLeaf, NoHistory},
+		{"ping", testLeaf, NoHistory, []Help{{"<host>", "host to ping"}}},
+	})
+	c.SetArgHints(true)
+	tests := []struct {
+		line string
+		hint string
+	}{
+		{"history ", "<index>|delete|clear"},
+		{"hist ", "<index>|delete|clear"},
+		{"history del ", "<index>"},
+		{"history 3 ", ""},
+		{"history", ""},
+		{"set sp ", "<rate>"},
+		{"set sp 100 ", "[duplex]"},
+		{"set ", ""},
+		{"exit ", ""},
+		{"bogus ", ""},
+		{"secret ", ""},
+		{"ping ", "<host>"},
 	}
-	for i := range a {
-		if a[i][0] != b[i][0] {
-			return false
+	for i, v := range tests {
+		hint := ""
+		if h := c.ln.hintsCallback(v.line); h != nil {
+			hint = h.Hint
 		}
-		if a[i][1] != b[i][1] {
-			return false
+		if hint != v.hint {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.hint, hint)
 		}
 	}
-	return true
+	c.SetArgHints(false)
+	if c.ln.hintsCallback != nil {
+		t.Errorf("FAIL hints callback not cleared")
+	}
 }
 
-func Test_SplitIndex(t *testing.T) {
+func Test_SubmenuHelp(t *testing.T) {
+	user := &testUser{}
+	c := NewCLI(user)
+	c.SetRoot(Menu{
+		{"set", Menu{
+			{"speed", testLeaf},
+			{"duplex", testLeaf},
+		}, "set menu"},
+		{"exit", testLeaf},
+	})
+	line := c.parseCmdline("set")
+	if line != "set" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "set", line)
+	}
+	out := user.out.String()
+	if strings.Contains(out, "additional input needed") {
+		t.Errorf("FAIL unexpected message: %q", out)
+	}
+	for _, s := range []string{"speed", "duplex"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("FAIL command %q not listed: %q", s, out)
+		}
+	}
+	if strings.Contains(out, "exit") {
+		t.Errorf("FAIL root command listed: %q", out)
+	}
+	if c.result == nil {
+		t.Errorf("FAIL incomplete command has no error result")
+	}
+}
+
+func Test_DryRun(t *testing.T) {
+	user := &testUser{}
+	c := NewCLI(user)
+	if !c.Apply("change") || user.out.String() != "" {
+		t.Errorf("FAIL action not applied")
+	}
+	c.SetDryRun(true)
+	if c.Apply("change") || user.out.String() != "dry-run: change\n" {
+		t.Errorf("FAIL action applied in dry-run mode: %q", user.out.String())
+	}
+	// state changing leaves must call Apply in dry-run mode
+	c.SetRoot(Menu{
+		{"set", Leaf{"set", func(c *CLI, args []string) { c.Apply("set") }}, StateChanging},
+		{"clear", testLeaf, StateChanging},
+		{"bad", Leaf{"bad", func(c *CLI, args []string) { c.SetResult(errors.New("bad")) }}, StateChanging},
+		{"show", testLeaf},
+	})
 	tests := []struct {
-		s string
-		r [][2]int
+		dryRun bool
+		line   string
+		err    error
 	}{
-		{"aaa bb  ccccc      ddddd", [][2]int{{0, 3}, {4, 6}, {8, 13}, {19, 24}}},
-		{"", [][2]int{}},
-		{"a", [][2]int{{0, 1}}},
+		{true, "set", nil},
+		{true, "clear", ErrNoDryRun},
+		{true, "show", nil},
+		{false, "clear", nil},
 	}
 	for i, v := range tests {
-		r := splitIndex(v.s)
-		if !indexCompare(r, v.r) {
-			t.Errorf("%d: FAIL expected (%v) != actual (%v)", i, v.r, r)
+		c.SetDryRun(v.dryRun)
+		c.parseCmdline(v.line)
+		if c.result != v.err {
+			t.Errorf("%d: FAIL expected (%v) != actual (%v)", i, v.err, c.result)
 		}
 	}
+	c.SetDryRun(true)
+	if c.parseCmdline("bad"); c.result == nil || c.result == ErrNoDryRun {
+		t.Errorf("FAIL expected (bad) != actual (%v)", c.result)
+	}
+}
+
+func Test_WalkFind(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.SetRoot(Menu{
+		{"show", Menu{{"stats", testLeaf}}, "show functions"},
+		{"exit", testLeaf},
+	})
+	var paths []string
+	c.Walk(func(path []string, item MenuItem) {
+		paths = append(paths, strings.Join(path, " "))
+	})
+	if strings.Join(paths, ",") != "show,show stats,exit" {
+		t.Errorf("FAIL bad walk %v", paths)
+	}
+	item, err := c.Find([]string{"show", "stats"})
+	if err != nil || item[0].(string) != "stats" {
+		t.Errorf("FAIL find (%v, %v)", item, err)
+	}
+	for _, p := range [][]string{{}, {"sh"}, {"exit", "now"}, {"show", "bogus"}} {
+		if _, err := c.Find(p); err == nil {
+			t.Errorf("FAIL found %v", p)
+		}
+	}
+}
+
+func Test_StartStopHooks(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.SetRoot(testMenu)
+	c.ln.scanner = bufio.NewScanner(strings.NewReader("show\nexit\n"))
+	var calls []string
+	c.OnStart(func(c *CLI) { calls = append(calls, "start1") })
+	c.OnStart(func(c *CLI) { calls = append(calls, "start2") })
+	c.OnStop(func(c *CLI) { calls = append(calls, "stop1") })
+	c.OnStop(func(c *CLI) { calls = append(calls, "stop2") })
+	for c.Running() {
+		c.Run()
+	}
+	c.Close()
+	s := strings.Join(calls, ",")
+	if s != "start1,start2,stop2,stop1" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "start1,start2,stop2,stop1", s)
+	}
+
+	// stop functions aren't called if the cli didn't start
+	c = NewCLI(&testUser{})
+	c.OnStop(func(c *CLI) { t.Errorf("FAIL stop function called") })
+	c.Close()
+	if c.Running() {
+		t.Errorf("FAIL closed cli is still running")
+	}
+}
+
+func Test_PreRead(t *testing.T) {
+	var ran []string
+	user := &testUser{}
+	c := NewCLI(user)
+	c.SetRoot(Menu{
+		{"show", Leaf{"show", func(c *CLI, args []string) { ran = append(ran, args[0]) }}},
+	})
+	c.SetPrompt("> ")
+	c.ln.scanner = bufio.NewScanner(strings.NewReader("show c\n"))
+	queue := []string{"show a", "show b"}
+	cancel := 1
+	c.SetPreRead(func(c *CLI, prompt string) (string, ReadAction) {
+		if len(queue) != 0 {
+			line := queue[0]
+			queue = queue[1:]
+			return line, ReadRun
+		}
+		if cancel > 0 {
+			cancel--
+			return "", ReadCancel
+		}
+		return "", ReadContinue
+	})
+	for c.Running() {
+		c.Run()
+	}
+	if s := strings.Join(ran, ","); s != "a,b,c" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "a,b,c", s)
+	}
+	if out := user.out.String(); out != "> show a\n> show b\n" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "> show a\n> show b\n", out)
+	}
+}
+
+func Test_HelpChar(t *testing.T) {
+	var got []string
+	show := Leaf{"show things", func(c *CLI, args []string) { got = args }}
+	tests := []struct {
+		hc   rune
+		line string
+		next string
+		args string
+		help bool
+	}{
+		{'?', "show?", "show", "", true},
+		{'?', "show a?", "show a", "", true},
+		{'?', "show ¿", "", "¿", false},
+		{'¿', "show¿", "show", "", true},
+		{'¿', "show a ¿", "show a ", "", true},
+		{'¿', "show ?", "", "?", false},
+		{0, "show a?", "", "a?", false},
+		{'?', "show a\\?", "", "a?", false},
+		{'?', "show a\\?b c?\\?", "", "a?b,c??", false},
+		{'¿', "show \\¿", "", "¿", false},
+		{0, "show a\\?", "", "a\\?", false},
+	}
+	for i, v := range tests {
+		user := &testUser{}
+		c := NewCLI(user)
+		c.SetRoot(Menu{{"show", show}})
+		c.SetHelpChar(v.hc)
+		got = nil
+		next := c.parseCmdline(v.line)
+		args := strings.Join(got, ",")
+		help := user.out.Len() != 0
+		if next != v.next || args != v.args || help != v.help {
+			t.Errorf("%d: FAIL expected (%q, %q, %v) != actual (%q, %q, %v)", i, v.next, v.args, v.help, next, args, help)
+		}
+	}
+}
+
+func Test_CommentLines(t *testing.T) {
+	ran := 0
+	user := &testUser{}
+	c := NewCLI(user)
+	c.SetRoot(Menu{
+		{"show", Leaf{"show", func(c *CLI, args []string) { ran++ }}},
+	})
+	c.RecordStart("m")
+	for _, line := range []string{"# check the links", "show", "  #show"} {
+		c.parseCmdline(line)
+	}
+	c.macro.recording = ""
+	if ran != 1 || user.out.Len() != 0 {
+		t.Errorf("FAIL comment was run: %d %q", ran, user.out.String())
+	}
+	h := strings.Join(c.ln.historyList(), ",")
+	if h != "# check the links,show,#show" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "# check the links,show,#show", h)
+	}
+	m := strings.Join(c.macro.lines, ",")
+	if m != h {
+		t.Errorf("FAIL expected (%q) != actual (%q)", h, m)
+	}
+	// comments disabled
+	c.SetCommentPrefix("")
+	c.parseCmdline("# show")
+	if !strings.Contains(user.out.String(), "unknown command") {
+		t.Errorf("FAIL comment not run as a command")
+	}
+	// the general help shows the comment prefix
+	tests := []struct {
+		prefix string
+		help   string
+	}{
+		{"#", "# text"},
+		{"//", "// text"},
+		{"", ""},
+	}
+	for i, v := range tests {
+		user.out.Reset()
+		c.SetCommentPrefix(v.prefix)
+		c.GeneralHelp()
+		out := user.out.String()
+		if strings.Contains(out, "a comment") != (v.help != "") || !strings.Contains(out, v.help) {
+			t.Errorf("%d: FAIL expected (%q) in (%q)", i, v.help, out)
+		}
+	}
+}
+
+func Test_IdleTimeout(t *testing.T) {
+	tests := []struct {
+		action IdleAction
+		out    string
+		err    error
+	}{
+		{IdleWarn, "idle timeout\n", nil},
+		{IdleLock, "session locked\n", nil},
+		{IdleExit, "idle timeout, exiting\n", ErrIdle},
+	}
+	for i, v := range tests {
+		r, w := io.Pipe()
+		var ticks int32
+		menu := Menu{
+			{"tick", Leaf{"tick", func(c *CLI, args []string) { atomic.AddInt32(&ticks, 1) }}},
+		}
+		user := &testUser{}
+		c := NewCLI(user)
+		c.SetRoot(menu)
+		c.ln.SetTerminal(&testTerm{Reader: r})
+		c.SetUnlock(func(c *CLI) error {
+			c.Exit()
+			return nil
+		})
+		c.SetIdleTimeout(100*time.Millisecond, v.action)
+		// scheduled jobs interrupt the line being edited but aren't input
+		id, err := c.Schedule("tick", 0, 10*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stop := time.AfterFunc(5*time.Second, c.Shutdown)
+		for c.Running() {
+			c.Run()
+			if strings.Contains(user.out.String(), v.out) {
+				c.Exit()
+			}
+		}
+		stop.Stop()
+		c.CancelJob(id)
+		w.Close()
+		if !strings.Contains(user.out.String(), v.out) || c.Err() != v.err || atomic.LoadInt32(&ticks) == 0 {
+			t.Errorf("%d: FAIL expected (%q, %v) != actual (%q, %v)", i, v.out, v.err, user.out.String(), c.Err())
+		}
+	}
+}
+
+func Test_ReadLineValid(t *testing.T) {
+	user := &testUser{}
+	c := NewCLI(user)
+	// the rejected line is presented for editing, ctrl-U clears it
+	c.ln.SetTerminal(&testTerm{Reader: strings.NewReader("12a\r\x1512\r")})
+	s, err := c.ReadLineValid("n: ", "", func(s string) error {
+		if strings.Trim(s, "0123456789") != "" {
+			return errors.New("not a number")
+		}
+		return nil
+	})
+	if s != "12" || err != nil || strings.Count(user.out.String(), "not a number\n") != 1 {
+		t.Errorf("FAIL expected (12) != actual (%q, %v) %q", s, err, user.out.String())
+	}
+	// the user quits
+	c.ln.SetTerminal(&testTerm{Reader: strings.NewReader("x\r\x03")})
+	s, err = c.ReadLineValid("n: ", "", func(s string) error { return errors.New("bad") })
+	if s != "" || err == nil {
+		t.Errorf("FAIL expected an error != actual (%q, %v)", s, err)
+	}
+}
+
+func Test_ReadInt(t *testing.T) {
+	tests := []struct {
+		in  string
+		val int
+		bad int
+	}{
+		{"\r", 10, 0},
+		{"\x15200\r\x150x20\r", 32, 1},
+		{"\x15-1\r\x15x\r\x1599\r", 99, 2},
+	}
+	for i, v := range tests {
+		user := &testUser{}
+		c := NewCLI(user)
+		c.ln.SetTerminal(&testTerm{Reader: strings.NewReader(v.in)})
+		val, err := c.ReadInt("n: ", 10, [2]int{0, 100})
+		bad := strings.Count(user.out.String(), "\n")
+		if val != v.val || err != nil || bad != v.bad {
+			t.Errorf("%d: FAIL expected (%d, %d) != actual (%d, %d, %v)", i, v.val, v.bad, val, bad, err)
+		}
+	}
+}
+
+func Test_ReadSecret(t *testing.T) {
+	tests := []struct {
+		in      string
+		confirm bool
+		s       string
+		out     string
+	}{
+		{"pw\r", false, "pw", ""},
+		{"pw\rpw\r", true, "pw", ""},
+		{"pw\rpx\rqq\rqq\r", true, "qq", "entries do not match\n"},
+	}
+	for i, v := range tests {
+		user := &testUser{}
+		c := NewCLI(user)
+		term := &testTerm{Reader: strings.NewReader(v.in)}
+		c.ln.SetTerminal(term)
+		s, err := c.ReadSecret("password: ", v.confirm)
+		if s != v.s || err != nil || user.out.String() != v.out {
+			t.Errorf("%d: FAIL expected (%q, %q) != actual (%q, %q, %v)", i, v.s, v.out, s, user.out.String(), err)
+		}
+		// the secret isn't displayed or added to history
+		if strings.Contains(term.out.String(), v.s) || len(c.ln.history) != 0 {
+			t.Errorf("%d: FAIL secret displayed or in history", i)
+		}
+	}
+}
+
+func Test_Sleep(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	c := NewCLI(&testUser{})
+	c.ln.SetTerminal(&testTerm{Reader: r})
+	if !c.Sleep(10 * time.Millisecond) {
+		t.Errorf("FAIL sleep interrupted")
+	}
+	// ctrl-C cuts the sleep short
+	c.ln.SetTerminal(&testTerm{Reader: strings.NewReader("\x03")})
+	start := time.Now()
+	if c.Sleep(5*time.Second) || time.Since(start) > time.Second {
+		t.Errorf("FAIL sleep not interrupted")
+	}
+}
+
+func Test_Banner(t *testing.T) {
+	user := &testUser{}
+	c := NewCLI(user)
+	c.SetRoot(Menu{{"show", Leaf{"show", func(c *CLI, args []string) { c.Put("shown\n") }}}})
+	c.ln.SetTerminal(&testTerm{Reader: strings.NewReader("show\rshow\r")})
+	c.SetBanner("banner")
+	motd := 0
+	c.SetMOTD(func() string {
+		motd++
+		return "motd\n"
+	})
+	// the banner and motd are shown once, on the first run
+	for c.Running() {
+		c.Run()
+	}
+	expected := "banner\nmotd\nshown\nshown\n"
+	if out := user.out.String(); out != expected || motd != 1 {
+		t.Errorf("FAIL expected (%q, 1) != actual (%q, %d)", expected, out, motd)
+	}
 }
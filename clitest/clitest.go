@@ -0,0 +1,84 @@
+//-----------------------------------------------------------------------------
+/*
+
+CLI Test Helpers
+
+Assertions for regression testing the command definitions of a menu tree
+without a terminal. Eg.
+
+	clitest.Completes(t, menuRoot, "sh", "show", "shutdown")
+	clitest.Dispatches(t, menuRoot, "sh st eth0", "show status", "eth0")
+	clitest.Rejects(t, menuRoot, "bogus")
+
+*/
+//-----------------------------------------------------------------------------
+
+package clitest
+
+import (
+	"strings"
+	"testing"
+
+	cli "github.com/deadsy/go-cli"
+)
+
+//-----------------------------------------------------------------------------
+
+// user collects the output of the CLI.
+type user struct {
+	out strings.Builder
+}
+
+func (u *user) Put(s string) {
+	u.out.WriteString(s)
+}
+
+// New returns a CLI for a menu tree with the output discarded.
+func New(root cli.Menu) *cli.CLI {
+	c := cli.NewCLI(&user{})
+	c.SetRoot(root)
+	return c
+}
+
+//-----------------------------------------------------------------------------
+
+// Completes asserts that the tab completions of a command line are the
+// expected lines. Trailing padding is ignored.
+func Completes(t testing.TB, root cli.Menu, line string, expected ...string) {
+	t.Helper()
+	lc := New(root).Complete(line)
+	for i := range lc {
+		lc[i] = strings.TrimRight(lc[i], " ")
+	}
+	if strings.Join(lc, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("%q: FAIL expected completions (%q) != actual (%q)", line, expected, lc)
+	}
+}
+
+// Dispatches asserts that a command line dispatches to the leaf function
+// with a menu path (Eg. "show status") and arguments.
+func Dispatches(t testing.TB, root cli.Menu, line, path string, args ...string) {
+	t.Helper()
+	p, a, err := New(root).Resolve(line)
+	if err != nil {
+		t.Errorf("%q: FAIL %s", line, err)
+		return
+	}
+	if strings.Join(p, " ") != path {
+		t.Errorf("%q: FAIL expected leaf (%s) != actual (%s)", line, path, strings.Join(p, " "))
+	}
+	if strings.Join(a, " ") != strings.Join(args, " ") || len(a) != len(args) {
+		t.Errorf("%q: FAIL expected arguments (%q) != actual (%q)", line, args, a)
+	}
+}
+
+// Rejects asserts that a command line doesn't dispatch to a leaf function.
+func Rejects(t testing.TB, root cli.Menu, line string) {
+	t.Helper()
+	p, _, err := New(root).Resolve(line)
+	if err == nil {
+		t.Errorf("%q: FAIL dispatches to (%s)", line, strings.Join(p, " "))
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,59 @@
+package clitest
+
+import (
+	"fmt"
+	"testing"
+
+	cli "github.com/deadsy/go-cli"
+)
+
+var testLeaf = cli.Leaf{
+	Descr: "test leaf",
+	F:     func(c *cli.CLI, args []string) {},
+}
+
+var testMenu = cli.Menu{
+	{"show", cli.Menu{
+		{"status", testLeaf},
+		{"stats", testLeaf},
+		{"version", testLeaf},
+	}, "show menu"},
+	{"shutdown", testLeaf},
+	{"exit", testLeaf},
+}
+
+// fakeT records test failures.
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func Test_Pass(t *testing.T) {
+	Completes(t, testMenu, "sh", "show", "shutdown")
+	Completes(t, testMenu, "show st", "show status", "show stats")
+	Completes(t, testMenu, "show v", "show version")
+	Dispatches(t, testMenu, "sho v", "show version")
+	Dispatches(t, testMenu, "show statu eth0 1", "show status", "eth0", "1")
+	Dispatches(t, testMenu, "shu now", "shutdown", "now")
+	Rejects(t, testMenu, "bogus")
+	Rejects(t, testMenu, "sh")
+	Rejects(t, testMenu, "show")
+}
+
+func Test_Fail(t *testing.T) {
+	ft := &fakeT{}
+	Completes(ft, testMenu, "sh", "show")
+	Dispatches(ft, testMenu, "show v", "show stats")
+	Dispatches(ft, testMenu, "show v", "show version", "x")
+	Dispatches(ft, testMenu, "bogus", "exit")
+	Rejects(ft, testMenu, "exit")
+	if len(ft.errors) != 5 {
+		t.Errorf("FAIL expected (5) != actual (%d) failures: %q", len(ft.errors), ft.errors)
+	}
+}
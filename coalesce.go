@@ -0,0 +1,96 @@
+//-----------------------------------------------------------------------------
+/*
+
+Coalesced Output
+
+Bursts of small Put calls from a chatty leaf function (Eg. per-packet logging)
+are buffered and written in batches at a maximum rate, so the terminal isn't
+flooded with small writes.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+
+// coalescer buffers output and writes it at a maximum rate.
+type coalescer struct {
+	sync.Mutex
+	put      func(s string)  // output function
+	interval time.Duration   // minimum interval between writes
+	buf      strings.Builder // buffered output
+	last     time.Time       // time of the last write
+	timer    *time.Timer     // pending write of buffered output
+}
+
+func newCoalescer(put func(s string), interval time.Duration) *coalescer {
+	return &coalescer{put: put, interval: interval}
+}
+
+// Write the buffered output. The lock must be held.
+func (o *coalescer) flushLocked() {
+	if o.timer != nil {
+		o.timer.Stop()
+		o.timer = nil
+	}
+	if o.buf.Len() == 0 {
+		return
+	}
+	o.put(o.buf.String())
+	o.buf.Reset()
+	o.last = time.Now()
+}
+
+// flush writes the buffered output.
+func (o *coalescer) flush() {
+	o.Lock()
+	defer o.Unlock()
+	o.flushLocked()
+}
+
+// write buffers output, it is written once the interval has elapsed.
+func (o *coalescer) write(s string) {
+	o.Lock()
+	defer o.Unlock()
+	o.buf.WriteString(s)
+	wait := o.interval - time.Since(o.last)
+	if wait <= 0 {
+		o.flushLocked()
+		return
+	}
+	if o.timer == nil {
+		o.timer = time.AfterFunc(wait, o.flush)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// SetPutInterval sets the minimum interval between writes of leaf function
+// output. Output from Put calls within the interval is coalesced into a
+// single write. An interval of 0 disables coalescing.
+func (c *CLI) SetPutInterval(interval time.Duration) {
+	c.putInterval = interval
+}
+
+// Call a leaf function with coalesced output.
+func (c *CLI) callLeaf(leaf func(c *CLI, args []string), args []string) {
+	if c.putInterval <= 0 {
+		leaf(c, args)
+		return
+	}
+	c.out = newCoalescer(c.User.Put, c.putInterval)
+	defer func() {
+		c.out.flush()
+		c.out = nil
+	}()
+	leaf(c, args)
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Coalescer(t *testing.T) {
+	var writes []string
+	o := newCoalescer(func(s string) { writes = append(writes, s) }, time.Hour)
+	o.write("a")
+	o.write("b")
+	o.write("c")
+	o.flush()
+	o.flush()
+	expected := "a,bc"
+	if strings.Join(writes, ",") != expected {
+		t.Errorf("FAIL expected (%v) != actual (%v)", expected, writes)
+	}
+
+	writes = nil
+	o = newCoalescer(func(s string) { writes = append(writes, s) }, time.Millisecond)
+	o.write("a")
+	o.write("b")
+	time.Sleep(20 * time.Millisecond)
+	o.Lock()
+	actual := strings.Join(writes, ",")
+	o.Unlock()
+	if actual != "a,b" {
+		t.Errorf("FAIL expected (a,b) != actual (%v)", actual)
+	}
+}
+
+func Test_PutInterval(t *testing.T) {
+	user := &testUser{}
+	menu := Menu{
+		{"log", Leaf{"log", func(c *CLI, args []string) {
+			for i := 0; i < 100; i++ {
+				c.Put(".")
+			}
+		}}},
+	}
+	c := NewCLI(user)
+	c.SetRoot(menu)
+	c.SetPutInterval(time.Hour)
+	c.parseCmdline("log")
+	if user.out.String() != strings.Repeat(".", 100) {
+		t.Errorf("FAIL output not flushed (%q)", user.out.String())
+	}
+	if c.out != nil {
+		t.Errorf("FAIL coalescer not removed")
+	}
+}
@@ -0,0 +1,130 @@
+//-----------------------------------------------------------------------------
+/*
+
+Standard Commands
+
+Leaf functions for the commands most applications have in their root menu.
+AddStandardCommands installs exit, help and history with optional alias,
+set and source commands.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+
+// StandardCommands selects the optional standard commands.
+type StandardCommands int
+
+// Optional standard commands.
+const (
+	StdAlias  StandardCommands = 1 << iota // alias: set a command alias
+	StdSet                                 // set: the preferences menu
+	StdSource                              // source: run a script file
+)
+
+// CmdExit is a leaf function to exit the CLI.
+var CmdExit = Leaf{
+	Descr: "exit application",
+	F: func(c *CLI, args []string) {
+		c.Exit()
+	},
+}
+
+// CmdHelp is a leaf function to display the general help,
+// or the help for a command path.
+var CmdHelp = Leaf{
+	Descr: "general help",
+	F: func(c *CLI, args []string) {
+		if len(args) == 0 {
+			c.GeneralHelp()
+			return
+		}
+		c.Page(c.helpCallback(strings.Join(args, " ") + " "))
+	},
+}
+
+// HelpHelp is help for the help command.
+var HelpHelp = []Help{
+	{"<cr>", "general help"},
+	{"<command>", "help for a command - Eg. help show"},
+}
+
+// CmdHistory is a leaf function to display and recall the command history.
+var CmdHistory = Leaf{
+	Descr: "command history",
+	F: func(c *CLI, args []string) {
+		c.SetLine(c.DisplayHistory(args))
+	},
+}
+
+// CmdSource is a leaf function to run a script file.
+var CmdSource = Leaf{
+	Descr: "run a script file",
+	F: func(c *CLI, args []string) {
+		var err error
+		if len(args) == 0 {
+			err = errors.New("bad number of arguments")
+		} else {
+			err = c.RunScriptFile(args[0], args[1:]...)
+		}
+		if err != nil {
+			c.Put(fmt.Sprintf("%s\n", err))
+		}
+		c.SetResult(err)
+	},
+}
+
+// SourceHelp is help for the source command.
+var SourceHelp = []Help{
+	{"<file> [args]", "run the commands of a script file, the arguments are $1..$9"},
+}
+
+// AddStandardCommands adds exit, help and history commands and the selected
+// optional commands to the root menu. Commands with a name already in the
+// root menu are not added. The root menu should be set before calling this,
+// the merged menu is sorted by name.
+func (c *CLI) AddStandardCommands(opts StandardCommands) {
+	cmds := Menu{
+		{"exit", CmdExit},
+		{"help", CmdHelp, HelpHelp},
+		{"history", CmdHistory, HistoryHelp, []Example{
+			{"history -1", "recall the previous command"},
+			{"history delete 0", "delete the latest entry"},
+		}},
+	}
+	if opts&StdAlias != 0 {
+		cmds = append(cmds, MenuItem{"alias", cmdPrefsAlias, aliasHelp})
+	}
+	if opts&StdSet != 0 {
+		cmds = append(cmds, MenuItem{"set", PrefsMenu, "terminal and user preferences"})
+	}
+	if opts&StdSource != 0 {
+		cmds = append(cmds, MenuItem{"source", CmdSource, SourceHelp})
+	}
+	names := make(map[string]bool)
+	for _, item := range c.root {
+		names[item[0].(string)] = true
+	}
+	root := make(Menu, 0, len(c.root)+len(cmds))
+	root = append(root, c.root...)
+	for _, item := range cmds {
+		if !names[item[0].(string)] {
+			root = append(root, item)
+		}
+	}
+	sort.SliceStable(root, func(i, j int) bool {
+		return root[i][0].(string) < root[j][0].(string)
+	})
+	c.root = root
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_StandardCommands(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.SetRoot(Menu{
+		{"show", testLeaf},
+		{"help", testLeaf},
+	})
+	c.AddStandardCommands(StdSet | StdSource)
+	names := strings.Join(menuNames(c.root), ",")
+	if names != "exit,help,history,set,show,source" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "exit,help,history,set,show,source", names)
+	}
+	// the application help command is kept
+	if c.root[1][1].(Leaf).Descr != testLeaf.Descr {
+		t.Errorf("FAIL application command replaced")
+	}
+	c.parseCmdline("exit")
+	if c.Running() {
+		t.Errorf("FAIL exit command didn't stop the cli")
+	}
+}
+
+func Test_SourceCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "script.txt")
+	ioutil.WriteFile(fname, []byte("show $1\nshow $2\n"), 0644)
+
+	var ran []string
+	user := &testUser{}
+	c := NewCLI(user)
+	c.SetRoot(Menu{
+		{"show", Leaf{"show", func(c *CLI, args []string) { ran = append(ran, args...) }}},
+	})
+	c.AddStandardCommands(StdSource)
+	if err := c.Exec([]string{"source", fname, "a", "b"}); err != nil {
+		t.Errorf("FAIL source error %v", err)
+	}
+	if strings.Join(ran, ",") != "a,b" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "a,b", ran)
+	}
+	if err := c.Exec([]string{"source", filepath.Join(dir, "missing.txt")}); err == nil {
+		t.Errorf("FAIL missing script file has no error")
+	}
+	if err := c.Exec([]string{"source"}); err == nil || !strings.Contains(user.out.String(), "bad number of arguments") {
+		t.Errorf("FAIL missing argument not reported")
+	}
+}
+
+func Test_HelpCommand(t *testing.T) {
+	user := &testUser{}
+	c := NewCLI(user)
+	c.SetRoot(Menu{
+		{"set", Menu{
+			{"speed", testLeaf, []Help{{"<rate>", "set the link speed"}}},
+		}, "set menu"},
+	})
+	c.AddStandardCommands(0)
+	tests := []struct {
+		line string
+		out  string
+	}{
+		{"help set", "speed"},
+		{"help set speed", "set the link speed"},
+		{"help bogus", "unknown command"},
+		{"help", "display command help"},
+	}
+	for i, v := range tests {
+		user.out.Reset()
+		c.parseCmdline(v.line)
+		if !strings.Contains(user.out.String(), v.out) {
+			t.Errorf("%d: FAIL expected (%q) in (%q)", i, v.out, user.out.String())
+		}
+	}
+	// help completes a command path
+	lc := c.completionCallback("help set s")
+	if strings.Join(lc, ",") != "help set speed" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "help set speed", lc)
+	}
+}
@@ -0,0 +1,147 @@
+//-----------------------------------------------------------------------------
+/*
+
+One-shot Execution and Shell Completion
+
+An application can run a single command from its command line arguments.
+Completion scripts for bash, zsh and fish are generated from the menu tree
+so the shell can complete those arguments.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+
+// Exec runs a single command given as a list of arguments (Eg. os.Args[1:])
+// and returns the result of the command. The arguments are passed to the leaf
+// function as given, they aren't split, the help character is literal and the
+// command isn't added to history.
+func (c *CLI) Exec(args []string) error {
+	item, args, err := c.resolve(args)
+	if err != nil {
+		c.Put(c.colorString(err.Error(), theme.Error, true) + "\n")
+		c.result = err
+		return err
+	}
+	c.result = nil
+	c.runLeaf(item, args)
+	return c.result
+}
+
+//-----------------------------------------------------------------------------
+
+// completion words for a command path
+type compWords struct {
+	path  string   // command path
+	words []string // completion words
+	descr []string // word descriptions
+}
+
+// Return the literal keywords from leaf function help. Eg. "on|off".
+func helpWords(help []Help) ([]string, []string) {
+	var words, descr []string
+	for _, h := range help {
+		for _, w := range strings.Split(strings.Fields(h.Parm + " ")[0], "|") {
+			if w == "" || strings.ContainsAny(w, "<>[]*") {
+				continue
+			}
+			words = append(words, w)
+			descr = append(descr, h.Descr)
+		}
+	}
+	return words, descr
+}
+
+// Return the completion words for each command path of the menu tree.
+func (c *CLI) completionWords() []*compWords {
+	cw := []*compWords{{}}
+	idx := map[string]*compWords{"": cw[0]}
+	c.Walk(func(path []string, item MenuItem) {
+		parent := idx[strings.Join(path[:len(path)-1], " ")]
+		parent.words = append(parent.words, path[len(path)-1])
+		parent.descr = append(parent.descr, itemDescr(item))
+		p := &compWords{path: strings.Join(path, " ")}
+		if _, ok := item[1].(Menu); !ok {
+			p.words, p.descr = helpWords(itemHelp(item))
+		}
+		cw = append(cw, p)
+		idx[p.path] = p
+	})
+	return cw
+}
+
+// Return a name usable as a shell function name.
+func shellName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// bash completion script
+func (c *CLI) bashCompletion(prog string) string {
+	fn := "_" + shellName(prog) + "_complete"
+	s := []string{
+		fmt.Sprintf("%s() {", fn),
+		"\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"",
+		"\tlocal path=\"${COMP_WORDS[*]:1:COMP_CWORD-1}\"",
+		"\tcase \"$path\" in",
+	}
+	for _, p := range c.completionWords() {
+		if len(p.words) == 0 {
+			continue
+		}
+		s = append(s, fmt.Sprintf("\t\"%s\") COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;", p.path, strings.Join(p.words, " ")))
+	}
+	s = append(s, "\tesac", "}", fmt.Sprintf("complete -F %s %s", fn, prog))
+	return strings.Join(s, "\n") + "\n"
+}
+
+// zsh completion script
+func (c *CLI) zshCompletion(prog string) string {
+	return "autoload -U +X bashcompinit && bashcompinit\n" + c.bashCompletion(prog)
+}
+
+// fish completion script
+func (c *CLI) fishCompletion(prog string) string {
+	fn := "__" + shellName(prog) + "_path"
+	s := []string{
+		fmt.Sprintf("function %s", fn),
+		"\tset -l tokens (commandline -opc)",
+		"\tset -e tokens[1]",
+		"\ttest \"$tokens\" = \"$argv\"",
+		"end",
+	}
+	for _, p := range c.completionWords() {
+		for i, w := range p.words {
+			descr := strings.Replace(p.descr[i], "'", "\\'", -1)
+			s = append(s, fmt.Sprintf("complete -c %s -f -n '%s %s' -a '%s' -d '%s'", prog, fn, p.path, w, descr))
+		}
+	}
+	return strings.Join(s, "\n") + "\n"
+}
+
+// CompletionScript returns a shell completion script (bash, zsh or fish)
+// for running the commands of the program in one-shot mode.
+func (c *CLI) CompletionScript(shell, prog string) (string, error) {
+	switch shell {
+	case "bash":
+		return c.bashCompletion(prog), nil
+	case "zsh":
+		return c.zshCompletion(prog), nil
+	case "fish":
+		return c.fishCompletion(prog), nil
+	}
+	return "", fmt.Errorf("unsupported shell \"%s\"", shell)
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_CompletionScript(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.SetRoot(Menu{
+		{"show", Menu{{"stats", testLeaf}}, "show functions"},
+		{"history", testLeaf, HistoryHelp},
+	})
+	tests := []struct {
+		shell string
+		has   []string
+	}{
+		{"bash", []string{
+			`"") COMPREPLY=($(compgen -W "show history" -- "$cur")) ;;`,
+			`"show") COMPREPLY=($(compgen -W "stats" -- "$cur")) ;;`,
+			`"history") COMPREPLY=($(compgen -W "delete clear" -- "$cur")) ;;`,
+			"complete -F _my_app_complete my-app",
+		}},
+		{"zsh", []string{"bashcompinit"}},
+		{"fish", []string{
+			"complete -c my-app -f -n '__my_app_path ' -a 'show' -d 'show functions'",
+			"complete -c my-app -f -n '__my_app_path show' -a 'stats' -d 'test leaf'",
+		}},
+	}
+	for i, v := range tests {
+		s, err := c.CompletionScript(v.shell, "my-app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, x := range v.has {
+			if !strings.Contains(s, x) {
+				t.Errorf("%d: FAIL %q not in script\n%s", i, x, s)
+			}
+		}
+	}
+	if _, err := c.CompletionScript("csh", "my-app"); err == nil {
+		t.Errorf("FAIL unsupported shell")
+	}
+}
+
+func Test_Exec(t *testing.T) {
+	var got []string
+	c := NewCLI(&testUser{})
+	c.SetRoot(Menu{
+		{"fail", Leaf{"fail", func(c *CLI, args []string) { c.SetResult(errors.New("failed")) }}},
+		{"pass", testLeaf},
+		{"set", Leaf{"set", func(c *CLI, args []string) { got = args }}},
+	})
+	if c.Exec([]string{"pass", "1"}) != nil || c.Exec([]string{"fail"}) == nil || c.Exec([]string{"bogus"}) == nil {
+		t.Errorf("FAIL bad exec result")
+	}
+	if c.Exec([]string{"fail"}) == nil || c.result == nil {
+		t.Errorf("FAIL result not set")
+	}
+	// the arguments are passed as given
+	tests := [][]string{
+		{"a b"},
+		{"x?"},
+		{"\"a\"", "# b"},
+	}
+	for i, v := range tests {
+		c.Exec(append([]string{"set"}, v...))
+		if strings.Join(got, ",") != strings.Join(v, ",") {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v, got)
+		}
+	}
+	if len(c.ln.history) != 0 {
+		t.Errorf("FAIL exec added to history")
+	}
+}
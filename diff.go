@@ -0,0 +1,205 @@
+//-----------------------------------------------------------------------------
+/*
+
+Diff Rendering
+
+Line based unified diffs for "show config diff" style commands.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+
+// number of context lines around changes
+const diffContext = 3
+
+// diff operations
+const (
+	diffEqual = iota
+	diffDel
+	diffAdd
+)
+
+// maximum size of the lcs table, larger changes are a delete and add of all the lines
+const diffMaxCells = 1 << 20
+
+type diffOp struct {
+	op   int    // operation
+	line string // line text
+	ai   int    // line index in a
+	bi   int    // line index in b
+}
+
+// Return the line operations to transform a into b (longest common subsequence).
+func diffLines(a, b []string) []diffOp {
+	// the common prefix and suffix are unchanged
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i := 0; i < pre; i++ {
+		ops = append(ops, diffOp{diffEqual, a[i], i, i})
+	}
+	ops = append(ops, diffChanged(a[pre:len(a)-suf], b[pre:len(b)-suf], pre, pre)...)
+	for k := suf; k > 0; k-- {
+		i, j := len(a)-k, len(b)-k
+		ops = append(ops, diffOp{diffEqual, a[i], i, j})
+	}
+	return ops
+}
+
+// Return the line operations to transform the changed lines a into b.
+// ai and bi are the line indices of a and b.
+func diffChanged(a, b []string, ai, bi int) []diffOp {
+	n, m := len(a), len(b)
+	ops := make([]diffOp, 0, n+m)
+	if (n+1)*(m+1) > diffMaxCells {
+		// too large for the lcs table
+		for i := range a {
+			ops = append(ops, diffOp{diffDel, a[i], ai + i, bi})
+		}
+		for j := range b {
+			ops = append(ops, diffOp{diffAdd, b[j], ai + n, bi + j})
+		}
+		return ops
+	}
+	// lcs[i][j] is the lcs length of a[i:] and b[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		if i < n && j < m && a[i] == b[j] {
+			ops = append(ops, diffOp{diffEqual, a[i], ai + i, bi + j})
+			i++
+			j++
+		} else if j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]) {
+			ops = append(ops, diffOp{diffDel, a[i], ai + i, bi + j})
+			i++
+		} else {
+			ops = append(ops, diffOp{diffAdd, b[j], ai + i, bi + j})
+			j++
+		}
+	}
+	return ops
+}
+
+// Split a string into lines.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Return a hunk range string.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// DiffString returns a unified diff of two strings, with colors from the theme.
+// Returns an empty string if the strings are the same.
+func DiffString(a, b string) string {
+	return diffString(a, b, colorEnabled())
+}
+
+// Return a unified diff of two strings, with colors if enabled.
+func diffString(a, b string, color bool) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+	out := make([]string, 0, len(ops))
+	for k := 0; k < len(ops); {
+		// find the next change
+		if ops[k].op == diffEqual {
+			k++
+			continue
+		}
+		// extend the hunk while changes are within the context distance
+		start := k - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := k
+		for end < len(ops) {
+			if ops[end].op != diffEqual {
+				end++
+				continue
+			}
+			// count the equal lines to the next change
+			e := end
+			for e < len(ops) && ops[e].op == diffEqual {
+				e++
+			}
+			if e == len(ops) || e-end > 2*diffContext {
+				end += diffContext
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = e
+		}
+		// hunk header
+		na, nb := 0, 0
+		for _, op := range ops[start:end] {
+			if op.op != diffAdd {
+				na++
+			}
+			if op.op != diffDel {
+				nb++
+			}
+		}
+		if len(out) == 0 {
+			out = append(out, "--- a", "+++ b")
+		}
+		hdr := fmt.Sprintf("@@ -%s +%s @@", hunkRange(ops[start].ai, na), hunkRange(ops[start].bi, nb))
+		out = append(out, styleString(hdr, theme.DiffHunk, false, color))
+		// hunk lines
+		for _, op := range ops[start:end] {
+			switch op.op {
+			case diffEqual:
+				out = append(out, " "+op.line)
+			case diffDel:
+				out = append(out, styleString("-"+op.line, theme.DiffDel, false, color))
+			case diffAdd:
+				out = append(out, styleString("+"+op.line, theme.DiffAdd, false, color))
+			}
+		}
+		k = end
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+//-----------------------------------------------------------------------------
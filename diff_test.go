@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func Test_DiffString(t *testing.T) {
+	if s, ok := os.LookupEnv("NO_COLOR"); ok {
+		defer os.Setenv("NO_COLOR", s)
+	} else {
+		defer os.Unsetenv("NO_COLOR")
+	}
+	os.Setenv("NO_COLOR", "1")
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	b := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	expected := "" +
+		"--- a\n" +
+		"+++ b\n" +
+		"@@ -1,5 +1,5 @@\n" +
+		" a\n" +
+		"-b\n" +
+		"+B\n" +
+		" c\n" +
+		" d\n" +
+		" e\n" +
+		"@@ -8,3 +8,4 @@\n" +
+		" h\n" +
+		" i\n" +
+		" j\n" +
+		"+k\n"
+	s := DiffString(a, b)
+	if s != expected {
+		t.Errorf("FAIL expected\n%s\nactual\n%s", expected, s)
+	}
+	if DiffString(a, a) != "" {
+		t.Errorf("FAIL diff of equal strings")
+	}
+	expected = "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n"
+	s = DiffString("", "x")
+	if s != expected {
+		t.Errorf("FAIL expected\n%s\nactual\n%s", expected, s)
+	}
+}
+
+func Test_DiffLarge(t *testing.T) {
+	// changes at both ends of large inputs don't need a huge lcs table
+	a := make([]string, 5000)
+	b := make([]string, 5000)
+	for i := range a {
+		a[i] = strconv.Itoa(i)
+		b[i] = strconv.Itoa(i + 1)
+	}
+	ops := diffLines(a, b)
+	na, nb := 0, 0
+	for _, op := range ops {
+		if op.op != diffAdd {
+			na++
+		}
+		if op.op != diffDel {
+			nb++
+		}
+	}
+	if na != len(a) || nb != len(b) {
+		t.Errorf("FAIL expected (%d, %d) != actual (%d, %d)", len(a), len(b), na, nb)
+	}
+	// a change in the middle keeps the common lines
+	b = append([]string{}, a...)
+	b[2500] = "x"
+	ops = diffLines(a, b)
+	if len(ops) != len(a)+1 || ops[2500].op != diffDel || ops[2501].op != diffAdd || ops[2501].bi != 2500 {
+		t.Errorf("FAIL bad middle change %v", ops[2499:2503])
+	}
+}
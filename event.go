@@ -0,0 +1,124 @@
+//-----------------------------------------------------------------------------
+/*
+
+Event Bus
+
+Background subsystems publish events (Eg. "link down") which are displayed
+above the command line being edited. Handler functions and command lines can
+be subscribed to an event topic.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+
+// Event is a message published on the event bus.
+type Event struct {
+	Topic   string      // event topic
+	Message string      // message displayed above the command line (if not empty)
+	Data    interface{} // event specific data
+	Time    time.Time   // time the event was published
+}
+
+// String returns the event as displayed above the command line.
+func (e *Event) String() string {
+	return fmt.Sprintf("%s [%s] %s", e.Time.Format("15:04:05"), e.Topic, e.Message)
+}
+
+// subscription to an event topic
+type subscription struct {
+	id    int                    // subscription identifier
+	topic string                 // event topic, "*" for all topics
+	fn    func(c *CLI, e *Event) // handler function
+}
+
+// eventBus stores the event subscriptions.
+type eventBus struct {
+	sync.Mutex
+	subs   []*subscription
+	nextID int
+}
+
+//-----------------------------------------------------------------------------
+
+// Subscribe calls a handler function for events published on a topic.
+// Use "*" for all topics. Returns the subscription identifier.
+// Handlers are run by the goroutine running the CLI, see Publish.
+func (c *CLI) Subscribe(topic string, fn func(c *CLI, e *Event)) int {
+	b := c.events
+	b.Lock()
+	defer b.Unlock()
+	b.nextID++
+	b.subs = append(b.subs, &subscription{b.nextID, topic, fn})
+	return b.nextID
+}
+
+// SubscribeCmd runs a command line for events published on a topic.
+// Returns the subscription identifier.
+func (c *CLI) SubscribeCmd(topic, line string) (int, error) {
+	item, args, err := c.resolve(c.parser.Split(line))
+	if err != nil {
+		return 0, err
+	}
+	return c.Subscribe(topic, func(c *CLI, e *Event) {
+		c.runLeaf(item, args)
+	}), nil
+}
+
+// Unsubscribe removes a subscription.
+func (c *CLI) Unsubscribe(id int) {
+	b := c.events
+	b.Lock()
+	defer b.Unlock()
+	for i, s := range b.subs {
+		if s.id == id {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Publish publishes an event. It's safe to call from other goroutines.
+// The message is displayed above the command line being edited and the
+// handlers are run. Both are queued for the goroutine running the CLI, or
+// done before Publish returns if the CLI isn't running.
+func (c *CLI) Publish(topic, msg string, data interface{}) {
+	e := &Event{
+		Topic:   topic,
+		Message: msg,
+		Data:    data,
+		Time:    time.Now(),
+	}
+	b := c.events
+	b.Lock()
+	var fns []func(c *CLI, e *Event)
+	for _, s := range b.subs {
+		if s.topic == topic || s.topic == "*" {
+			fns = append(fns, s.fn)
+		}
+	}
+	b.Unlock()
+	if msg == "" && len(fns) == 0 {
+		return
+	}
+	// the event is delivered to the cli, not a copy of the session
+	o := c.owner()
+	o.post(func() {
+		if msg != "" {
+			o.Put(e.String() + "\n")
+		}
+		for _, fn := range fns {
+			fn(o, e)
+		}
+	})
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Events(t *testing.T) {
+	var got []string
+	user := &testUser{}
+	c := NewCLI(user)
+	c.SetRoot(Menu{
+		{"show", Leaf{"show", func(c *CLI, args []string) { got = append(got, "cmd "+args[0]) }}},
+	})
+	id := c.Subscribe("link", func(c *CLI, e *Event) { got = append(got, "fn "+e.Message) })
+	c.Subscribe("*", func(c *CLI, e *Event) { got = append(got, "all "+e.Topic) })
+	if _, err := c.SubscribeCmd("link", "sh links"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SubscribeCmd("link", "bogus"); err == nil {
+		t.Errorf("FAIL subscribed an unknown command")
+	}
+	c.Publish("link", "down", nil)
+	c.Unsubscribe(id)
+	c.Publish("alarm", "", nil)
+	c.Publish("link", "up", nil)
+	expect := []string{"fn down", "all link", "cmd links", "all alarm", "all link", "cmd links"}
+	if len(got) != len(expect) {
+		t.Fatalf("FAIL expected (%v) != actual (%v)", expect, got)
+	}
+	for i := range expect {
+		if got[i] != expect[i] {
+			t.Errorf("%d: FAIL expected (%v) != actual (%v)", i, expect[i], got[i])
+		}
+	}
+	// the messages are put to the session user
+	out := user.out.String()
+	if !strings.Contains(out, "[link] down\n") || !strings.Contains(out, "[link] up\n") || strings.Contains(out, "[alarm]") {
+		t.Errorf("FAIL bad event output %q", out)
+	}
+}
+
+func Test_EventsMainLoop(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	var line string
+	c := NewCLI(&testUser{})
+	c.SetRoot(testMenu)
+	c.ln.SetTerminal(&testTerm{Reader: r})
+	c.Subscribe("stop", func(c *CLI, e *Event) {
+		line = c.currentLine
+		c.Exit()
+	})
+	go func() {
+		w.Write([]byte("abc"))
+		time.Sleep(50 * time.Millisecond)
+		c.Publish("stop", "", nil)
+	}()
+	// the handler interrupts the line being edited and runs on this goroutine
+	for c.Running() {
+		c.Run()
+	}
+	if line != "abc" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "abc", line)
+	}
+}
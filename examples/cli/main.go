@@ -7,6 +7,7 @@ Example code to demonstrate the Command Line Interface.
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -17,49 +18,66 @@ import (
 //-----------------------------------------------------------------------------
 // cli related leaf functions
 
-var cmdHelp = cli.Leaf{
-	Descr: "general help",
+var cmdSchedule = cli.Leaf{
+	Descr: "schedule commands",
 	F: func(c *cli.CLI, args []string) {
-		c.GeneralHelp()
+		c.ScheduleCmd(args)
 	},
 }
 
-var cmdHistory = cli.Leaf{
-	Descr: "command history",
+var cmdRecord = cli.Leaf{
+	Descr: "record command macros",
 	F: func(c *cli.CLI, args []string) {
-		c.SetLine(c.DisplayHistory(args))
+		c.RecordCmd(args)
 	},
 }
 
-var cmdExit = cli.Leaf{
-	Descr: "exit application",
+var cmdRun = cli.Leaf{
+	Descr: "run a command macro",
 	F: func(c *cli.CLI, args []string) {
-		c.Exit()
+		c.RunCmd(args)
 	},
 }
 
+var cmdLock = cli.Leaf{
+	Descr: "lock the session",
+	F: func(c *cli.CLI, args []string) {
+		c.Lock()
+	},
+}
+
+var cmdDryRun = cli.Leaf{
+	Descr: "validate commands without running them",
+	F: func(c *cli.CLI, args []string) {
+		if len(args) == 1 && (args[0] == "on" || args[0] == "off") {
+			c.SetDryRun(args[0] == "on")
+		}
+		c.Put(fmt.Sprintf("dry-run is %v\n", c.DryRun()))
+	},
+}
+
+var dryRunHelp = []cli.Help{
+	{"<cr>", "display the dry-run mode"},
+	{"on|off", "set the dry-run mode"},
+}
+
 //-----------------------------------------------------------------------------
 // application leaf functions
 
-const maxLoops = 10
-
 var loopIndex int
 
-// example loop function - return true on completion
-func loop() bool {
-	fmt.Printf("loop index %d/%d\r\n", loopIndex, maxLoops)
-	time.Sleep(500 * time.Millisecond)
+// example render function - return the screen content
+func loopRender() string {
 	loopIndex++
-	return loopIndex > maxLoops
+	return fmt.Sprintf("loop index %d\n%s\n\nCtrl-D to exit\n", loopIndex, time.Now().Format("15:04:05"))
 }
 
 var a0Func = cli.Leaf{
 	Descr: "a0 function description",
 	F: func(c *cli.CLI, args []string) {
 		c.Put(fmt.Sprintf("a0 function arguments %v\n", args))
-		c.Put("Looping... Ctrl-D to exit\n")
 		loopIndex = 0
-		c.Loop(loop, cli.KeycodeCtrlD)
+		c.LoopRender(loopRender, 500*time.Millisecond, []rune{cli.KeycodeCtrlD})
 	},
 }
 
@@ -74,6 +92,11 @@ var a2Func = cli.Leaf{
 	Descr: "a2 function description",
 	F: func(c *cli.CLI, args []string) {
 		c.Put(fmt.Sprintf("a2 function arguments %v\n", args))
+		name, err := c.ReadLineDefault("name: ", "default")
+		if err != nil {
+			return
+		}
+		c.Put(fmt.Sprintf("name is %q\n", name))
 	},
 }
 
@@ -87,7 +110,9 @@ var b0Func = cli.Leaf{
 var b1Func = cli.Leaf{
 	Descr: "b1 function description",
 	F: func(c *cli.CLI, args []string) {
-		c.Put(fmt.Sprintf("b1 function arguments %v\n", args))
+		if c.Apply(fmt.Sprintf("b1 with arguments %v", args)) {
+			c.Put(fmt.Sprintf("b1 function arguments %v\n", args))
+		}
 	},
 }
 
@@ -112,6 +137,15 @@ var c2Func = cli.Leaf{
 	},
 }
 
+var cmdShell = cli.Leaf{
+	Descr: "run a child shell with the 'a' menu",
+	F: func(c *cli.CLI, args []string) {
+		c.Shell(aMenu, "shell> ", func(child *cli.CLI) {
+			child.AddStandardCommands(0)
+		})
+	},
+}
+
 //-----------------------------------------------------------------------------
 
 // example of function argument help (parm, descr)
@@ -131,7 +165,7 @@ var aMenu = cli.Menu{
 // 'b' submenu items
 var bMenu = cli.Menu{
 	{"b0", b0Func, argumentHelp},
-	{"b1", b1Func},
+	{"b1", b1Func, cli.StateChanging},
 }
 
 // 'c' submenu items
@@ -146,9 +180,13 @@ var menuRoot = cli.Menu{
 	{"amenu", aMenu, "menu a functions"},
 	{"bmenu", bMenu, "menu b functions"},
 	{"cmenu", cMenu, "menu c functions"},
-	{"exit", cmdExit},
-	{"help", cmdHelp},
-	{"history", cmdHistory, cli.HistoryHelp},
+	{"dryrun", cmdDryRun, dryRunHelp},
+	{"lock", cmdLock, cli.LockHelp},
+	{"terminal", cli.PrefsMenu, "terminal and user preferences"},
+	{"record", cmdRecord, cli.RecordHelp},
+	{"run", cmdRun, cli.RunHelp},
+	{"schedule", cmdSchedule, cli.ScheduleHelp},
+	{"shell", cmdShell},
 }
 
 //-----------------------------------------------------------------------------
@@ -170,15 +208,33 @@ func (user *userApp) Put(s string) {
 //-----------------------------------------------------------------------------
 
 func main() {
-	hpath := "history.txt"
-	c := cli.NewCLI(newUserApp())
-	c.HistoryLoad(hpath)
-	c.SetRoot(menuRoot)
-	c.SetPrompt("cli> ")
+	c := cli.NewCLI(newUserApp(),
+		cli.WithRoot(menuRoot),
+		cli.WithStandardCommands(cli.StdSource),
+		cli.WithPrompt("cli> "),
+		cli.WithHistoryFile("history.txt"),
+		cli.WithHistoryCompletion(true),
+		cli.WithArgHints(true),
+		cli.WithPrefsFile("prefs.json"),
+	)
+	if len(os.Args) > 1 {
+		// one-shot mode: run the command from the arguments
+		if err := c.Exec(os.Args[1:]); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	c.SetBanner(cli.CenterString("go-cli example\nType \"help\" for help.", 60))
+	c.SetUnlock(func(c *cli.CLI) error {
+		s, err := c.ReadSecret("password (secret): ", false)
+		if err == nil && s != "secret" {
+			err = errors.New("incorrect password")
+		}
+		return err
+	})
 	for c.Running() {
 		c.Run()
 	}
-	c.HistorySave(hpath)
 	os.Exit(0)
 }
 
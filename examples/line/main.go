@@ -64,10 +64,20 @@ func main() {
 	multilineFlag := flag.Bool("multiline", false, "enable multiline editing mode")
 	keycodeFlag := flag.Bool("keycodes", false, "read and display keycodes")
 	loopFlag := flag.Bool("loop", false, "run a loop function with hotkey exit")
+	traceFlag := flag.String("trace", "", "write a debug trace to a file")
 	flag.Parse()
 
 	l := cli.NewLineNoise()
 
+	if *traceFlag != "" {
+		f, err := os.Create(*traceFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		l.SetTrace(f)
+	}
+
 	if *multilineFlag {
 		l.SetMultiline(true)
 		fmt.Printf("Multi-line mode enabled.\n")
@@ -104,7 +114,7 @@ func main() {
 	for {
 		s, err := l.Read(prompt, "")
 		if err != nil {
-			if err == cli.ErrQuit {
+			if err == cli.ErrQuit || err == cli.ErrEOF {
 				break
 			}
 			log.Printf("%s\n", err)
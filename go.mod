@@ -4,10 +4,9 @@ go 1.13
 
 require (
 	github.com/creack/termios v0.0.0-20160714173321-88d0029e36a1
-	github.com/deadsy/go-fdset v0.0.0-20191109013125-1377c8665b89
 	github.com/kr/pty v1.1.8 // indirect
 	github.com/mattn/go-isatty v0.0.17
 	github.com/mattn/go-runewidth v0.0.14
-	github.com/rivo/uniseg v0.4.4 // indirect
-	golang.org/x/sys v0.5.0 // indirect
+	github.com/rivo/uniseg v0.4.4
+	golang.org/x/sys v0.5.0
 )
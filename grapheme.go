@@ -0,0 +1,99 @@
+//-----------------------------------------------------------------------------
+/*
+
+Grapheme Clusters
+
+The line buffer is a slice of runes, but a glyph on the screen can be several
+runes (Eg. combining accents, emoji ZWJ sequences, Hangul jamo). Cursor
+movement, deletion and display widths step over whole grapheme clusters.
+
+Line refreshes don't allocate, so clusters are found in a small window of
+the buffer encoded on the stack, with a fast path for simple text.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
+)
+
+//-----------------------------------------------------------------------------
+
+// maximum number of runes in a grapheme cluster
+const graphemeWindow = 32
+
+// Is there a grapheme cluster boundary between runes a and b?
+// Returns false if the boundary is unknown without segmentation.
+func graphemeBreak(a, b rune) bool {
+	// runes below the combining marks don't join, except for CR LF
+	return a < 0x300 && b < 0x300 && !(a == '\r' && b == '\n')
+}
+
+// Return the rune length of the grapheme cluster starting at buf[i].
+func graphemeLen(buf []rune, i int) int {
+	var tmp [4 * graphemeWindow]byte
+	n := 0
+	for j := i; j < len(buf) && j < i+graphemeWindow; j++ {
+		n += copy(tmp[n:], string(buf[j]))
+	}
+	cluster, _, _, _ := uniseg.FirstGraphemeCluster(tmp[:n], -1)
+	k := 0
+	for _, c := range cluster {
+		// count the utf8 start bytes
+		if c&0xc0 != 0x80 {
+			k++
+		}
+	}
+	if k == 0 {
+		k = 1
+	}
+	return k
+}
+
+// Return the buffer position after the grapheme cluster at pos.
+func graphemeNext(buf []rune, pos int) int {
+	if pos >= len(buf)-1 {
+		return len(buf)
+	}
+	if graphemeBreak(buf[pos], buf[pos+1]) {
+		return pos + 1
+	}
+	return pos + graphemeLen(buf, pos)
+}
+
+// Return the buffer position of the grapheme cluster before pos.
+func graphemePrev(buf []rune, pos int) int {
+	if pos <= 1 {
+		return 0
+	}
+	if graphemeBreak(buf[pos-2], buf[pos-1]) {
+		return pos - 1
+	}
+	// go back to a known boundary, then forward to pos
+	k := pos - 1
+	for k > 0 && !graphemeBreak(buf[k-1], buf[k]) {
+		k--
+	}
+	for {
+		n := k + graphemeLen(buf, k)
+		if n >= pos {
+			return k
+		}
+		k = n
+	}
+}
+
+// Return the display width of a rune slice.
+// The width of a grapheme cluster is the width of the first rune.
+func runesWidth(rs []rune) int {
+	w := 0
+	for i := 0; i < len(rs); i = graphemeNext(rs, i) {
+		w += runewidth.RuneWidth(rs[i])
+	}
+	return w
+}
+
+//-----------------------------------------------------------------------------
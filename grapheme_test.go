@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func Test_Graphemes(t *testing.T) {
+	tests := []struct {
+		s      string
+		bounds []int // cluster boundaries (rune positions)
+		width  int
+	}{
+		{"abc", []int{0, 1, 2, 3}, 3},
+		{"e\u0301x", []int{0, 2, 3}, 2},
+		{"\U0001f468\u200d\U0001f469\u200d\U0001f467!", []int{0, 5, 6}, 3},
+		{"\u1100\u1161\u11a8a", []int{0, 3, 4}, 3},
+		{"\U0001f1ef\U0001f1f5\U0001f1fa\U0001f1f8", []int{0, 2, 4}, -1},
+		{"a\r\nb", []int{0, 1, 3, 4}, -1},
+	}
+	for i, v := range tests {
+		buf := []rune(v.s)
+		for k := 0; k < len(v.bounds)-1; k++ {
+			if n := graphemeNext(buf, v.bounds[k]); n != v.bounds[k+1] {
+				t.Errorf("%d: FAIL next expected (%d) != actual (%d)", i, v.bounds[k+1], n)
+			}
+			if n := graphemePrev(buf, v.bounds[k+1]); n != v.bounds[k] {
+				t.Errorf("%d: FAIL prev expected (%d) != actual (%d)", i, v.bounds[k], n)
+			}
+		}
+		if w := runesWidth(buf); v.width >= 0 && w != v.width {
+			t.Errorf("%d: FAIL width expected (%d) != actual (%d)", i, v.width, w)
+		}
+	}
+}
+
+func Test_GraphemeEdit(t *testing.T) {
+	tests := []struct {
+		in   string
+		init string
+		line string
+	}{
+		{"\x7f\r", "cafe\u0301", "caf"},
+		{"\x02\x02X\r", "ae\u0301b", "aXe\u0301b"},
+		{"\x01\x04\r", "\U0001f468\u200d\U0001f469\u200d\U0001f467!", "!"},
+		{"\x02\x14\r", "ae\u0301", "e\u0301a"},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		line, err := l.Edit(NewByteSource([]byte(v.in)), ioutil.Discard, "> ", v.init)
+		if line != v.line || err != nil {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q, %v)", i, v.line, line, err)
+		}
+	}
+}
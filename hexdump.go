@@ -0,0 +1,78 @@
+//-----------------------------------------------------------------------------
+/*
+
+Hex Dump
+
+Canonical offset/hex/ASCII output of a byte buffer, sized to the terminal.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+
+// Return the width of a hex dump line with n bytes per line.
+func hexDumpWidth(n, addrWidth int) int {
+	// address, hex bytes with a gap every 8 bytes, |ascii|
+	return addrWidth + 2 + (3 * n) + (n / 8) - 1 + 2 + n + 1
+}
+
+// Return a hex dump of the data with lines that fit within cols.
+func hexDump(data []byte, baseAddr uint64, cols int) string {
+	if len(data) == 0 {
+		return ""
+	}
+	// address width
+	addrWidth := 8
+	if baseAddr+uint64(len(data)) > 0xffffffff {
+		addrWidth = 16
+	}
+	// bytes per line
+	n := 8
+	for hexDumpWidth(2*n, addrWidth) <= cols && n < 64 {
+		n *= 2
+	}
+	lines := make([]string, 0, (len(data)+n-1)/n)
+	for ofs := 0; ofs < len(data); ofs += n {
+		end := ofs + n
+		if end > len(data) {
+			end = len(data)
+		}
+		s := make([]string, 0, 8)
+		s = append(s, fmt.Sprintf("%0*x  ", addrWidth, baseAddr+uint64(ofs)))
+		ascii := make([]byte, 0, n)
+		for i := 0; i < n; i++ {
+			if i != 0 && i%8 == 0 {
+				s = append(s, " ")
+			}
+			if ofs+i < end {
+				c := data[ofs+i]
+				s = append(s, fmt.Sprintf("%02x ", c))
+				if c >= 0x20 && c < 0x7f {
+					ascii = append(ascii, c)
+				} else {
+					ascii = append(ascii, '.')
+				}
+			} else {
+				s = append(s, "   ")
+			}
+		}
+		s = append(s, fmt.Sprintf(" |%s|", ascii))
+		lines = append(lines, strings.Join(s, ""))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// HexDump returns a canonical hex dump (offset, hex bytes, ASCII) of the data.
+// The number of bytes per line is sized to the terminal width.
+func HexDump(data []byte, baseAddr uint64) string {
+	return hexDump(data, baseAddr, termColumns())
+}
+
+//-----------------------------------------------------------------------------
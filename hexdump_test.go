@@ -0,0 +1,22 @@
+package cli
+
+import "testing"
+
+func Test_HexDump(t *testing.T) {
+	data := []byte("Hello World\n\x00\x01abcdefgh")
+	s := hexDump(data, 0x1000, 80)
+	expected := "" +
+		"00001000  48 65 6c 6c 6f 20 57 6f  72 6c 64 0a 00 01 61 62  |Hello World...ab|\n" +
+		"00001010  63 64 65 66 67 68                                 |cdefgh|\n"
+	if s != expected {
+		t.Errorf("FAIL expected\n%s\nactual\n%s", expected, s)
+	}
+	s = hexDump(data[:4], 0, 40)
+	expected = "00000000  48 65 6c 6c              |Hell|\n"
+	if s != expected {
+		t.Errorf("FAIL expected\n%s\nactual\n%s", expected, s)
+	}
+	if len(hexDump(make([]byte, 64), 0xfffffffff, 300)) != hexDumpWidth(64, 16)+1 {
+		t.Errorf("FAIL bad line width")
+	}
+}
@@ -0,0 +1,120 @@
+//-----------------------------------------------------------------------------
+/*
+
+Input Events
+
+An event channel for applications with their own select loop (Eg. network IO
+and timers). A goroutine reads lines with the line editor and sends them as
+events, along with key presses and terminal resizes, so the application never
+blocks in Read.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+//-----------------------------------------------------------------------------
+
+// InputEventType is the type of an input event.
+type InputEventType int
+
+// Input event types
+const (
+	EventLine   InputEventType = iota // a line has been entered
+	EventKey                          // a key was pressed while editing
+	EventResize                       // the terminal was resized while editing
+	EventEOF                          // the end of input, the channel is then closed
+)
+
+var eventTypeNames = map[InputEventType]string{
+	EventLine:   "line",
+	EventKey:    "key",
+	EventResize: "resize",
+	EventEOF:    "eof",
+}
+
+func (t InputEventType) String() string {
+	if s, ok := eventTypeNames[t]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// InputEvent is an input event from the line editor.
+type InputEvent struct {
+	Type       InputEventType
+	Line       string   // EventLine: the line
+	Key        KeyEvent // EventKey: the key code, escape sequences are KeycodeESC
+	Cols, Rows int      // EventResize: the terminal size
+	Err        error    // EventEOF: the error that ended input (Eg. ErrQuit, ErrEOF)
+}
+
+// size of the event channel buffer
+const eventsBuffer = 64
+
+// Events returns a channel of input events. The first call starts a goroutine
+// that reads lines (see SetEventPrompt) until the end of input, an EventEOF is
+// then sent and the channel is closed. Line and EOF events wait for the
+// receiver, key and resize events are dropped if the channel is full.
+// After a Shutdown the events don't wait, the EventEOF (with ErrShutdown)
+// is dropped if the channel is full.
+func (l *Linenoise) Events() <-chan InputEvent {
+	l.eventsOnce.Do(func() {
+		l.asyncLock.Lock()
+		l.events = make(chan InputEvent, eventsBuffer)
+		l.asyncLock.Unlock()
+		go l.readEvents()
+	})
+	return l.events
+}
+
+// SetEventPrompt sets the prompt for lines read by the event goroutine.
+// A line being edited is redrawn with the new prompt.
+// It's safe to call from other goroutines.
+func (l *Linenoise) SetEventPrompt(prompt string) {
+	l.asyncLock.Lock()
+	l.eventPrompt = prompt
+	l.asyncLock.Unlock()
+	l.SetPromptAsync(prompt)
+}
+
+// Read lines and send them as events.
+func (l *Linenoise) readEvents() {
+	defer close(l.events)
+	for {
+		l.asyncLock.Lock()
+		prompt := l.eventPrompt
+		l.asyncLock.Unlock()
+		line, err := l.Read(prompt, "")
+		e := InputEvent{Type: EventLine, Line: line}
+		if err != nil {
+			e = InputEvent{Type: EventEOF, Err: err}
+		}
+		select {
+		case l.events <- e:
+		case <-l.shutdown:
+			// don't wait for the receiver, send what fits
+			l.postEvent(e)
+			if err == nil {
+				l.postEvent(InputEvent{Type: EventEOF, Err: ErrShutdown})
+			}
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Send an event without waiting if there is an event reader.
+func (l *Linenoise) postEvent(e InputEvent) {
+	if l.events == nil {
+		return
+	}
+	select {
+	case l.events <- e:
+	default:
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_InputEvents(t *testing.T) {
+	term := &testTerm{Reader: strings.NewReader("ab\rc\r")}
+	l := NewLineNoise()
+	l.SetTerminal(term)
+	l.SetEventPrompt("> ")
+	var lines []string
+	keys := 0
+	var end InputEvent
+	for e := range l.Events() {
+		switch e.Type {
+		case EventLine:
+			lines = append(lines, e.Line)
+		case EventKey:
+			keys++
+		case EventEOF:
+			end = e
+		}
+	}
+	if strings.Join(lines, ",") != "ab,c" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "ab,c", lines)
+	}
+	if keys != 5 {
+		t.Errorf("FAIL expected (%d) != actual (%d) keys", 5, keys)
+	}
+	if end.Type != EventEOF || end.Err == nil {
+		t.Errorf("FAIL expected eof event, actual (%v, %v)", end.Type, end.Err)
+	}
+	if !strings.Contains(term.out.String(), "> ab") {
+		t.Errorf("FAIL prompt not written to the terminal: %q", term.out.String())
+	}
+}
+
+func Test_InputEventsShutdown(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	l := NewLineNoise()
+	l.SetTerminal(&testTerm{Reader: r})
+	events := l.Events()
+	w.Write([]byte("ab\r"))
+	l.Shutdown()
+	var last InputEvent
+	timeout := time.After(time.Second)
+	for done := false; !done; {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				done = true
+				break
+			}
+			last = e
+		case <-timeout:
+			t.Fatalf("FAIL event channel not closed")
+		}
+	}
+	if last.Type != EventEOF || last.Err != ErrShutdown {
+		t.Errorf("FAIL expected (eof, %v) != actual (%v, %v)", ErrShutdown, last.Type, last.Err)
+	}
+}
@@ -0,0 +1,309 @@
+//-----------------------------------------------------------------------------
+/*
+
+Line Editor Keymap
+
+Keys are bound to named editor actions or to application functions.
+The default keymap has the emacs style bindings, applications can rebind
+them with Bind, BindFunc and Unbind. Meta keys (ESC key or alt-key) are bound
+with MetaKey. Escape sequences (arrow keys, etc.) are not in the keymap.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"fmt"
+	"unicode"
+)
+
+//-----------------------------------------------------------------------------
+
+// EditAction is a named line editor action.
+type EditAction string
+
+// Line editor actions
+const (
+	ActionSelfInsert         EditAction = "self-insert"               // insert the key
+	ActionAcceptLine         EditAction = "accept-line"               // return the line
+	ActionQuit               EditAction = "quit"                      // return ErrQuit
+	ActionDeleteCharOrQuit   EditAction = "delete-char-or-quit"       // delete a character, ErrQuit for an empty line
+	ActionMoveHome           EditAction = "move-home"                 // cursor to the start of the line
+	ActionMoveEnd            EditAction = "move-end"                  // cursor to the end of the line
+	ActionMoveLeft           EditAction = "move-left"                 // cursor left
+	ActionMoveRight          EditAction = "move-right"                // cursor right
+	ActionBackwardDeleteChar EditAction = "backward-delete-char"      // delete the character left of the cursor
+	ActionDeleteChar         EditAction = "delete-char"               // delete the character at the cursor
+	ActionKillLine           EditAction = "kill-line"                 // delete to the end of the line
+	ActionKillWholeLine      EditAction = "kill-whole-line"           // delete the whole line
+	ActionBackwardKillWord   EditAction = "backward-kill-word"        // delete the previous word
+	ActionTransposeChars     EditAction = "transpose-chars"           // swap the character with the previous
+	ActionClearScreen        EditAction = "clear-screen"              // clear the screen
+	ActionHistoryPrev        EditAction = "history-prev"              // previous history entry
+	ActionHistoryNext        EditAction = "history-next"              // next history entry
+	ActionHistorySearch      EditAction = "history-search"            // reverse incremental history search
+	ActionComplete           EditAction = "complete"                  // completion
+	ActionUndo               EditAction = "undo"                      // undo the last edit
+	ActionRedo               EditAction = "redo"                      // redo the last undone edit
+	ActionOverwriteMode      EditAction = "overwrite-mode"            // toggle overwrite/insert mode
+	ActionCharSearch         EditAction = "character-search"          // cursor to the next occurrence of the next typed character
+	ActionCharSearchBackward EditAction = "character-search-backward" // cursor to the previous occurrence of the next typed character
+	ActionYankLastArg        EditAction = "yank-last-arg"             // insert the last argument of a previous line
+	ActionTransposeWords     EditAction = "transpose-words"           // swap the word with the previous word
+)
+
+var editActions = map[EditAction]bool{
+	ActionSelfInsert:         true,
+	ActionAcceptLine:         true,
+	ActionQuit:               true,
+	ActionDeleteCharOrQuit:   true,
+	ActionMoveHome:           true,
+	ActionMoveEnd:            true,
+	ActionMoveLeft:           true,
+	ActionMoveRight:          true,
+	ActionBackwardDeleteChar: true,
+	ActionDeleteChar:         true,
+	ActionKillLine:           true,
+	ActionKillWholeLine:      true,
+	ActionBackwardKillWord:   true,
+	ActionTransposeChars:     true,
+	ActionClearScreen:        true,
+	ActionHistoryPrev:        true,
+	ActionHistoryNext:        true,
+	ActionHistorySearch:      true,
+	ActionComplete:           true,
+	ActionUndo:               true,
+	ActionRedo:               true,
+	ActionOverwriteMode:      true,
+	ActionCharSearch:         true,
+	ActionCharSearchBackward: true,
+	ActionYankLastArg:        true,
+	ActionTransposeWords:     true,
+}
+
+// keyBinding is the action or function bound to a key.
+type keyBinding struct {
+	action EditAction                               // named editor action
+	fn     func(line string, pos int) (string, int) // application function
+}
+
+// actions that can be repeated by a numeric argument
+var repeatActions = map[EditAction]bool{
+	"":                       true, // unbound keys are inserted
+	ActionSelfInsert:         true,
+	ActionMoveLeft:           true,
+	ActionMoveRight:          true,
+	ActionBackwardDeleteChar: true,
+	ActionDeleteChar:         true,
+	ActionDeleteCharOrQuit:   true,
+	ActionKillLine:           true,
+	ActionBackwardKillWord:   true,
+	ActionTransposeChars:     true,
+	ActionTransposeWords:     true,
+	ActionHistoryPrev:        true,
+	ActionHistoryNext:        true,
+	ActionUndo:               true,
+	ActionRedo:               true,
+}
+
+// maximum numeric argument
+const maxRepeat = 1000
+
+// defaultKeymap returns the default key bindings.
+func defaultKeymap() map[Key]keyBinding {
+	return map[Key]keyBinding{
+		KeycodeCR:               {action: ActionAcceptLine},
+		KeycodeTAB:              {action: ActionComplete},
+		KeycodeBS:               {action: ActionBackwardDeleteChar},
+		KeycodeCtrlA:            {action: ActionMoveHome},
+		KeycodeCtrlB:            {action: ActionMoveLeft},
+		KeycodeCtrlC:            {action: ActionQuit},
+		KeycodeCtrlD:            {action: ActionDeleteCharOrQuit},
+		KeycodeCtrlE:            {action: ActionMoveEnd},
+		KeycodeCtrlF:            {action: ActionMoveRight},
+		KeycodeCtrlH:            {action: ActionBackwardDeleteChar},
+		KeycodeCtrlK:            {action: ActionKillLine},
+		KeycodeCtrlL:            {action: ActionClearScreen},
+		KeycodeCtrlN:            {action: ActionHistoryNext},
+		KeycodeCtrlP:            {action: ActionHistoryPrev},
+		KeycodeCtrlR:            {action: ActionHistorySearch},
+		KeycodeCtrlT:            {action: ActionTransposeChars},
+		KeycodeCtrlU:            {action: ActionKillWholeLine},
+		KeycodeCtrlW:            {action: ActionBackwardKillWord},
+		KeycodeCtrlUnderscore:   {action: ActionUndo},
+		KeycodeCtrlCaret:        {action: ActionRedo},
+		KeycodeCtrlRightBracket: {action: ActionCharSearch},
+		MetaKey('.'):            {action: ActionYankLastArg},
+		MetaKey('t'):            {action: ActionTransposeWords},
+	}
+}
+
+// Return true if a key can't be bound.
+// It starts escape sequences, numeric arguments or the backward character search.
+func reservedKey(key Key) bool {
+	switch key {
+	case KeycodeESC, MetaKey('['), MetaKey('0'), MetaKey(KeycodeCtrlRightBracket):
+		return true
+	}
+	return key >= MetaKey('1') && key <= MetaKey('9')
+}
+
+// Bind binds a key to a named editor action. The escape key and the meta
+// keys for escape sequences and numeric arguments can't be rebound.
+func (l *Linenoise) Bind(key Key, action EditAction) error {
+	if reservedKey(key) {
+		return fmt.Errorf("can't bind %s", key)
+	}
+	if !editActions[action] {
+		return fmt.Errorf("unknown editor action \"%s\"", action)
+	}
+	l.keymap[key] = keyBinding{action: action}
+	return nil
+}
+
+// BindFunc binds a key to an application function. The function is passed
+// the line buffer and the cursor position and returns the new line buffer
+// and cursor position.
+func (l *Linenoise) BindFunc(key Key, fn func(line string, pos int) (string, int)) error {
+	if reservedKey(key) {
+		return fmt.Errorf("can't bind %s", key)
+	}
+	if fn == nil {
+		return fmt.Errorf("no function for %s", key)
+	}
+	l.keymap[key] = keyBinding{fn: fn}
+	return nil
+}
+
+// Unbind removes the binding for a key, it will be inserted into the line.
+func (l *Linenoise) Unbind(key Key) {
+	delete(l.keymap, key)
+}
+
+// ResetKeymap restores the default key bindings.
+func (l *Linenoise) ResetKeymap() {
+	l.keymap = defaultKeymap()
+}
+
+//-----------------------------------------------------------------------------
+
+// Run an application function bound to a key.
+func (ls *linestate) editFunc(fn func(line string, pos int) (string, int)) {
+	s, pos := fn(ls.String(), ls.pos)
+	buf := []rune(s)
+	if pos < 0 {
+		pos = 0
+	} else if pos > len(buf) {
+		pos = len(buf)
+	}
+	if s != ls.String() {
+		ls.saveUndo()
+	}
+	ls.buf, ls.pos = buf, pos
+	ls.refreshLine()
+}
+
+// Read the digits of a numeric argument following ESC digit.
+// Returns the repeat count and the key to be repeated.
+func (ls *linestate) numericArg(u *utf8, n int) (int, rune) {
+	for {
+		r := ls.ts.getRune(u, ls.ifd, nil)
+		switch {
+		case r == KeycodeNull:
+		case r == keycodeAsync:
+			ls.asyncFlush()
+		case r == KeycodeESC && !ls.ts.wouldBlock(ls.ifd, &timeout20ms):
+			// a meta key can be repeated
+			s0 := ls.ts.getRune(u, ls.ifd, &timeout20ms)
+			if _, ok := ls.ts.keymap[MetaKey(s0)]; ok {
+				ls.ts.tracef("numeric argument %d", n)
+				return n, rune(MetaKey(s0))
+			}
+			// handle the escape sequence once
+			ls.escNext = s0
+			return n, r
+		case r >= '0' && r <= '9':
+			n = n*10 + int(r-'0')
+			if n > maxRepeat {
+				n = maxRepeat
+			}
+		default:
+			ls.ts.tracef("numeric argument %d", n)
+			return n, r
+		}
+	}
+}
+
+// Repeat the action or function bound to a key n times.
+// Returns false if the key can't be repeated.
+func (ls *linestate) editRepeat(r rune, n int) bool {
+	b := ls.ts.keymap[Key(r)]
+	if b.fn == nil && !repeatActions[b.action] {
+		return false
+	}
+	if (b.action == "" || b.action == ActionSelfInsert) && !unicode.IsPrint(r) {
+		return false
+	}
+	if b.action == ActionDeleteCharOrQuit {
+		b.action = ActionDeleteChar
+	}
+	for i := 0; i < n; i++ {
+		if b.fn != nil {
+			ls.editFunc(b.fn)
+		} else {
+			ls.editAction(b.action, r)
+		}
+	}
+	return true
+}
+
+// Run an editor action that doesn't end line editing.
+func (ls *linestate) editAction(action EditAction, r rune) {
+	switch action {
+	case ActionMoveHome:
+		ls.editMoveHome()
+	case ActionMoveEnd:
+		ls.editMoveEnd()
+	case ActionMoveLeft:
+		ls.editMoveLeft()
+	case ActionMoveRight:
+		ls.editMoveRight()
+	case ActionBackwardDeleteChar:
+		if !ls.deletePair() {
+			ls.editBackspace()
+		}
+	case ActionDeleteChar:
+		ls.editDelete()
+	case ActionKillLine:
+		ls.deleteToEnd()
+	case ActionKillWholeLine:
+		ls.deleteLine()
+	case ActionBackwardKillWord:
+		ls.deletePrevWord()
+	case ActionTransposeChars:
+		ls.editSwap()
+	case ActionTransposeWords:
+		ls.editSwapWords()
+	case ActionClearScreen:
+		ls.clearScreen()
+		ls.refreshLine()
+	case ActionHistoryPrev:
+		ls.editSet(ls.ts.historyPrev(ls))
+	case ActionHistoryNext:
+		ls.editSet(ls.ts.historyNext(ls))
+	case ActionUndo:
+		ls.editUndo()
+	case ActionRedo:
+		ls.editRedo()
+	case ActionOverwriteMode:
+		ls.toggleOverwrite()
+	default:
+		// self-insert, or completion/search when they aren't available
+		if Key(r)&keyMeta == 0 {
+			ls.editSelfInsert(r)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func Test_Keymap(t *testing.T) {
+	upper := func(line string, pos int) (string, int) {
+		return strings.ToUpper(line), 0
+	}
+	tests := []struct {
+		in   string
+		line string
+		err  error
+	}{
+		{"abc\x01X\r", "abcX", nil},
+		{"abc\x18d\r", "dABC", nil},
+		{"abc\x18\x1fd\r", "abcd", nil},
+		{"ab\x15c\r", "ab\x15c", nil},
+		{"abc\n", "abc", nil},
+		{"abc\x0f", "", ErrQuit},
+		{"abc\x03\r", "abc\x03", nil},
+		{"abc\x05\x05\r", "abc", nil},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		l.Bind(KeycodeCtrlA, ActionMoveEnd)
+		l.BindFunc(KeycodeCtrlX, upper)
+		l.Unbind(KeycodeCtrlU)
+		l.Bind(KeycodeLF, ActionAcceptLine)
+		l.Bind(KeycodeCtrlO, ActionQuit)
+		l.Bind(KeycodeCtrlC, ActionSelfInsert)
+		line, err := l.Edit(NewByteSource([]byte(v.in)), ioutil.Discard, "> ", "")
+		if line != v.line || err != v.err {
+			t.Errorf("%d: FAIL expected (%q, %v) != actual (%q, %v)", i, v.line, v.err, line, err)
+		}
+	}
+	l := NewLineNoise()
+	if err := l.Bind(KeycodeCtrlA, "no-such-action"); err == nil {
+		t.Errorf("FAIL unknown action has no error")
+	}
+	if err := l.Bind(KeycodeESC, ActionQuit); err == nil {
+		t.Errorf("FAIL escape key binding has no error")
+	}
+	l.Bind(KeycodeCtrlA, ActionMoveEnd)
+	l.ResetKeymap()
+	line, _ := l.Edit(NewByteSource([]byte("bc\x01a\r")), ioutil.Discard, "> ", "")
+	if line != "abc" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "abc", line)
+	}
+}
+
+func Test_MetaKeys(t *testing.T) {
+	tests := []struct {
+		in   string
+		line string
+	}{
+		{"\x1bt\r", "one three  two"},
+		{"\x1b3\x1bt\r", "one three  two"},
+		{"\x1b2\x1bt\r", "one two  three"},
+		{"\x1b2\x1b[DX\r", "one two  threXe"},
+		{"\x1bu\r", "ONE TWO  THREE"},
+		{"\x1b2\x1bx\r", "one two  three"},
+		{"\x1bb\x1bt\r", "one three  two"},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		l.BindFunc(MetaKey('u'), func(line string, pos int) (string, int) {
+			return strings.ToUpper(line), pos
+		})
+		l.Bind(MetaKey('x'), ActionSelfInsert)
+		l.Unbind(MetaKey('b'))
+		line, err := l.Edit(NewByteSource([]byte(v.in)), ioutil.Discard, "> ", "one two  three")
+		if line != v.line || err != nil {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q, %v)", i, v.line, line, err)
+		}
+	}
+	l := NewLineNoise()
+	for _, r := range []rune{'[', '0', '5', KeycodeCtrlRightBracket} {
+		if err := l.Bind(MetaKey(r), ActionQuit); err == nil {
+			t.Errorf("FAIL %s binding has no error", MetaKey(r))
+		}
+	}
+	if s := MetaKey('t').String(); s != "Alt-t" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "Alt-t", s)
+	}
+}
@@ -14,6 +14,12 @@ won't be true for all character sets. If you don't have a monospaced version of
 character being rendered then these assumptions will fail and odd things will
 be seen.
 
+Notes on memory: Line refreshes render into an output buffer that is reused
+across edits, and key input is decoded without allocation, so typing and
+cursor movement don't allocate in the steady state. Allocations are made when
+the line buffer grows, for history entries, and by the completion, hints and
+help callbacks (which are passed the line as a string).
+
 */
 //-----------------------------------------------------------------------------
 
@@ -21,26 +27,30 @@ package cli
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 	"unicode"
-	"unsafe"
 
-	"github.com/creack/termios/raw"
-	"github.com/deadsy/go-fdset"
-	"github.com/mattn/go-isatty"
 	"github.com/mattn/go-runewidth"
 )
 
 //-----------------------------------------------------------------------------
 
 // Keycodes
+// Control keys without a name of their own (Eg. TAB) are KeycodeCtrlX.
 const (
 	KeycodeNull  = 0
 	KeycodeCtrlA = 1
@@ -49,6 +59,7 @@ const (
 	KeycodeCtrlD = 4
 	KeycodeCtrlE = 5
 	KeycodeCtrlF = 6
+	KeycodeCtrlG = 7
 	KeycodeCtrlH = 8
 	KeycodeTAB   = 9
 	KeycodeLF    = 10
@@ -56,20 +67,77 @@ const (
 	KeycodeCtrlL = 12
 	KeycodeCR    = 13
 	KeycodeCtrlN = 14
+	KeycodeCtrlO = 15
 	KeycodeCtrlP = 16
+	KeycodeCtrlQ = 17
+	KeycodeCtrlR = 18
+	KeycodeCtrlS = 19
 	KeycodeCtrlT = 20
 	KeycodeCtrlU = 21
+	KeycodeCtrlV = 22
 	KeycodeCtrlW = 23
+	KeycodeCtrlX = 24
+	KeycodeCtrlY = 25
+	KeycodeCtrlZ = 26
 	KeycodeESC   = 27
 	KeycodeBS    = 127
 )
 
+// Control keys sent for Ctrl-], Ctrl-^ and Ctrl-_ (also Ctrl-/ on many terminals).
+const (
+	KeycodeCtrlRightBracket = 29
+	KeycodeCtrlCaret        = 30
+	KeycodeCtrlUnderscore   = 31
+)
+
+// Key is a key code for the keymap. The Keycode constants are untyped so they
+// can be used as a Key or a rune.
+type Key rune
+
+// bit set in the key code of a meta key
+const keyMeta Key = 1 << 30
+
+// MetaKey returns the Key for a meta key (ESC key or alt-key), Eg. MetaKey('t').
+func MetaKey(r rune) Key {
+	return Key(r) | keyMeta
+}
+
+// String returns a printable name for the key.
+func (k Key) String() string {
+	return keyName(rune(k))
+}
+
+// EscMode sets the behavior of a single ESC key press when editing.
+type EscMode int
+
+// ESC key behaviors
+const (
+	EscAbandon  EscMode = iota // abandon the line (default)
+	EscIgnore                  // ignore the escape
+	EscClear                   // clear the line buffer
+	EscViNormal                // enter vi normal mode
+)
+
 var timeout20ms = syscall.Timeval{0, 20 * 1000}
 var timeoutZero = syscall.Timeval{0, 0}
 
 // ErrQuit is returned when the user has quit line editing.
 var ErrQuit = errors.New("quit")
 
+// ErrEOF is returned at the end of input from a file or pipe.
+var ErrEOF = errors.New("eof")
+
+// ErrIdle is returned when there has been no input for the idle timeout.
+// The partially edited line is returned with the error.
+var ErrIdle = errors.New("idle")
+
+// ErrShutdown is returned when line editing has been shut down.
+var ErrShutdown = errors.New("shutdown")
+
+// ErrInterrupted is returned when line editing has been interrupted.
+// The partially edited line is returned with the error.
+var ErrInterrupted = errors.New("interrupted")
+
 //-----------------------------------------------------------------------------
 
 // boolean to integer
@@ -80,168 +148,192 @@ func btoi(x bool) int {
 	return 0
 }
 
-//-----------------------------------------------------------------------------
-// control the terminal mode
-
-// Set a tty terminal to raw mode.
-func setRawMode(fd int) (*raw.Termios, error) {
-	// make sure this is a tty
-	if !isatty.IsTerminal(uintptr(fd)) {
-		return nil, fmt.Errorf("fd %d is not a tty", fd)
-	}
-	// get the terminal IO mode
-	originalMode, err := raw.TcGetAttr(uintptr(fd))
-	if err != nil {
-		return nil, err
-	}
-	// modify the original mode
-	newMode := *originalMode
-	newMode.Iflag &^= (syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON)
-	newMode.Oflag &^= syscall.OPOST
-	newMode.Lflag &^= (syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN)
-	newMode.Cflag &^= (syscall.CSIZE | syscall.PARENB)
-	newMode.Cflag |= syscall.CS8
-	newMode.Cc[syscall.VMIN] = 1
-	newMode.Cc[syscall.VTIME] = 0
-	err = raw.TcSetAttr(uintptr(fd), &newMode)
-	if err != nil {
-		return nil, err
-	}
-	return originalMode, nil
-}
-
-// Restore the terminal mode.
-func restoreMode(fd int, mode *raw.Termios) error {
-	return raw.TcSetAttr(uintptr(fd), mode)
+// Return a printable name for a key.
+func keyName(r rune) string {
+	if r >= 0 && Key(r)&keyMeta != 0 {
+		return "Alt-" + keyName(r&^rune(keyMeta))
+	}
+	if unicode.IsPrint(r) {
+		return string(r)
+	}
+	switch r {
+	case KeycodeCR:
+		return "\\r"
+	case KeycodeTAB:
+		return "\\t"
+	case KeycodeESC:
+		return "ESC"
+	case KeycodeLF:
+		return "\\n"
+	case KeycodeBS:
+		return "BS"
+	}
+	if r > KeycodeNull && r < ' ' {
+		return "Ctrl-" + string('@'+r)
+	}
+	return "?"
 }
 
 //-----------------------------------------------------------------------------
 // UTF8 Decoding
 
-const (
-	getByte0 = iota
-	get3More
-	get2More
-	get1More
-)
+// utf8Lead describes the valid lead bytes of a utf8 sequence.
+// The range of the second byte excludes overlong encodings, surrogates
+// and code points > U+10FFFF.
+var utf8Lead = []struct {
+	lo, hi   byte // lead byte range
+	size     int  // sequence size in bytes
+	mask     byte // value bits of the lead byte
+	lo2, hi2 byte // second byte range
+}{
+	{0x00, 0x7f, 1, 0x7f, 0, 0},
+	{0xc2, 0xdf, 2, 0x1f, 0x80, 0xbf},
+	{0xe0, 0xe0, 3, 0x0f, 0xa0, 0xbf},
+	{0xe1, 0xec, 3, 0x0f, 0x80, 0xbf},
+	{0xed, 0xed, 3, 0x0f, 0x80, 0x9f},
+	{0xee, 0xef, 3, 0x0f, 0x80, 0xbf},
+	{0xf0, 0xf0, 4, 0x07, 0x90, 0xbf},
+	{0xf1, 0xf3, 4, 0x07, 0x80, 0xbf},
+	{0xf4, 0xf4, 4, 0x07, 0x80, 0x8f},
+}
 
 type utf8 struct {
-	state byte
-	count int
-	val   int32
+	more   int   // number of continuation bytes still needed
+	count  int   // number of bytes in the sequence
+	val    int32 // decoded value
+	lo, hi byte  // range of the next continuation byte
+	held   bool  // a byte that interrupted a sequence needs decoding
+	hold   byte  // the interrupting byte
+	err    error // read error
 }
 
 // Add a byte to a utf8 decode.
-// Return the rune and it's size in bytes.
+// Return the rune and it's size in bytes. The size is 0 for an incomplete
+// sequence. Invalid bytes are returned as U+FFFD. A byte that interrupts
+// a sequence is held and must be added again.
 func (u *utf8) add(c byte) (r rune, size int) {
-	switch u.state {
-	case getByte0:
-		if c&0x80 == 0 {
-			// 1 byte
-			return rune(c), 1
-		} else if c&0xe0 == 0xc0 {
-			// 2 byte
-			u.val = int32(c&0x1f) << 6
-			u.count = 2
-			u.state = get1More
-			return KeycodeNull, 0
-		} else if c&0xf0 == 0xe0 {
-			// 3 bytes
-			u.val = int32(c&0x0f) << 6
-			u.count = 3
-			u.state = get2More
-			return KeycodeNull, 0
-		} else if c&0xf8 == 0xf0 {
-			// 4 bytes
-			u.val = int32(c&0x07) << 6
-			u.count = 4
-			u.state = get3More
-			return KeycodeNull, 0
-		}
-	case get3More:
-		if c&0xc0 == 0x80 {
-			u.state = get2More
-			u.val |= int32(c & 0x3f)
-			u.val <<= 6
-			return KeycodeNull, 0
-		}
-	case get2More:
-		if c&0xc0 == 0x80 {
-			u.state = get1More
-			u.val |= int32(c & 0x3f)
-			u.val <<= 6
-			return KeycodeNull, 0
-		}
-	case get1More:
-		if c&0xc0 == 0x80 {
-			u.state = getByte0
-			u.val |= int32(c & 0x3f)
-			return rune(u.val), u.count
-		}
-	}
-	// Error
-	u.state = getByte0
-	return unicode.ReplacementChar, 1
+	if u.more == 0 {
+		for _, x := range utf8Lead {
+			if c >= x.lo && c <= x.hi {
+				if x.size == 1 {
+					return rune(c), 1
+				}
+				u.val = int32(c & x.mask)
+				u.count = x.size
+				u.more = x.size - 1
+				u.lo, u.hi = x.lo2, x.hi2
+				return KeycodeNull, 0
+			}
+		}
+		// invalid lead byte
+		return unicode.ReplacementChar, 1
+	}
+	if c < u.lo || c > u.hi {
+		// interrupted sequence
+		size = u.count - u.more
+		u.more = 0
+		u.held = true
+		u.hold = c
+		return unicode.ReplacementChar, size
+	}
+	u.val = u.val<<6 | int32(c&0x3f)
+	u.lo, u.hi = 0x80, 0xbf
+	u.more--
+	if u.more != 0 {
+		return KeycodeNull, 0
+	}
+	return rune(u.val), u.count
+}
+
+// Return the next held byte.
+func (u *utf8) next() (byte, bool) {
+	if !u.held {
+		return 0, false
+	}
+	u.held = false
+	return u.hold, true
 }
 
 // read a single rune from a file descriptor (with timeout)
 // timeout >= 0 : wait for timeout seconds
 // timeout = nil : return immediately
+// Return keycodeError on a read error, the error is saved in u.err.
 func (u *utf8) getRune(fd int, timeout *syscall.Timeval) rune {
+	if c, ok := u.next(); ok {
+		// decode the byte that interrupted a sequence
+		r, _ := u.add(c)
+		return r
+	}
 	// use select() for the timeout
 	if timeout != nil {
-		for true {
-			rd := syscall.FdSet{}
-			fdset.Set(fd, &rd)
-			n, err := syscall.Select(fd+1, &rd, nil, nil, timeout)
-			if err != nil {
-				continue
-			}
-			if n == 0 {
-				// nothing is readable
-				return KeycodeNull
-			}
-			break
+		rfd, err := selectRead([]int{fd}, timeout)
+		if err != nil {
+			u.err = err
+			return keycodeError
+		}
+		if rfd < 0 {
+			// nothing is readable
+			return KeycodeNull
 		}
 	}
 	// Read the file descriptor
-	buf := make([]byte, 1)
-	_, err := syscall.Read(fd, buf)
+	c, err := readByte(fd)
 	if err != nil {
-		panic(fmt.Sprintf("read error %s\n", err))
-	}
-	// decode the utf8
-	r, size := u.add(buf[0])
-	if size == 0 {
-		// incomplete utf8 code point
-		return KeycodeNull
-	}
-	if size == 1 && r == unicode.ReplacementChar {
-		// utf8 decode error
-		return KeycodeNull
+		u.err = err
+		return keycodeError
 	}
+	// decode the utf8, an incomplete code point returns KeycodeNull
+	r, _ := u.add(c)
 	return r
 }
 
 //-----------------------------------------------------------------------------
+// File descriptor IO
+
+// keycode returned by getRune on a read error
+const keycodeError = -2
+
+// Read a byte from the file descriptor.
+func readByte(fd int) (byte, error) {
+	var buf [1]byte
+	n, err := readFd(fd, buf[:])
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return buf[0], nil
+}
+
+// Write a buffer to the file descriptor, return the number of bytes written.
+func writeAll(fd int, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		k, err := writeFd(fd, buf[n:])
+		if err != nil {
+			return n, err
+		}
+		n += k
+	}
+	return n, nil
+}
 
 // If fd is not readable within the timeout period return true.
 func wouldBlock(fd int, timeout *syscall.Timeval) bool {
-	rd := syscall.FdSet{}
-	fdset.Set(fd, &rd)
-	n, err := syscall.Select(fd+1, &rd, nil, nil, timeout)
+	rfd, err := selectRead([]int{fd}, timeout)
 	if err != nil {
 		log.Printf("select error %s\n", err)
 		return false
 	}
-	return n == 0
+	return rfd < 0
 }
 
 // Write a string to the file descriptor, return the number of bytes written.
 func puts(fd int, s string) int {
-	n, err := syscall.Write(fd, []byte(s))
+	n, err := writeAll(fd, []byte(s))
 	if err != nil {
-		panic(fmt.Sprintf("puts error %s\n", err))
+		log.Printf("puts error %s\n", err)
 	}
 	return n
 }
@@ -264,7 +356,7 @@ func getCursorPosition(ifd, ofd int) int {
 
 	for len(buf) < 32 {
 		r := u.getRune(ifd, &timeout20ms)
-		if r == KeycodeNull {
+		if r == KeycodeNull || r == keycodeError {
 			break
 		}
 		buf = append(buf, r)
@@ -291,13 +383,11 @@ func getCursorPosition(ifd, ofd int) int {
 
 // Get the number of columns for the terminal. Assume defaultCols if it fails.
 func getColumns(ifd, ofd int) int {
-	// try using the ioctl to get the number of cols
-	var winsize [4]uint16
-	_, _, err := syscall.Syscall(syscall.SYS_IOCTL, uintptr(syscall.Stdout), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&winsize)))
-	if err == 0 {
-		return int(winsize[1])
+	// try the terminal size from the system
+	if cols, _, ok := termSize(); ok && cols > 0 {
+		return cols
 	}
-	// the ioctl failed - try using the terminal itself
+	// that failed - try using the terminal itself
 	start := getCursorPosition(ifd, ofd)
 	if start < 0 {
 		return defaultCols
@@ -317,16 +407,42 @@ func getColumns(ifd, ofd int) int {
 	return cols
 }
 
-//-----------------------------------------------------------------------------
+// Return the number of columns for stdout. Assume defaultCols if it's not a terminal.
+func termColumns() int {
+	if !isTerminal(stdoutFd) {
+		return defaultCols
+	}
+	return getColumns(stdinFd, stdoutFd)
+}
 
-// Clear the screen.
-func clearScreen() {
-	puts(syscall.Stdout, "\x1b[H\x1b[2J")
+// Return the number of rows for stdout. Return 0 if it's not a terminal.
+func termRows() int {
+	if !isTerminal(stdoutFd) {
+		return 0
+	}
+	_, rows, ok := termSize()
+	if !ok {
+		return 0
+	}
+	return rows
+}
+
+// Return true if the terminal can display unicode glyphs.
+func termUnicode() bool {
+	for _, env := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if s := os.Getenv(env); s != "" {
+			s = strings.ToUpper(s)
+			return strings.Contains(s, "UTF-8") || strings.Contains(s, "UTF8")
+		}
+	}
+	return false
 }
 
+//-----------------------------------------------------------------------------
+
 // Beep.
 func beep() {
-	puts(syscall.Stderr, "\x07")
+	puts(stderrFd, "\x07")
 }
 
 //-----------------------------------------------------------------------------
@@ -346,16 +462,33 @@ func unsupportedTerm() bool {
 //-----------------------------------------------------------------------------
 
 type linestate struct {
-	ifd, ofd     int        // stdin/stdout file descriptors
-	prompt       string     // prompt string
-	promptWidth  int        // prompt width in terminal columns
-	ts           *Linenoise // terminal state
-	historyIndex int        // history index we are currently editing, 0 is the LAST entry
-	buf          []rune     // line buffer
-	cols         int        // number of columns in terminal
-	pos          int        // current cursor position within line buffer
-	oldpos       int        // previous refresh cursor position (multiline)
-	maxrows      int        // maximum num of rows used so far (multiline)
+	ifd, ofd     int         // stdin/stdout file descriptors
+	prompt       string      // prompt string
+	promptWidth  int         // prompt width in terminal columns
+	ts           *Linenoise  // terminal state
+	historyIndex int         // history index we are currently editing, 0 is the LAST entry
+	buf          []rune      // line buffer
+	cols         int         // number of columns in terminal
+	pos          int         // current cursor position within line buffer
+	oldpos       int         // previous refresh cursor column (multiline)
+	maxrows      int         // maximum num of rows used so far (multiline)
+	viNormal     bool        // are we in vi normal mode?
+	selStart     int         // start of highlighted buffer text
+	selEnd       int         // end of highlighted buffer text
+	label        *Hint       // label of the completion being shown
+	escNext      rune        // key read after an escape, KeycodeNull for none
+	undo         []editState // line states for undo
+	redo         []editState // line states for redo
+	typing       bool        // inserting characters, grouped as one undo step
+	yanked       bool        // the last key inserted a previous argument
+	yankIdx      int         // history index of the inserted argument
+	yankStart    int         // buffer position of the inserted argument
+}
+
+// editState is a line buffer state saved for undo/redo.
+type editState struct {
+	buf []rune // line buffer
+	pos int    // cursor position
 }
 
 func newLineState(ifd, ofd int, prompt string, ts *Linenoise) *linestate {
@@ -363,50 +496,161 @@ func newLineState(ifd, ofd int, prompt string, ts *Linenoise) *linestate {
 	ls.ifd = ifd
 	ls.ofd = ofd
 	ls.prompt = prompt
-	ls.promptWidth = runewidth.StringWidth(prompt)
+	ls.promptWidth = promptWidth(prompt)
 	ls.ts = ts
-	ls.cols = getColumns(ifd, ofd)
+	ls.cols = ts.columns(ifd, ofd)
 	return &ls
 }
 
-// show hints to the right of the cursor
-func (ls *linestate) refreshShowHints() []string {
-	// do we have a hints callback?
-	if ls.ts.hintsCallback == nil {
+// Write a byte buffer to the line editing output.
+func (ls *linestate) write(b []byte) {
+	if st := ls.ts.stats; st != nil {
+		ls.ts.count(&st.Writes, 1)
+		ls.ts.count(&st.Bytes, len(b))
+	}
+	if ls.ts.output != nil {
+		ls.ts.output.Write(b)
+		return
+	}
+	_, err := writeAll(ls.ofd, b)
+	if err != nil {
+		log.Printf("write error %s\n", err)
+	}
+}
+
+// Write a string to the line editing output.
+func (ls *linestate) puts(s string) {
+	if st := ls.ts.stats; st != nil {
+		ls.ts.count(&st.Writes, 1)
+		ls.ts.count(&st.Bytes, len(s))
+	}
+	if ls.ts.output != nil {
+		io.WriteString(ls.ts.output, s)
+		return
+	}
+	puts(ls.ofd, s)
+}
+
+// Beep to indicate an error.
+func (ls *linestate) beep() {
+	if ls.ts.output != nil {
+		ls.puts("\x07")
+		return
+	}
+	beep()
+}
+
+// Clear the screen.
+func (ls *linestate) clearScreen() {
+	ls.puts("\x1b[H\x1b[2J")
+}
+
+// Return the display width of a prompt. ANSI escape sequences have no width.
+func promptWidth(prompt string) int {
+	var sb strings.Builder
+	esc := false
+	for _, r := range prompt {
+		if esc {
+			// CSI sequences end with a letter
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+				esc = false
+			}
+			continue
+		}
+		if r == KeycodeESC {
+			esc = true
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return runewidth.StringWidth(sb.String())
+}
+
+// Append runes to a byte buffer.
+func appendRunes(b []byte, rs []rune) []byte {
+	for _, r := range rs {
+		b = append(b, string(r)...)
+	}
+	return b
+}
+
+// Append a CSI escape sequence with a numeric parameter. Eg. ESC [ n C
+func appendCSI(b []byte, n int, cmd byte) []byte {
+	b = append(b, "\x1b["...)
+	b = strconv.AppendInt(b, int64(n), 10)
+	return append(b, cmd)
+}
+
+// Append hints to the right of the cursor.
+func (ls *linestate) appendHints(b []byte, bufWidth int) []byte {
+	// do we have a completion label or a hints callback?
+	if (ls.label == nil && ls.ts.hintsCallback == nil) || ls.ts.masked {
 		// no hints
-		return nil
+		return b
 	}
 	// How many columns do we have for the hint?
-	hintCols := ls.cols - ls.promptWidth - runewidth.StringWidth(string(ls.buf))
+	hintCols := ls.cols - ls.promptWidth - bufWidth
 	if hintCols <= 0 {
 		// no space to display hints
-		return nil
+		return b
 	}
 	// get the hint
-	h := ls.ts.hintsCallback(string(ls.buf))
+	h := ls.label
+	if h == nil {
+		h = ls.ts.hintsCallback(string(ls.buf))
+	}
 	if h == nil || len(h.Hint) == 0 {
 		// no hints
-		return nil
-	}
-	// trim the hint until it fits
-	hEnd := len(h.Hint)
-	for runewidth.StringWidth(h.Hint[:hEnd]) > hintCols {
-		hEnd--
+		return b
 	}
 	// color fixup
 	if h.Bold && h.Color < 0 {
 		h.Color = 37
 	}
-	// build the output string
-	seq := make([]string, 0, 3)
-	if h.Color >= 0 || h.Bold {
-		seq = append(seq, fmt.Sprintf("\033[%d;%d;49m", btoi(h.Bold), h.Color))
+	color := (h.Color >= 0 || h.Bold) && ls.ts.color != ColorOff
+	if color {
+		b = append(b, "\033["...)
+		b = strconv.AppendInt(b, int64(btoi(h.Bold)), 10)
+		b = append(b, ';')
+		b = strconv.AppendInt(b, int64(h.Color), 10)
+		b = append(b, ";49m"...)
 	}
-	seq = append(seq, h.Hint[:hEnd])
-	if h.Color >= 0 || h.Bold {
-		seq = append(seq, "\033[0m")
+	// trim the hint until it fits
+	b = append(b, TruncateWidth(h.Hint, hintCols)...)
+	if color {
+		b = append(b, "\033[0m"...)
+	}
+	return b
+}
+
+// Append the buffer text to be rendered with any highlighted text in reverse video.
+func (ls *linestate) appendBuf(b []byte, bStart, bEnd int) []byte {
+	s0, s1 := ls.selStart, ls.selEnd
+	if s0 < bStart {
+		s0 = bStart
+	}
+	if s1 > bEnd {
+		s1 = bEnd
+	}
+	if !ls.ts.colorEnabled() {
+		return appendRunes(b, ls.buf[bStart:bEnd])
+	}
+	if s0 >= s1 {
+		if m := ls.bracketMatch(); m >= bStart && m < bEnd {
+			// highlight the matching bracket
+			b = appendRunes(b, ls.buf[bStart:m])
+			b = append(b, "\x1b[1;4m"...)
+			b = appendRunes(b, ls.buf[m:m+1])
+			b = append(b, "\x1b[0m"...)
+			return appendRunes(b, ls.buf[m+1:bEnd])
+		}
+		return appendRunes(b, ls.buf[bStart:bEnd])
 	}
-	return seq
+	b = appendRunes(b, ls.buf[bStart:s0])
+	b = append(b, "\x1b[7m"...)
+	b = appendRunes(b, ls.buf[s0:s1])
+	b = append(b, "\x1b[0m"...)
+	return appendRunes(b, ls.buf[s1:bEnd])
 }
 
 // single line refresh
@@ -415,38 +659,43 @@ func (ls *linestate) refreshSingleline() {
 	bStart := 0
 	bEnd := len(ls.buf)
 	// trim the left hand side to keep the cursor position on the screen
-	posWidth := runewidth.StringWidth(string(ls.buf[:ls.pos]))
-	for ls.promptWidth+posWidth >= ls.cols {
-		bStart++
-		posWidth = runewidth.StringWidth(string(ls.buf[bStart:ls.pos]))
+	posWidth := runesWidth(ls.buf[:ls.pos])
+	for ls.promptWidth+posWidth >= ls.cols && bStart < ls.pos {
+		n := graphemeNext(ls.buf, bStart)
+		posWidth -= runesWidth(ls.buf[bStart:n])
+		bStart = n
 	}
 	// trim the right hand side - don't print beyond max columns
-	bufWidth := runewidth.StringWidth(string(ls.buf[bStart:bEnd]))
-	for ls.promptWidth+bufWidth >= ls.cols {
-		bEnd--
-		bufWidth = runewidth.StringWidth(string(ls.buf[bStart:bEnd]))
-	}
-	// build the output string
-	seq := make([]string, 0, 6)
+	bufWidth := posWidth + runesWidth(ls.buf[ls.pos:bEnd])
+	for ls.promptWidth+bufWidth >= ls.cols && bEnd > ls.pos {
+		n := graphemePrev(ls.buf, bEnd)
+		bufWidth -= runesWidth(ls.buf[n:bEnd])
+		bEnd = n
+	}
+	// build the output string in the reused output buffer
+	b := ls.ts.obuf[:0]
 	// cursor to the left edge
-	seq = append(seq, "\r")
+	b = append(b, '\r')
 	// write the prompt
-	seq = append(seq, ls.prompt)
+	b = append(b, ls.prompt...)
 	// write the current buffer content
-	seq = append(seq, string(ls.buf[bStart:bEnd]))
+	b = ls.appendBuf(b, bStart, bEnd)
 	// Show hints (if any)
-	seq = append(seq, ls.refreshShowHints()...)
+	b = ls.appendHints(b, runesWidth(ls.buf))
 	// Erase to right
-	seq = append(seq, "\x1b[0K")
+	b = append(b, "\x1b[0K"...)
 	// Move cursor to original position
-	seq = append(seq, fmt.Sprintf("\r\x1b[%dC", ls.promptWidth+posWidth))
+	b = append(b, '\r')
+	b = appendCSI(b, ls.promptWidth+posWidth, 'C')
 	// write it out
-	puts(ls.ofd, strings.Join(seq, ""))
+	ls.write(b)
+	ls.ts.obuf = b
 }
 
 // multiline refresh
 func (ls *linestate) refreshMultiline() {
-	bufWidth := runewidth.StringWidth(string(ls.buf))
+	bufWidth := runesWidth(ls.buf)
+	posWidth := runesWidth(ls.buf[:ls.pos])
 	oldRows := ls.maxrows
 	// cursor position relative to row
 	rpos := (ls.promptWidth + ls.oldpos + ls.cols) / ls.cols
@@ -456,53 +705,93 @@ func (ls *linestate) refreshMultiline() {
 	if rows > ls.maxrows {
 		ls.maxrows = rows
 	}
-	// build the output string
-	seq := make([]string, 0, 15)
+	// build the output string in the reused output buffer
+	b := ls.ts.obuf[:0]
 	// First step: clear all the lines used before. To do so start by going to the last row.
 	if oldRows-rpos > 0 {
-		seq = append(seq, fmt.Sprintf("\x1b[%dB", oldRows-rpos))
+		b = appendCSI(b, oldRows-rpos, 'B')
 	}
 	// Now for every row clear it, go up.
 	for j := 0; j < oldRows-1; j++ {
-		seq = append(seq, "\r\x1b[0K\x1b[1A")
+		b = append(b, "\r\x1b[0K\x1b[1A"...)
 	}
 	// Clear the top line.
-	seq = append(seq, "\r\x1b[0K")
+	b = append(b, "\r\x1b[0K"...)
 	// Write the prompt and the current buffer content
-	seq = append(seq, ls.prompt)
-	seq = append(seq, string(ls.buf))
+	b = append(b, ls.prompt...)
+	b = ls.appendBuf(b, 0, len(ls.buf))
 	// Show hints (if any)
-	seq = append(seq, ls.refreshShowHints()...)
+	b = ls.appendHints(b, bufWidth)
 	// If we are at the very end of the screen with our prompt, we need to
 	// emit a newline and move the prompt to the first column.
-	if ls.pos != 0 && ls.pos == bufWidth && (ls.pos+ls.promptWidth)%ls.cols == 0 {
-		seq = append(seq, "\n\r")
+	if posWidth != 0 && posWidth == bufWidth && (posWidth+ls.promptWidth)%ls.cols == 0 {
+		b = append(b, "\n\r"...)
 		rows++
 		if rows > ls.maxrows {
 			ls.maxrows = rows
 		}
 	}
 	// Move cursor to right position.
-	rpos2 := (ls.promptWidth + ls.pos + ls.cols) / ls.cols // current cursor relative row.
+	rpos2 := (ls.promptWidth + posWidth + ls.cols) / ls.cols // current cursor relative row.
 	// Go up till we reach the expected position.
 	if rows-rpos2 > 0 {
-		seq = append(seq, fmt.Sprintf("\x1b[%dA", rows-rpos2))
+		b = appendCSI(b, rows-rpos2, 'A')
 	}
 	// Set column
-	col := (ls.promptWidth + ls.pos) % ls.cols
+	col := (ls.promptWidth + posWidth) % ls.cols
+	b = append(b, '\r')
 	if col != 0 {
-		seq = append(seq, fmt.Sprintf("\r\x1b[%dC", col))
-	} else {
-		seq = append(seq, "\r")
+		b = appendCSI(b, col, 'C')
 	}
 	// save the cursor position
-	ls.oldpos = ls.pos
+	ls.oldpos = posWidth
 	// write it out
-	puts(ls.ofd, strings.Join(seq, ""))
+	ls.write(b)
+	ls.ts.obuf = b
+}
+
+// Clear the edit line from the screen, leaving the cursor at the left edge.
+func (ls *linestate) clearLine() {
+	if !ls.ts.mlmode {
+		ls.puts("\r\x1b[0K")
+		return
+	}
+	seq := make([]string, 0, 8)
+	// go to the last row
+	rpos := (ls.promptWidth + ls.oldpos + ls.cols) / ls.cols
+	if ls.maxrows-rpos > 0 {
+		seq = append(seq, fmt.Sprintf("\x1b[%dB", ls.maxrows-rpos))
+	}
+	// clear every row going up
+	for j := 0; j < ls.maxrows-1; j++ {
+		seq = append(seq, "\r\x1b[0K\x1b[1A")
+	}
+	seq = append(seq, "\r\x1b[0K")
+	ls.puts(strings.Join(seq, ""))
+	// the next refresh starts from scratch
+	ls.maxrows = 0
+	ls.oldpos = 0
 }
 
 // refresh the edit line
 func (ls *linestate) refreshLine() {
+	if ls.ts.masked {
+		// display a masked line buffer
+		buf, pos := ls.buf, ls.pos
+		if ls.ts.maskChar == 0 {
+			// no echo
+			ls.buf, ls.pos = nil, 0
+		} else {
+			ls.buf = []rune(repeat(ls.ts.maskChar, len(buf)))
+		}
+		defer func() { ls.buf, ls.pos = buf, pos }()
+	}
+	if ls.ts.trace != nil {
+		ls.ts.tracef("refresh multiline %t buf %q pos %d cols %d", ls.ts.mlmode, string(ls.buf), ls.pos, ls.cols)
+	}
+	if st := ls.ts.stats; st != nil {
+		ls.ts.count(&st.Refreshes, 1)
+	}
 	if ls.ts.mlmode {
 		ls.refreshMultiline()
 	} else {
@@ -510,10 +799,59 @@ func (ls *linestate) refreshLine() {
 	}
 }
 
+// Return a copy of the line state.
+func (ls *linestate) state() editState {
+	return editState{append([]rune(nil), ls.buf...), ls.pos}
+}
+
+// Save a line state so the following edit can be undone.
+func (ls *linestate) pushUndo(s editState) {
+	ls.undo = append(ls.undo, s)
+	ls.redo = nil
+	ls.typing = false
+}
+
+// Save the line state before an edit.
+func (ls *linestate) saveUndo() {
+	ls.pushUndo(ls.state())
+}
+
+// Undo the last edit.
+func (ls *linestate) editUndo() {
+	n := len(ls.undo)
+	if n == 0 {
+		ls.beep()
+		return
+	}
+	ls.redo = append(ls.redo, ls.state())
+	s := ls.undo[n-1]
+	ls.undo = ls.undo[:n-1]
+	ls.buf, ls.pos = s.buf, s.pos
+	ls.typing = false
+	ls.refreshLine()
+}
+
+// Redo the last undone edit.
+func (ls *linestate) editRedo() {
+	n := len(ls.redo)
+	if n == 0 {
+		ls.beep()
+		return
+	}
+	ls.undo = append(ls.undo, ls.state())
+	s := ls.redo[n-1]
+	ls.redo = ls.redo[:n-1]
+	ls.buf, ls.pos = s.buf, s.pos
+	ls.typing = false
+	ls.refreshLine()
+}
+
 // delete the character at the current cursor position
 func (ls *linestate) editDelete() {
 	if len(ls.buf) > 0 && ls.pos < len(ls.buf) {
-		ls.buf = append(ls.buf[:ls.pos], ls.buf[ls.pos+1:]...)
+		ls.saveUndo()
+		n := graphemeNext(ls.buf, ls.pos)
+		ls.buf = append(ls.buf[:ls.pos], ls.buf[n:]...)
 		ls.refreshLine()
 	}
 }
@@ -521,34 +859,187 @@ func (ls *linestate) editDelete() {
 // delete the character to the left of the current cursor position
 func (ls *linestate) editBackspace() {
 	if ls.pos > 0 && len(ls.buf) > 0 {
-		ls.buf = append(ls.buf[:ls.pos-1], ls.buf[ls.pos:]...)
-		ls.pos--
+		ls.saveUndo()
+		n := graphemePrev(ls.buf, ls.pos)
+		ls.buf = append(ls.buf[:n], ls.buf[ls.pos:]...)
+		ls.pos = n
 		ls.refreshLine()
 	}
 }
 
 // insert a character at the current cursor position
+// In overwrite mode the character at the cursor is replaced.
 func (ls *linestate) editInsert(r rune) {
-	ls.buf = append(ls.buf[:ls.pos], append([]rune{r}, ls.buf[ls.pos:]...)...)
+	if !ls.typing {
+		// consecutive characters are undone together
+		ls.saveUndo()
+		ls.typing = true
+	}
+	if ls.ts.overwrite && ls.pos < len(ls.buf) {
+		// remove the character being replaced
+		n := graphemeNext(ls.buf, ls.pos)
+		ls.buf = append(ls.buf[:ls.pos], ls.buf[n:]...)
+	}
+	ls.buf = append(ls.buf, 0)
+	copy(ls.buf[ls.pos+1:], ls.buf[ls.pos:])
+	ls.buf[ls.pos] = r
 	ls.pos++
 	ls.refreshLine()
 }
 
+// Toggle overwrite/insert mode and redraw the line with the mode prompt.
+func (ls *linestate) toggleOverwrite() {
+	l := ls.ts
+	l.overwrite = !l.overwrite
+	l.tracef("overwrite %v", l.overwrite)
+	if l.overwriteCallback != nil && !l.masked {
+		if p := l.overwriteCallback(l.overwrite); p != "" {
+			ls.clearLine()
+			ls.prompt = p
+			ls.promptWidth = promptWidth(p)
+		}
+	}
+	ls.refreshLine()
+}
+
+// Insert the last argument of the previous history entry. Repeating the
+// key replaces it with the last argument of successively older entries.
+func (ls *linestate) yankLastArg(repeat bool) {
+	l := ls.ts
+	if l.masked {
+		return
+	}
+	idx := 1
+	if repeat {
+		idx = ls.yankIdx + 1
+	}
+	// history index 0 is the line being edited
+	for ; idx < len(l.history); idx++ {
+		args := strings.Fields(l.historyGet(idx))
+		if len(args) == 0 {
+			continue
+		}
+		if repeat {
+			// remove the previously inserted argument
+			ls.buf = append(ls.buf[:ls.yankStart], ls.buf[ls.pos:]...)
+			ls.pos = ls.yankStart
+		} else {
+			ls.saveUndo()
+			ls.yankStart = ls.pos
+		}
+		arg := []rune(args[len(args)-1])
+		ls.buf = append(ls.buf[:ls.pos], append(arg, ls.buf[ls.pos:]...)...)
+		ls.pos += len(arg)
+		ls.yankIdx = idx
+		ls.yanked = true
+		ls.typing = false
+		ls.refreshLine()
+		return
+	}
+	// no older arguments, keep the last one for another repeat
+	ls.yanked = repeat
+}
+
+// Move the cursor to the next (or previous) occurrence of a character.
+// Only a grapheme cluster of the character alone matches.
+func (ls *linestate) charSearch(c rune, forward bool) {
+	// moving the cursor ends the current undo step
+	ls.typing = false
+	if forward {
+		for i := graphemeNext(ls.buf, ls.pos); i < len(ls.buf); {
+			j := graphemeNext(ls.buf, i)
+			if j == i+1 && ls.buf[i] == c {
+				ls.pos = i
+				ls.refreshLine()
+				return
+			}
+			i = j
+		}
+		return
+	}
+	for j := ls.pos; j > 0; {
+		i := graphemePrev(ls.buf, j)
+		if j == i+1 && ls.buf[i] == c {
+			ls.pos = i
+			ls.refreshLine()
+			return
+		}
+		j = i
+	}
+}
+
 // Swap current character with the previous character.
 func (ls *linestate) editSwap() {
 	if ls.pos > 0 && ls.pos < len(ls.buf) {
-		tmp := ls.buf[ls.pos-1]
-		ls.buf[ls.pos-1] = ls.buf[ls.pos]
-		ls.buf[ls.pos] = tmp
-		if ls.pos != len(ls.buf)-1 {
-			ls.pos++
+		ls.saveUndo()
+		a := graphemePrev(ls.buf, ls.pos)
+		b := graphemeNext(ls.buf, ls.pos)
+		s := append(append([]rune{}, ls.buf[ls.pos:b]...), ls.buf[a:ls.pos]...)
+		copy(ls.buf[a:b], s)
+		if b != len(ls.buf) {
+			ls.pos = b
+		} else {
+			// stay on the last character
+			ls.pos = a + b - ls.pos
 		}
 		ls.refreshLine()
 	}
 }
 
+// Insert pasted text literally up to the end of paste sequence. Line breaks
+// and tabs are inserted as spaces, other control characters are dropped.
+// Returns keycodeError for a read error.
+func (ls *linestate) editPaste(u *utf8) rune {
+	var text []rune
+	// length of the matched end of paste sequence
+	k := 0
+	for k < len(pasteEnd) {
+		r := ls.ts.getRune(u, ls.ifd, nil)
+		if r == keycodeError {
+			return r
+		}
+		if r == keycodeAsync {
+			ls.asyncFlush()
+			continue
+		}
+		if r == rune(pasteEnd[k]) {
+			k++
+			continue
+		}
+		if k > 0 {
+			// not the end sequence after all
+			text = append(text, []rune(pasteEnd[:k])...)
+			k = 0
+			if r == rune(pasteEnd[0]) {
+				k = 1
+				continue
+			}
+		}
+		text = append(text, r)
+	}
+	buf := make([]rune, 0, len(text))
+	for _, r := range strings.Replace(string(text), "\r\n", "\n", -1) {
+		if r == '\r' || r == '\n' || r == '\t' {
+			r = ' '
+		} else if !unicode.IsPrint(r) {
+			continue
+		}
+		buf = append(buf, r)
+	}
+	if len(buf) == 0 {
+		return KeycodeNull
+	}
+	// insert the paste as one undo step
+	ls.saveUndo()
+	ls.buf = append(ls.buf[:ls.pos], append(buf, ls.buf[ls.pos:]...)...)
+	ls.pos += len(buf)
+	ls.refreshLine()
+	return KeycodeNull
+}
+
 // Set the line buffer to a string.
 func (ls *linestate) editSet(s string) {
+	ls.saveUndo()
 	ls.buf = []rune(s)
 	ls.pos = len(ls.buf)
 	ls.refreshLine()
@@ -557,7 +1048,8 @@ func (ls *linestate) editSet(s string) {
 // Move cursor on the left.
 func (ls *linestate) editMoveLeft() {
 	if ls.pos > 0 {
-		ls.pos--
+		ls.pos = graphemePrev(ls.buf, ls.pos)
+		ls.typing = false
 		ls.refreshLine()
 	}
 }
@@ -565,7 +1057,8 @@ func (ls *linestate) editMoveLeft() {
 // Move cursor to the right.
 func (ls *linestate) editMoveRight() {
 	if ls.pos != len(ls.buf) {
-		ls.pos++
+		ls.pos = graphemeNext(ls.buf, ls.pos)
+		ls.typing = false
 		ls.refreshLine()
 	}
 }
@@ -574,6 +1067,7 @@ func (ls *linestate) editMoveRight() {
 func (ls *linestate) editMoveHome() {
 	if ls.pos > 0 {
 		ls.pos = 0
+		ls.typing = false
 		ls.refreshLine()
 	}
 }
@@ -582,12 +1076,16 @@ func (ls *linestate) editMoveHome() {
 func (ls *linestate) editMoveEnd() {
 	if ls.pos != len(ls.buf) {
 		ls.pos = len(ls.buf)
+		ls.typing = false
 		ls.refreshLine()
 	}
 }
 
 // Delete the line.
 func (ls *linestate) deleteLine() {
+	if len(ls.buf) > 0 {
+		ls.saveUndo()
+	}
 	ls.buf = nil // []rune{}
 	ls.pos = 0
 	ls.refreshLine()
@@ -595,12 +1093,62 @@ func (ls *linestate) deleteLine() {
 
 // Delete from the current cursor position to the end of the line.
 func (ls *linestate) deleteToEnd() {
+	if ls.pos < len(ls.buf) {
+		ls.saveUndo()
+	}
 	ls.buf = ls.buf[:ls.pos]
 	ls.refreshLine()
 }
 
+// Swap the space delimited word under (or before) the cursor with the
+// previous word. The cursor moves to the end of the swapped words.
+func (ls *linestate) editSwapWords() {
+	// the word under or before the cursor
+	e2 := ls.pos
+	if e2 < len(ls.buf) && ls.buf[e2] != ' ' {
+		for e2 < len(ls.buf) && ls.buf[e2] != ' ' {
+			e2++
+		}
+	} else {
+		for e2 > 0 && ls.buf[e2-1] == ' ' {
+			e2--
+		}
+	}
+	s2 := e2
+	for s2 > 0 && ls.buf[s2-1] != ' ' {
+		s2--
+	}
+	// the previous word
+	e1 := s2
+	for e1 > 0 && ls.buf[e1-1] == ' ' {
+		e1--
+	}
+	s1 := e1
+	for s1 > 0 && ls.buf[s1-1] != ' ' {
+		s1--
+	}
+	if s1 == e1 || s2 == e2 {
+		// not two words
+		return
+	}
+	ls.saveUndo()
+	buf := make([]rune, 0, len(ls.buf))
+	buf = append(buf, ls.buf[:s1]...)
+	buf = append(buf, ls.buf[s2:e2]...)
+	buf = append(buf, ls.buf[e1:s2]...)
+	buf = append(buf, ls.buf[s1:e1]...)
+	buf = append(buf, ls.buf[e2:]...)
+	ls.buf = buf
+	ls.pos = e2
+	ls.typing = false
+	ls.refreshLine()
+}
+
 // Delete the previous space delimited word.
 func (ls *linestate) deletePrevWord() {
+	if ls.pos > 0 {
+		ls.saveUndo()
+	}
 	oldPos := ls.pos
 	// remove spaces
 	for ls.pos > 0 && ls.buf[ls.pos-1] == ' ' {
@@ -614,13 +1162,51 @@ func (ls *linestate) deletePrevWord() {
 	ls.refreshLine()
 }
 
+// Handle a key in vi normal mode.
+func (ls *linestate) editViNormal(r rune) {
+	defer func() {
+		if !ls.viNormal {
+			ls.ts.tracef("vi insert mode")
+		}
+	}()
+	switch r {
+	case 'h':
+		ls.editMoveLeft()
+	case 'l':
+		ls.editMoveRight()
+	case '0', '^':
+		ls.editMoveHome()
+	case '$':
+		ls.editMoveEnd()
+	case 'x':
+		ls.editDelete()
+	case 'D':
+		ls.deleteToEnd()
+	case 'u':
+		ls.editUndo()
+	case 'i':
+		ls.viNormal = false
+	case 'a':
+		ls.viNormal = false
+		ls.editMoveRight()
+	case 'I':
+		ls.viNormal = false
+		ls.editMoveHome()
+	case 'A':
+		ls.viNormal = false
+		ls.editMoveEnd()
+	default:
+		ls.beep()
+	}
+}
+
 // Show completions for the current line.
 func (ls *linestate) completeLine() rune {
 	// get a list of line completions
 	lc := ls.ts.completionCallback(ls.String())
 	if len(lc) == 0 {
 		// no line completions
-		beep()
+		ls.beep()
 		return KeycodeNull
 	}
 	// navigate and display the line completions
@@ -634,30 +1220,44 @@ func (ls *linestate) completeLine() rune {
 			savedBuf := ls.buf
 			savedPos := ls.pos
 			// show the completion
-			ls.buf = []rune(lc[idx])
+			ls.ts.tracef("completion %d/%d %q", idx, len(lc), lc[idx])
+			ls.buf = []rune(ls.completionDisplay(lc[idx], savedBuf))
 			ls.pos = len(ls.buf)
+			// highlight the suggested text
+			ls.selStart = commonPrefix(savedBuf, ls.buf)
+			ls.selEnd = len([]rune(strings.TrimRight(lc[idx], " ")))
+			if ls.ts.completionLabel != nil {
+				ls.label = ls.ts.completionLabel(lc[idx])
+			}
 			ls.refreshLine()
 			// restore the line buffer
 			ls.buf = savedBuf
 			ls.pos = savedPos
+			ls.selStart = 0
+			ls.selEnd = 0
+			ls.label = nil
 		} else {
 			// show the original buffer
 			ls.refreshLine()
 		}
 		// navigate through the completions
-		r = u.getRune(ls.ifd, nil)
-		if r == KeycodeNull {
+		r = ls.ts.getRune(&u, ls.ifd, nil)
+		if r == keycodeAsync {
+			ls.asyncFlush()
+			continue
+		}
+		if r == KeycodeNull || r == keycodeError {
 			// error on read
 			stop = true
 		} else if r == KeycodeTAB {
 			// loop through the completions
 			idx = (idx + 1) % (len(lc) + 1)
 			if idx == len(lc) {
-				beep()
+				ls.beep()
 			}
 		} else if r == KeycodeESC {
 			// could be an escape, could be an escape sequence
-			if wouldBlock(ls.ifd, &timeout20ms) {
+			if ls.ts.wouldBlock(ls.ifd, &timeout20ms) {
 				// nothing more to read, looks like a single escape
 				// re-show the original buffer
 				if idx < len(lc) {
@@ -669,6 +1269,7 @@ func (ls *linestate) completeLine() rune {
 				// probably an escape sequence
 				// update the buffer and return
 				if idx < len(lc) {
+					ls.saveUndo()
 					ls.buf = []rune(lc[idx])
 					ls.pos = len(ls.buf)
 				}
@@ -677,6 +1278,7 @@ func (ls *linestate) completeLine() rune {
 		} else {
 			// update the buffer and return
 			if idx < len(lc) {
+				ls.saveUndo()
 				ls.buf = []rune(lc[idx])
 				ls.pos = len(ls.buf)
 			}
@@ -687,51 +1289,235 @@ func (ls *linestate) completeLine() rune {
 	return r
 }
 
-// Return a string for the current line buffer.
-func (ls *linestate) String() string {
-	return string(ls.buf)
-}
-
-//-----------------------------------------------------------------------------
-
-// Linenoise stores line editor state.
-type Linenoise struct {
-	history            []string              // list of history strings
-	historyMaxlen      int                   // maximum number of history entries
-	rawmode            bool                  // are we in raw mode?
-	mlmode             bool                  // are we in multiline mode?
-	savedmode          *raw.Termios          // saved terminal mode
-	completionCallback func(string) []string // callback function for tab completion
-	hintsCallback      func(string) *Hint    // callback function for hints
-	hotkey             rune                  // character for hotkey
-	scanner            *bufio.Scanner        // buffered IO scanner for file reading
-}
-
-// NewLineNoise returns a new line editor.
-func NewLineNoise() *Linenoise {
-	l := Linenoise{}
-	l.historyMaxlen = 32
-	return &l
+// Return the display string for a line completion.
+func (ls *linestate) completionDisplay(s string, buf []rune) string {
+	if !ls.ts.completionPad {
+		return s
+	}
+	// Pad the completion to the width of the line buffer.
+	// We don't want the cursor to move about unecessarily.
+	return PadWidth(s, runesWidth(buf))
 }
 
-// Enable raw mode
-func (l *Linenoise) enableRawMode(fd int) error {
-	mode, err := setRawMode(fd)
-	if err != nil {
-		return err
+// Search the history for a string, starting at a history index and going back.
+// Returns the history index and rune position of the match, or -1 for no match.
+func (l *Linenoise) historySearch(s string, idx int) (int, int) {
+	for ; idx < len(l.history); idx++ {
+		line := l.historyGet(idx)
+		if k := strings.Index(line, s); k >= 0 {
+			return idx, len([]rune(line[:k]))
+		}
 	}
-	l.rawmode = true
-	l.savedmode = mode
-	return nil
+	return -1, 0
 }
 
-// Disable raw mode
-func (l *Linenoise) disableRawMode(fd int) error {
-	if l.rawmode {
+// Reverse incremental history search.
+// Like completeLine it returns the key to be handled next.
+func (ls *linestate) searchHistory() rune {
+	// save the line state
+	savedPrompt := ls.prompt
+	savedBuf := ls.buf
+	savedPos := ls.pos
+	var query []rune
+	// history index 0 is the line being edited, start the search before it
+	idx := 0
+	failed := false
+	u := utf8{}
+	var r rune
+	for {
+		// show the search prompt and the current match
+		if failed {
+			ls.prompt = "(failed reverse-i-search)`" + string(query) + "': "
+		} else {
+			ls.prompt = "(reverse-i-search)`" + string(query) + "': "
+		}
+		ls.promptWidth = promptWidth(ls.prompt)
+		ls.selEnd = ls.selStart + len(query)
+		ls.refreshLine()
+		r = ls.ts.getRune(&u, ls.ifd, nil)
+		if r == keycodeAsync {
+			ls.asyncFlush()
+			continue
+		}
+		if r == KeycodeNull || r == keycodeError {
+			// error on read
+			break
+		}
+		start := idx
+		if r == KeycodeCtrlR {
+			// search for an older match
+			start = idx + 1
+		} else if r == KeycodeBS || r == KeycodeCtrlH {
+			// remove the last search character and search from the start
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+			}
+			start = 0
+		} else if r == KeycodeCtrlG || (r == KeycodeESC && ls.ts.wouldBlock(ls.ifd, &timeout20ms)) {
+			// cancel the search, restore the original line
+			ls.buf = savedBuf
+			ls.pos = savedPos
+			r = KeycodeNull
+			break
+		} else if unicode.IsPrint(r) {
+			query = append(query, r)
+		} else {
+			// accept the match and handle the key
+			break
+		}
+		if len(query) == 0 {
+			// nothing to search for
+			idx = 0
+			failed = false
+			ls.buf = savedBuf
+			ls.pos = savedPos
+			ls.selStart = 0
+			continue
+		}
+		if start == 0 {
+			// skip the line being edited
+			start = 1
+		}
+		i, pos := ls.ts.historySearch(string(query), start)
+		if i < 0 {
+			// keep showing the last match
+			failed = true
+			ls.beep()
+			continue
+		}
+		failed = false
+		idx = i
+		ls.buf = []rune(ls.ts.historyGet(idx))
+		ls.pos = pos
+		ls.selStart = pos
+	}
+	if r != KeycodeNull && string(ls.buf) != string(savedBuf) {
+		// the accepted match can be undone
+		ls.pushUndo(editState{savedBuf, savedPos})
+	}
+	// redraw with the original prompt
+	ls.prompt = savedPrompt
+	ls.promptWidth = promptWidth(savedPrompt)
+	ls.selStart = 0
+	ls.selEnd = 0
+	ls.refreshLine()
+	return r
+}
+
+// Return the length of the common prefix of two rune slices.
+func commonPrefix(a, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// Return a string for the current line buffer.
+func (ls *linestate) String() string {
+	return string(ls.buf)
+}
+
+//-----------------------------------------------------------------------------
+
+// Linenoise stores line editor state.
+type Linenoise struct {
+	history            []historyEntry        // list of history entries
+	historyMaxlen      int                   // maximum number of history entries
+	rawmode            bool                  // are we in raw mode?
+	mlmode             bool                  // are we in multiline mode?
+	savedmode          *termMode             // saved terminal mode
+	completionCallback func(string) []string // callback function for tab completion
+	hintsCallback      func(string) *Hint    // callback function for hints
+	hotkey             rune                  // character for hotkey
+	helpKey            rune                  // character for inline help
+	helpCallback       func(string) string   // callback function for inline help
+	nextLine           string                // preloaded line buffer for the next edit
+	completionPad      bool                  // pad completions when they are displayed
+	completionLabel    func(string) *Hint    // callback for the label of a completion
+	bracketedPaste     bool                  // insert pasted text literally
+	bracketMatch       bool                  // highlight the matching bracket or quote
+	autoPair           bool                  // insert closing brackets and quotes
+	escMode            EscMode               // behavior of a single escape key press
+	historyMerge       bool                  // merge with the history file on save
+	historyFormat      HistoryFormat         // file format for saved history
+	trace              io.Writer             // debug trace output
+	injectOnce         sync.Once             // create the inject pipe once
+	injectPipe         []int                 // pipe for injected input (read, write)
+	wakePipe           []int                 // pipe to wake the edit loop (read, write)
+	asyncLock          sync.Mutex            // lock for asynchronous output
+	asyncBuf           []string              // pending asynchronous output
+	editing            bool                  // is a line being edited?
+	editTerm           bool                  // is the line being edited on the terminal?
+	masked             bool                  // mask the line buffer when displayed
+	maskChar           rune                  // displayed for masked characters, 0 for no echo
+	asyncPrompt        *string               // prompt update for the line being edited
+	idleTimeout        time.Duration         // idle timeout for line editing
+	idleDeadline       time.Time             // idle deadline from the last key, zero for none
+	scanner            *bufio.Scanner        // buffered IO scanner for file reading
+	shutdown           chan struct{}         // closed to shut down line editing
+	shutdownOnce       sync.Once             // close the shutdown channel once
+	ioErr              error                 // error that stopped line editing
+	pauseCh            chan struct{}         // closed to resume after a pause
+	pauseAck           chan struct{}         // closed when the edit loop has paused
+	pausedRaw          bool                  // was raw mode enabled outside of editing when paused?
+	cols, rows         int                   // terminal size set by the transport, 0 = detect
+	asyncResize        bool                  // the terminal size changed while editing
+	input              InputSource           // input source replacing the input fd
+	output             io.Writer             // output replacing the output fd
+	stats              *EditStats            // edit loop statistics, nil when disabled
+	obuf               []byte                // output buffer reused by line refreshes
+	keymap             map[Key]keyBinding    // key bindings for line editing
+	term               Terminal              // terminal replacing stdin/stdout, nil for none
+	termInput          *termSource           // input source for the terminal
+	ctx                context.Context       // context for the line being read, nil for none
+	color              ColorMode             // ANSI colors for the session, ColorAuto for the process setting
+	events             chan InputEvent       // input events, nil until Events is called
+	eventsOnce         sync.Once             // start the event reader once
+	eventPrompt        string                // prompt for lines read by the event reader
+	overwrite          bool                  // typed characters replace the characters at the cursor
+	overwriteCallback  func(bool) string     // called when the overwrite mode is toggled, returns the prompt
+	interrupt          bool                  // the line being edited has been interrupted
+	resume             *editPoint            // the interrupted line, edited again with the cursor restored
+}
+
+// editPoint is a line and cursor position.
+type editPoint struct {
+	line string
+	pos  int
+}
+
+// NewLineNoise returns a new line editor.
+func NewLineNoise() *Linenoise {
+	l := Linenoise{}
+	l.historyMaxlen = 32
+	l.bracketedPaste = true
+	l.maskChar = '*'
+	l.keymap = defaultKeymap()
+	l.shutdown = make(chan struct{})
+	return &l
+}
+
+// Enable raw mode
+func (l *Linenoise) enableRawMode(fd int) error {
+	mode, err := setRawMode(fd)
+	if err != nil {
+		return err
+	}
+	l.rawmode = true
+	l.savedmode = mode
+	l.tracef("raw mode enabled fd %d", fd)
+	return nil
+}
+
+// Disable raw mode
+func (l *Linenoise) disableRawMode(fd int) error {
+	if l.rawmode {
 		err := restoreMode(fd, l.savedmode)
 		if err != nil {
 			return err
 		}
+		l.tracef("raw mode disabled fd %d", fd)
 	}
 	l.rawmode = false
 	return nil
@@ -740,30 +1526,147 @@ func (l *Linenoise) disableRawMode(fd int) error {
 //-----------------------------------------------------------------------------
 
 // edit a line in raw mode
-func (l *Linenoise) edit(ifd, ofd int, prompt, init string) (string, error) {
+func (l *Linenoise) edit(ifd, ofd int, prompt, init string) (line string, err error) {
+	l.tracef("edit start prompt %q init %q", prompt, init)
+	if l.interrupted() {
+		return init, ErrInterrupted
+	}
+	if l.idleTimeout > 0 {
+		// an interrupted edit keeps the deadline from the last key
+		if l.idleDeadline.IsZero() {
+			l.idleDeadline = time.Now().Add(l.idleTimeout)
+		}
+		defer func() {
+			if err != ErrInterrupted {
+				l.idleDeadline = time.Time{}
+			}
+		}()
+	}
+	if l.input == nil {
+		l.injectInit()
+		if cols, _ := l.size(); cols == 0 {
+			// redraw the line when the terminal is resized
+			defer l.watchResize()()
+		}
+	}
 	// create the line state
 	ls := newLineState(ifd, ofd, prompt, l)
 	// set and output the initial line
 	ls.editSet(init)
-	// The latest history entry is always our current buffer
-	l.HistoryAdd(ls.String())
+	ls.undo = nil
+	if p := l.resume; p != nil {
+		l.resume = nil
+		if p.line == init {
+			// put the cursor back where it was when the line was interrupted
+			ls.pos = p.pos
+			ls.refreshLine()
+		}
+	}
+	// The latest history entry is always our current buffer.
+	// Push it unconditionally, it's popped when editing is done.
+	l.historyPush(ls.String())
 
 	u := utf8{}
+	// key read after a numeric argument that isn't repeated
+	pending := rune(KeycodeNull)
 
 	for {
-		r := u.getRune(syscall.Stdin, nil)
+		r := pending
+		pending = KeycodeNull
+		if r == KeycodeNull {
+			if l.interrupted() {
+				l.tracef("edit interrupted")
+				ls.clearLine()
+				l.historyPop(-1)
+				l.resume = &editPoint{ls.String(), ls.pos}
+				return ls.String(), ErrInterrupted
+			}
+			// check for an idle timeout
+			if l.idleTimeout > 0 && l.idleExpired(ifd) {
+				l.tracef("edit idle")
+				l.historyPop(-1)
+				return ls.String(), ErrIdle
+			}
+			r = l.getRune(&u, ifd, nil)
+		}
 		if r == KeycodeNull {
 			continue
 		}
+		if r == keycodeAsync {
+			ls.asyncFlush()
+			continue
+		}
+		if r == keycodeError {
+			l.historyPop(-1)
+			return "", l.ioErr
+		}
+		if st := l.stats; st != nil {
+			l.count(&st.Keys, 1)
+		}
+		if !l.masked && Key(r)&keyMeta == 0 {
+			l.postEvent(InputEvent{Type: EventKey, Key: KeyEvent{Rune: r}})
+		}
+		// is this key repeating the insertion of a previous argument?
+		yankRepeat := ls.yanked
+		ls.yanked = false
+		if !l.masked && l.trace != nil {
+			l.tracef("key '%s' 0x%x", keyName(r), int32(r))
+		}
+		// vi normal mode handles the printable keys
+		if ls.viNormal && unicode.IsPrint(r) && r != l.hotkey && r != l.helpKey {
+			ls.editViNormal(r)
+			continue
+		}
+		b := l.keymap[Key(r)]
 		// Autocomplete when the callback is set.
 		// It returns the character to be handled next.
-		if r == KeycodeTAB && l.completionCallback != nil {
+		if b.action == ActionComplete && l.completionCallback != nil && !l.masked {
 			r = ls.completeLine()
 			if r == KeycodeNull {
 				continue
 			}
+			if r == keycodeError {
+				l.historyPop(-1)
+				return "", l.ioErr
+			}
+			b = l.keymap[Key(r)]
 		}
-		if r == KeycodeCR || r == l.hotkey {
+		// Reverse incremental search of the history.
+		// It returns the character to be handled next.
+		if b.action == ActionHistorySearch && !l.masked {
+			r = ls.searchHistory()
+			if r == KeycodeNull {
+				continue
+			}
+			if r == keycodeError {
+				l.historyPop(-1)
+				return "", l.ioErr
+			}
+			b = l.keymap[Key(r)]
+		}
+		if b.action == ActionYankLastArg {
+			ls.yankLastArg(yankRepeat)
+			continue
+		}
+		// Character search reads the character to search for.
+		if b.action == ActionCharSearch || b.action == ActionCharSearchBackward {
+			c := l.getRune(&u, ifd, nil)
+			for c == KeycodeNull {
+				// the rest of a multi-byte character
+				c = l.getRune(&u, ifd, nil)
+			}
+			if c == keycodeError {
+				l.historyPop(-1)
+				return "", l.ioErr
+			}
+			ls.charSearch(c, b.action == ActionCharSearch)
+			continue
+		}
+		if r == l.helpKey && l.helpCallback != nil && !l.masked && !ls.escaped() {
+			ls.showHelp()
+			continue
+		}
+		if b.action == ActionAcceptLine || (r == l.hotkey && !l.masked) {
 			l.historyPop(-1)
 			if l.hintsCallback != nil {
 				// Refresh the line without hints to leave the
@@ -774,35 +1677,92 @@ func (l *Linenoise) edit(ifd, ofd int, prompt, init string) (string, error) {
 				l.hintsCallback = hcb
 			}
 			s := ls.String()
+			if !l.masked {
+				l.tracef("edit done %q", s)
+			}
 			if r == l.hotkey {
 				return s + string(l.hotkey), nil
 			}
 			return s, nil
-		} else if r == KeycodeBS {
-			// backspace: remove the character to the left of the cursor
-			ls.editBackspace()
-
 		} else if r == KeycodeESC {
-			if wouldBlock(ifd, &timeout20ms) {
-				// looks like a single escape- abandon the line
-				l.historyPop(-1)
-				return "", nil
+			// the key after the escape may have been read with a numeric argument
+			s0 := ls.escNext
+			ls.escNext = KeycodeNull
+			if s0 == KeycodeNull && l.wouldBlock(ifd, &timeout20ms) {
+				// looks like a single escape
+				l.tracef("single escape, mode %d", l.escMode)
+				switch l.escMode {
+				case EscIgnore:
+					// do nothing
+				case EscClear:
+					ls.deleteLine()
+				case EscViNormal:
+					l.tracef("vi normal mode")
+					ls.viNormal = true
+					ls.editMoveLeft()
+				default:
+					// abandon the line
+					l.historyPop(-1)
+					return "", nil
+				}
+				continue
 			}
 			// escape sequence
-			s0 := u.getRune(ifd, &timeout20ms)
-			s1 := u.getRune(ifd, &timeout20ms)
+			if s0 == KeycodeNull {
+				s0 = l.getRune(&u, ifd, &timeout20ms)
+			}
+			if _, ok := l.keymap[MetaKey(s0)]; ok {
+				// ESC key (alt-key), handle the meta key binding next
+				ls.yanked = yankRepeat
+				pending = rune(MetaKey(s0))
+				continue
+			}
+			if s0 >= '1' && s0 <= '9' {
+				// ESC digit (alt-digit), numeric argument for the next key
+				n, r := ls.numericArg(&u, int(s0-'0'))
+				if r == keycodeError {
+					l.historyPop(-1)
+					return "", l.ioErr
+				}
+				if !ls.editRepeat(r, n) {
+					// handle the key once
+					pending = r
+				}
+				continue
+			}
+			if s0 != '[' && s0 != '0' && s0 != KeycodeCtrlRightBracket {
+				// unbound meta key
+				continue
+			}
+			s1 := l.getRune(&u, ifd, &timeout20ms)
 			if s0 == '[' {
 				// ESC [ sequence
 				if s1 >= '0' && s1 <= '9' {
-					// Extended escape, read additional byte.
-					s2 := u.getRune(ifd, &timeout20ms)
-					if s2 == '~' {
-						if s1 == '3' {
-							// delete
-							ls.editDelete()
+					// Extended escape, read the parameter digits and the final byte.
+					seq := []rune{s0, s1}
+					s2 := l.getRune(&u, ifd, &timeout20ms)
+					for s2 >= '0' && s2 <= '9' && len(seq) < 5 {
+						seq = append(seq, s2)
+						s2 = l.getRune(&u, ifd, &timeout20ms)
+					}
+					seq = append(seq, s2)
+					l.tracef("escape sequence ESC %q", string(seq))
+					switch string(seq) {
+					case "[2~":
+						// insert
+						ls.toggleOverwrite()
+					case "[3~":
+						// delete
+						ls.editDelete()
+					case "[200~":
+						// bracketed paste
+						if ls.editPaste(&u) == keycodeError {
+							l.historyPop(-1)
+							return "", l.ioErr
 						}
 					}
 				} else {
+					l.tracef("escape sequence ESC %q", string([]rune{s0, s1}))
 					if s1 == 'A' {
 						// cursor up
 						ls.editSet(l.historyPrev(ls))
@@ -823,8 +1783,20 @@ func (l *Linenoise) edit(ifd, ofd int, prompt, init string) (string, error) {
 						ls.editMoveEnd()
 					}
 				}
+			} else if s0 == KeycodeCtrlRightBracket {
+				// ESC ctrl-] c, backward character search
+				for s1 == KeycodeNull {
+					// the character is typed after the key sequence
+					s1 = l.getRune(&u, ifd, nil)
+				}
+				if s1 == keycodeError {
+					l.historyPop(-1)
+					return "", l.ioErr
+				}
+				ls.charSearch(s1, false)
 			} else if s0 == '0' {
 				// ESC 0 sequence
+				l.tracef("escape sequence ESC %q", string([]rune{s0, s1}))
 				if s1 == 'H' {
 					// cursor home
 					ls.editMoveHome()
@@ -833,154 +1805,943 @@ func (l *Linenoise) edit(ifd, ofd int, prompt, init string) (string, error) {
 					ls.editMoveEnd()
 				}
 			}
-		} else if r == KeycodeCtrlA {
-			// go to the start of the line
-			ls.editMoveHome()
-		} else if r == KeycodeCtrlB {
-			// cursor left
-			ls.editMoveLeft()
-		} else if r == KeycodeCtrlC {
+		} else if b.fn != nil {
+			// application function
+			ls.editFunc(b.fn)
+		} else if b.action == ActionQuit || (b.action == ActionDeleteCharOrQuit && len(ls.buf) == 0) {
 			// return QUIT
+			l.tracef("edit quit")
+			l.historyPop(-1)
 			return "", ErrQuit
-		} else if r == KeycodeCtrlD {
-			if len(ls.buf) > 0 {
-				// delete: remove the character to the right of the cursor.
-				ls.editDelete()
-			} else {
-				// nothing to delete - QUIT
-				l.historyPop(-1)
-				return "", ErrQuit
+		} else if b.action == ActionDeleteCharOrQuit {
+			// delete: remove the character to the right of the cursor.
+			ls.editDelete()
+		} else {
+			ls.editAction(b.action, r)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Injected Input and Asynchronous Output
+
+// keycode returned by getRune when asynchronous output is pending
+const keycodeAsync = -1
+
+// bracketed paste sequences
+const (
+	pasteOn  = "\x1b[?2004h" // enable bracketed paste
+	pasteOff = "\x1b[?2004l" // disable bracketed paste
+	pasteEnd = "\x1b[201~"   // end of pasted text
+)
+
+// Create the pipes used for injected input and asynchronous output.
+func (l *Linenoise) injectInit() {
+	l.injectOnce.Do(func() {
+		p := make([]int, 4)
+		err := newPipe(p[0:2])
+		if err != nil {
+			log.Printf("inject pipe error %s\n", err)
+			return
+		}
+		err = newPipe(p[2:4])
+		if err != nil {
+			log.Printf("wake pipe error %s\n", err)
+			closeFd(p[0])
+			closeFd(p[1])
+			return
+		}
+		l.injectPipe = p[0:2]
+		l.wakePipe = p[2:4]
+	})
+}
+
+// Close releases the pipes and the terminal reader of the line editor.
+// Injected input and asynchronous output are not supported after it's closed.
+// It should not be called while a line is edited.
+func (l *Linenoise) Close() {
+	// the pipes are not created after they are closed
+	l.injectOnce.Do(func() {})
+	l.asyncLock.Lock()
+	var p []int
+	p = append(p, l.injectPipe...)
+	p = append(p, l.wakePipe...)
+	l.injectPipe = nil
+	l.wakePipe = nil
+	s := l.termInput
+	l.termInput = nil
+	l.term = nil
+	l.asyncLock.Unlock()
+	for _, fd := range p {
+		closeFd(fd)
+	}
+	if s != nil {
+		s.close()
+	}
+}
+
+// Inject feeds a string into the line editor as if it had been typed.
+// It's safe to call while another goroutine is blocked in Read.
+// Injected input is only read when editing on a terminal.
+func (l *Linenoise) Inject(s string) error {
+	l.injectInit()
+	l.asyncLock.Lock()
+	p := l.injectPipe
+	l.asyncLock.Unlock()
+	if p == nil {
+		return errors.New("no inject pipe")
+	}
+	_, err := writeAll(p[1], []byte(s))
+	return err
+}
+
+// PrintAsync prints a string above the line being edited.
+// If no line is being edited the string is printed immediately.
+// It's safe to call from other goroutines.
+func (l *Linenoise) PrintAsync(s string) {
+	l.injectInit()
+	l.asyncLock.Lock()
+	defer l.asyncLock.Unlock()
+	if !l.canWake() {
+		l.asyncPrint(s)
+		return
+	}
+	l.asyncBuf = append(l.asyncBuf, s)
+	l.wakeEdit()
+}
+
+// SetPromptAsync changes the prompt of the line being edited.
+// It's safe to call from other goroutines.
+func (l *Linenoise) SetPromptAsync(prompt string) {
+	l.injectInit()
+	l.asyncLock.Lock()
+	defer l.asyncLock.Unlock()
+	if !l.canWake() {
+		return
+	}
+	l.asyncPrompt = &prompt
+	l.wakeEdit()
+}
+
+// Is a line being edited that can be woken up? Called with the async lock held.
+func (l *Linenoise) canWake() bool {
+	if !l.editing {
+		return false
+	}
+	if l.editTerm {
+		return l.termInput != nil
+	}
+	return l.wakePipe != nil
+}
+
+// Wake up the edit loop. Called with the async lock held.
+func (l *Linenoise) wakeEdit() {
+	if l.editTerm {
+		l.termInput.wakeUp()
+		return
+	}
+	writeAll(l.wakePipe[1], []byte{0})
+}
+
+// Print asynchronous output to the terminal or stdout.
+// Called with the async lock held.
+func (l *Linenoise) asyncPrint(s string) {
+	if l.rawmode || l.editTerm {
+		s = strings.Replace(s, "\n", "\r\n", -1)
+	}
+	if l.term != nil {
+		io.WriteString(l.term, s)
+		return
+	}
+	puts(stdoutFd, s)
+}
+
+// Start or stop editing a line. Pending asynchronous output is printed when editing stops.
+func (l *Linenoise) setEditing(editing bool) {
+	l.asyncLock.Lock()
+	defer l.asyncLock.Unlock()
+	l.editing = editing
+	if !editing {
+		for _, s := range l.asyncBuf {
+			l.asyncPrint(s)
+		}
+		l.editTerm = false
+		l.asyncBuf = nil
+		l.asyncPrompt = nil
+		l.asyncResize = false
+		if l.pauseAck != nil {
+			// editing stopped before the edit loop paused
+			close(l.pauseAck)
+			l.pauseAck = nil
+		}
+	}
+}
+
+// Pause restores the terminal mode so application components can write
+// directly to stdout. A line being edited is cleared and the edit loop
+// waits until Resume is called, the line is then redrawn.
+// It's safe to call from other goroutines.
+func (l *Linenoise) Pause() {
+	l.injectInit()
+	l.asyncLock.Lock()
+	if l.pauseCh != nil {
+		// already paused
+		l.asyncLock.Unlock()
+		return
+	}
+	l.pauseCh = make(chan struct{})
+	if l.canWake() {
+		// wait for the edit loop to pause
+		ack := make(chan struct{})
+		l.pauseAck = ack
+		l.wakeEdit()
+		l.asyncLock.Unlock()
+		<-ack
+		return
+	}
+	l.pausedRaw = l.rawmode
+	if l.rawmode {
+		l.disableRawMode(stdinFd)
+	}
+	l.asyncLock.Unlock()
+}
+
+// Resume re-enters raw mode after a Pause.
+// It's safe to call from other goroutines.
+func (l *Linenoise) Resume() {
+	l.asyncLock.Lock()
+	defer l.asyncLock.Unlock()
+	if l.pauseCh == nil {
+		return
+	}
+	close(l.pauseCh)
+	l.pauseCh = nil
+	if l.pausedRaw {
+		l.enableRawMode(stdinFd)
+		l.pausedRaw = false
+	}
+}
+
+// Wait until a pause has been resumed.
+func (l *Linenoise) waitResume() {
+	l.asyncLock.Lock()
+	ch := l.pauseCh
+	l.asyncLock.Unlock()
+	if ch != nil {
+		<-ch
+	}
+}
+
+// Drain the wake pipe.
+func (l *Linenoise) drainWake() {
+	buf := make([]byte, 64)
+	for !wouldBlock(l.wakePipe[0], &timeoutZero) {
+		readFd(l.wakePipe[0], buf)
+	}
+}
+
+// Print pending asynchronous output above the line being edited.
+func (ls *linestate) asyncFlush() {
+	l := ls.ts
+	l.asyncLock.Lock()
+	if l.wakePipe != nil {
+		l.drainWake()
+	}
+	if l.pauseAck != nil {
+		// clear the line and restore the terminal mode until resumed
+		ls.clearLine()
+		if !l.editTerm {
+			l.disableRawMode(ls.ifd)
+		}
+		close(l.pauseAck)
+		l.pauseAck = nil
+		ch := l.pauseCh
+		l.asyncLock.Unlock()
+		<-ch
+		l.asyncLock.Lock()
+		if !l.editTerm {
+			l.enableRawMode(ls.ifd)
+		}
+	}
+	if l.asyncResize {
+		ls.clearLine()
+		// asyncLock is held, use the set size
+		ls.cols = l.detectColumns(l.cols, ls.ifd, ls.ofd)
+		l.asyncResize = false
+		l.postEvent(InputEvent{Type: EventResize, Cols: ls.cols, Rows: l.detectRows(l.rows)})
+	}
+	if l.asyncPrompt != nil {
+		ls.clearLine()
+		ls.prompt = *l.asyncPrompt
+		ls.promptWidth = promptWidth(ls.prompt)
+		l.asyncPrompt = nil
+	}
+	if len(l.asyncBuf) != 0 {
+		ls.clearLine()
+		for _, s := range l.asyncBuf {
+			if !strings.HasSuffix(s, "\n") {
+				s += "\n"
 			}
-		} else if r == KeycodeCtrlE {
-			// go to the end of the line
-			ls.editMoveEnd()
-		} else if r == KeycodeCtrlF {
-			// cursor right
-			ls.editMoveRight()
-		} else if r == KeycodeCtrlH {
-			// backspace: remove the character to the left of the cursor
-			ls.editBackspace()
-		} else if r == KeycodeCtrlK {
-			// delete to the end of the line
-			ls.deleteToEnd()
-		} else if r == KeycodeCtrlL {
-			// clear screen
-			clearScreen()
-			ls.refreshLine()
-		} else if r == KeycodeCtrlN {
-			// next history item
-			ls.editSet(l.historyNext(ls))
-		} else if r == KeycodeCtrlP {
-			// previous history item
-			ls.editSet(l.historyPrev(ls))
-		} else if r == KeycodeCtrlT {
-			// swap current character with the previous
-			ls.editSwap()
-		} else if r == KeycodeCtrlU {
-			// delete the whole line
-			ls.deleteLine()
-		} else if r == KeycodeCtrlW {
-			// delete previous word
-			ls.deletePrevWord()
+			l.asyncPrint(s)
+		}
+		l.asyncBuf = nil
+	}
+	l.asyncLock.Unlock()
+	ls.refreshLine()
+}
+
+// Is the cursor after a backslash? An escaped help key is inserted literally.
+func (ls *linestate) escaped() bool {
+	return ls.pos > 0 && ls.buf[ls.pos-1] == '\\'
+}
+
+// Display inline help below the line being edited, then redraw the line.
+func (ls *linestate) showHelp() {
+	l := ls.ts
+	s := l.helpCallback(string(ls.buf[:ls.pos]))
+	l.tracef("help at %d", ls.pos)
+	// leave the line as typed above the help
+	ls.clearLine()
+	s = ls.prompt + ls.String() + "\n" + s
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	ls.puts(strings.Replace(s, "\n", "\r\n", -1))
+	ls.refreshLine()
+}
+
+// Wait for the fd or the injected input to be readable.
+// If async is true also wait for asynchronous output.
+// Return the readable fd, or -1 if nothing is readable within the timeout.
+func (l *Linenoise) waitInput(fd int, timeout *syscall.Timeval, async bool) (int, error) {
+	// prioritize injected input and asynchronous output
+	fds := []int{fd, l.injectPipe[0]}
+	if async {
+		fds = append(fds, l.wakePipe[0])
+	}
+	return selectRead(fds, timeout)
+}
+
+// Wait for input until the idle deadline. Return true if the deadline passes.
+// Asynchronous output and interrupts wake the wait but don't count as input.
+func (l *Linenoise) idleExpired(fd int) bool {
+	d := time.Until(l.idleDeadline)
+	if d < 0 {
+		d = 0
+	}
+	if l.input == nil && l.injectPipe != nil {
+		tv := syscall.NsecToTimeval(d.Nanoseconds())
+		rfd, err := l.waitInput(fd, &tv, true)
+		if err != nil || rfd >= 0 {
+			return false
+		}
+	} else if l.termInput != nil && l.input == l.termInput {
+		if l.termInput.fill(d) {
+			return false
+		}
+	} else {
+		// other input sources have no timed reads
+		return false
+	}
+	return true
+}
+
+// Read a rune from the fd or the injected input (with timeout).
+// Return keycodeAsync if a blocking read is interrupted by asynchronous output.
+// Return keycodeError on a read error or shutdown, the error is saved in l.ioErr.
+func (l *Linenoise) getRune(u *utf8, fd int, timeout *syscall.Timeval) rune {
+	if l.input != nil {
+		r := u.sourceRune(l.input, timeout)
+		if r == keycodeError {
+			if u.err == errWake {
+				return keycodeAsync
+			}
+			l.ioErr = u.err
+		}
+		l.keyRead(r)
+		return r
+	}
+	if err := l.stopped(); err != nil {
+		l.ioErr = err
+		return keycodeError
+	}
+	var r rune
+	if l.injectPipe == nil || u.held {
+		r = u.getRune(fd, timeout)
+	} else {
+		rfd, err := l.waitInput(fd, timeout, timeout == nil)
+		if err != nil {
+			u.err = err
+			r = keycodeError
+		} else if rfd < 0 {
+			r = KeycodeNull
+		} else if rfd == l.wakePipe[0] {
+			r = keycodeAsync
+		} else {
+			r = u.getRune(rfd, nil)
+		}
+	}
+	l.keyRead(r)
+	if r == keycodeError {
+		l.ioErr = u.err
+	}
+	if r == keycodeAsync {
+		// the wake up may be a shutdown or a cancelled read
+		if err := l.stopped(); err != nil {
+			l.ioErr = err
+			return keycodeError
+		}
+	}
+	return r
+}
+
+// A key has been read, restart the idle timeout.
+func (l *Linenoise) keyRead(r rune) {
+	if l.idleTimeout > 0 && r != KeycodeNull && r != keycodeAsync && r != keycodeError {
+		l.idleDeadline = time.Now().Add(l.idleTimeout)
+	}
+}
+
+// Return an error if line editing has been shut down or the read cancelled.
+func (l *Linenoise) stopped() error {
+	select {
+	case <-l.shutdown:
+		return ErrShutdown
+	default:
+	}
+	if l.ctx != nil {
+		return l.ctx.Err()
+	}
+	return nil
+}
+
+// Shutdown stops line editing, a blocked Read returns ErrShutdown.
+// It's safe to call from other goroutines.
+func (l *Linenoise) Shutdown() {
+	l.shutdownOnce.Do(func() {
+		close(l.shutdown)
+	})
+	l.injectInit()
+	l.asyncLock.Lock()
+	defer l.asyncLock.Unlock()
+	if l.wakePipe != nil {
+		// wake up the edit loop
+		writeAll(l.wakePipe[1], []byte{0})
+	}
+}
+
+// Interrupt stops the line being edited so the goroutine reading it can do
+// other work. The line is cleared and Read returns the partial line with
+// ErrInterrupted. If no line is being edited the next edit is interrupted.
+// Reading piped input isn't interrupted. It's safe to call from other goroutines.
+func (l *Linenoise) Interrupt() {
+	l.injectInit()
+	l.asyncLock.Lock()
+	defer l.asyncLock.Unlock()
+	l.interrupt = true
+	if l.editing && l.wakePipe != nil {
+		// wake up the edit loop
+		writeAll(l.wakePipe[1], []byte{0})
+	}
+	if l.termInput != nil {
+		l.termInput.wakeUp()
+	}
+}
+
+// Has the line being edited been interrupted? The interruption is cleared.
+func (l *Linenoise) interrupted() bool {
+	l.asyncLock.Lock()
+	defer l.asyncLock.Unlock()
+	i := l.interrupt
+	l.interrupt = false
+	return i
+}
+
+// If neither the fd or the injected input is readable within the timeout period return true.
+func (l *Linenoise) wouldBlock(fd int, timeout *syscall.Timeval) bool {
+	if l.input != nil {
+		return !l.input.Pending()
+	}
+	if l.injectPipe == nil {
+		return wouldBlock(fd, timeout)
+	}
+	rfd, err := l.waitInput(fd, timeout, false)
+	return err == nil && rfd < 0
+}
+
+//-----------------------------------------------------------------------------
+
+// Read a line from stdin in raw mode.
+func (l *Linenoise) readRaw(prompt, init string) (string, error) {
+	l.waitResume()
+	// set rawmode for stdin
+	l.enableRawMode(stdinFd)
+	defer l.disableRawMode(stdinFd)
+	if l.bracketedPaste {
+		// pasted text is marked so it can be inserted literally
+		puts(stdoutFd, pasteOn)
+		defer puts(stdoutFd, pasteOff)
+	}
+	// edit the line
+	l.setEditing(true)
+	s, err := l.edit(stdinFd, stdoutFd, prompt, init)
+	if err != ErrInterrupted {
+		fmt.Printf("\r\n")
+	}
+	l.setEditing(false)
+	return s, err
+}
+
+// Read a line on a terminal without line editing (Eg. TERM=dumb).
+// The terminal echoes and edits the line (cooked mode). An initial line
+// (Eg. a command recycled after help) is displayed and the typed text is
+// appended to it. "!!" and "!n" recall history entries.
+func (l *Linenoise) readDumb(prompt, init string) (string, error) {
+	fmt.Printf("%s%s", prompt, init)
+	s, err := l.readBasic()
+	if err == ErrEOF {
+		// the user typed ctrl-D
+		fmt.Printf("\n")
+		return "", ErrQuit
+	}
+	if err != nil {
+		return "", err
+	}
+	s = init + s
+	if l.masked {
+		return s, nil
+	}
+	line, ok, err := l.historyExpand(s)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return "", nil
+	}
+	if ok {
+		// show the recalled command
+		fmt.Printf("%s%s\n", prompt, line)
+	}
+	return line, nil
+}
+
+// Expand a history reference ("!!" or "!n") to the history entry.
+// Returns true if the line was a history reference.
+func (l *Linenoise) historyExpand(line string) (string, bool, error) {
+	s := strings.TrimSpace(line)
+	if !strings.HasPrefix(s, "!") || len(s) == 1 {
+		return line, false, nil
+	}
+	n := len(l.history)
+	if n == 0 {
+		return "", true, errors.New("no history")
+	}
+	idx := 0
+	if s != "!!" {
+		var err error
+		idx, err = historyIndex(s[1:], n)
+		if err != nil {
+			return "", true, err
+		}
+	}
+	return l.historyGet(idx), true, nil
+}
+
+// Read a line using basic buffered IO.
+func (l *Linenoise) readBasic() (string, error) {
+	if l.scanner == nil {
+		l.scanner = bufio.NewScanner(os.Stdin)
+	}
+	// scan a line
+	if !l.scanner.Scan() {
+		// check for unexpected errors
+		err := l.scanner.Err()
+		if err != nil {
+			return "", err
+		}
+		// end of input
+		return "", ErrEOF
+	}
+	// get the line string
+	return l.scanner.Text(), nil
+}
+
+// Read a line.
+// Returns ErrQuit when the user quits and ErrEOF at the end of piped input.
+func (l *Linenoise) Read(prompt, init string) (string, error) {
+	// a preloaded line buffer takes precedence
+	if l.nextLine != "" {
+		init = l.nextLine
+		l.nextLine = ""
+	}
+	if l.term != nil {
+		// line editing on the application terminal
+		return l.readTerm(prompt, init)
+	}
+	if !isTerminal(stdinFd) {
+		// Not a tty, read from a file or pipe.
+		return l.readBasic()
+	} else if unsupportedTerm() {
+		// Not a terminal we know about, so cooked mode line reading.
+		return l.readDumb(prompt, init)
+	} else {
+		// A command line on stdin, our raison d'etre.
+		return l.readRaw(prompt, init)
+	}
+}
+
+// ReadContext reads a line like Read. Line editing stops when the context is
+// cancelled and ctx.Err() is returned. Reading piped input isn't interrupted.
+func (l *Linenoise) ReadContext(ctx context.Context, prompt, init string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	defer l.setContext(ctx)()
+	return l.Read(prompt, init)
+}
+
+// Set the context for a read, a blocked read is woken up when the context is
+// cancelled. Returns a function to call when the read is done.
+func (l *Linenoise) setContext(ctx context.Context) func() {
+	l.ctx = ctx
+	done := ctx.Done()
+	if done == nil {
+		return func() { l.ctx = nil }
+	}
+	l.injectInit()
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+			l.asyncLock.Lock()
+			if l.wakePipe != nil {
+				// wake up the edit loop
+				writeAll(l.wakePipe[1], []byte{0})
+			}
+			l.asyncLock.Unlock()
+		case <-stop:
+		}
+	}()
+	return func() {
+		close(stop)
+		l.ctx = nil
+	}
+}
+
+// ReadPassword reads a line with masked display (Eg. a password).
+// The typed characters are echoed as the mask character and the line is not
+// added to history. Completion, hints, history and the hotkey are disabled.
+func (l *Linenoise) ReadPassword(prompt string) (string, error) {
+	return l.readPassword(context.Background(), prompt)
+}
+
+// Read a password, reading stops when the context is cancelled.
+func (l *Linenoise) readPassword(ctx context.Context, prompt string) (string, error) {
+	l.masked = true
+	defer func() { l.masked = false }()
+	// a preloaded line buffer is kept for the next read
+	next := l.nextLine
+	l.nextLine = ""
+	defer func() { l.nextLine = next }()
+	return l.ReadContext(ctx, prompt, "")
+}
+
+//-----------------------------------------------------------------------------
+
+// Loop calls the provided function in a loop.
+// Exit when the function returns true or when the exit key is pressed.
+// Returns true when the loop function completes, false for early exit.
+func (l *Linenoise) Loop(fn func() bool, exitKey rune) bool {
+	return l.LoopKeys(fn, []rune{exitKey})
+}
+
+// LoopKeys calls the provided function in a loop.
+// Exit when the function returns true or when one of the exit keys is pressed.
+// Returns true when the loop function completes, false for early exit.
+func (l *Linenoise) LoopKeys(fn func() bool, exitKeys []rune) bool {
+
+	// set rawmode for the terminal
+	restore, err := l.keyMode()
+	if err != nil {
+		log.Printf("enable rawmode error %s\n", err)
+		return false
+	}
+
+	u := utf8{}
+	rc := false
+	looping := true
+
+	for looping {
+		// get a rune
+		r := l.loopRune(&u)
+		if r == keycodeError || (r != KeycodeNull && strings.ContainsRune(string(exitKeys), r)) {
+			// the loop has been cancelled
+			rc = false
+			looping = false
 		} else {
-			// insert the character into the line buffer
-			ls.editInsert(r)
+			if fn() {
+				// the loop function has completed
+				rc = true
+				looping = false
+			}
+		}
+	}
+
+	// restore the terminal mode
+	restore()
+	return rc
+}
+
+// Read a rune without blocking for a loop function.
+// Return keycodeError if line editing has been shut down.
+func (l *Linenoise) loopRune(u *utf8) rune {
+	select {
+	case <-l.shutdown:
+		return keycodeError
+	default:
+	}
+	if l.input != nil {
+		return u.sourceRune(l.input, &timeoutZero)
+	}
+	return u.getRune(stdinFd, &timeoutZero)
+}
+
+// readKey displays a prompt and waits for a single key press.
+// The prompt is erased after the key is pressed.
+func (l *Linenoise) readKey(ctx context.Context, prompt string) rune {
+	l.putTerm(prompt)
+	k, err := l.ReadKeyContext(ctx, 0)
+	l.putTerm("\r\x1b[0K")
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("read key error %s\n", err)
+		}
+		return KeycodeCtrlC
+	}
+	return k.Rune
+}
+
+//-----------------------------------------------------------------------------
+// Input Sources
+
+// InputSource is a source of input bytes for line editing.
+// It replaces the terminal so the editor can be driven deterministically,
+// Eg. by tests and fuzzers. Timeouts expire immediately when no input is pending.
+type InputSource interface {
+	ReadByte() (byte, error) // return the next byte, io.EOF at the end of input
+	Pending() bool           // is there input available?
+}
+
+// byteSource is an input source for a byte slice.
+type byteSource struct {
+	buf []byte
+}
+
+// NewByteSource returns an input source for a byte slice.
+func NewByteSource(buf []byte) InputSource {
+	return &byteSource{buf}
+}
+
+func (s *byteSource) ReadByte() (byte, error) {
+	if len(s.buf) == 0 {
+		return 0, io.EOF
+	}
+	c := s.buf[0]
+	s.buf = s.buf[1:]
+	return c, nil
+}
+
+func (s *byteSource) Pending() bool {
+	return len(s.buf) != 0
+}
+
+// read a single rune from an input source (with timeout)
+// Return keycodeError at the end of input, the error is saved in u.err.
+func (u *utf8) sourceRune(src InputSource, timeout *syscall.Timeval) rune {
+	if c, ok := u.next(); ok {
+		// decode the byte that interrupted a sequence
+		r, _ := u.add(c)
+		return r
+	}
+	if timeout != nil && !src.Pending() {
+		// the timeout expires immediately
+		return KeycodeNull
+	}
+	c, err := src.ReadByte()
+	if err != nil {
+		u.err = err
+		return keycodeError
+	}
+	r, _ := u.add(c)
+	return r
+}
+
+// Edit edits a line with input from an input source and output to a writer.
+// The terminal isn't used. It returns the edited line, or the input source
+// error (Eg. io.EOF) if the input ends before the line is complete.
+func (l *Linenoise) Edit(src InputSource, out io.Writer, prompt, init string) (string, error) {
+	l.input = src
+	l.output = out
+	defer func() {
+		l.input = nil
+		l.output = nil
+	}()
+	return l.edit(-1, -1, prompt, init)
+}
+
+//-----------------------------------------------------------------------------
+// Key Code Debugging
+
+// KeyEvent is a decoded key press.
+type KeyEvent struct {
+	Rune rune   // key code, KeycodeESC for escape sequences
+	Seq  string // escape sequence following the ESC (if any)
+}
+
+// escape sequence names
+var escSeqNames = map[string]string{
+	"[A":  "<up>",
+	"[B":  "<down>",
+	"[C":  "<right>",
+	"[D":  "<left>",
+	"[H":  "<home>",
+	"[F":  "<end>",
+	"OH":  "<home>",
+	"OF":  "<end>",
+	"[2~": "<insert>",
+	"[3~": "<delete>",
+	"[5~": "<pgup>",
+	"[6~": "<pgdn>",
+}
+
+// Name returns a printable name for the key event.
+func (k KeyEvent) Name() string {
+	if k.Seq != "" {
+		if name, ok := escSeqNames[k.Seq]; ok {
+			return name
 		}
+		return "ESC " + k.Seq
 	}
+	return keyName(k.Rune)
 }
 
-//-----------------------------------------------------------------------------
-
-// Read a line from stdin in raw mode.
-func (l *Linenoise) readRaw(prompt, init string) (string, error) {
-	// set rawmode for stdin
-	l.enableRawMode(syscall.Stdin)
-	defer l.disableRawMode(syscall.Stdin)
-	// edit the line
-	s, err := l.edit(syscall.Stdin, syscall.Stdout, prompt, init)
-	fmt.Printf("\r\n")
-	return s, err
+// String pretty prints the key event.
+func (k KeyEvent) String() string {
+	if k.Seq != "" {
+		return fmt.Sprintf("'%s' ESC %q", k.Name(), k.Seq)
+	}
+	return fmt.Sprintf("'%s' 0x%x (%d)", k.Name(), int32(k.Rune), int32(k.Rune))
 }
 
-// Read a line using basic buffered IO.
-func (l *Linenoise) readBasic() (string, error) {
-	if l.scanner == nil {
-		l.scanner = bufio.NewScanner(os.Stdin)
-	}
-	// scan a line
-	if !l.scanner.Scan() {
-		// EOF - return quit
-		return "", ErrQuit
+// Read the remainder of an escape sequence.
+func (l *Linenoise) getEscSeq(u *utf8, fd int) string {
+	seq := make([]rune, 0, 8)
+	for len(seq) < 8 {
+		r := l.getRune(u, fd, &timeout20ms)
+		if r == KeycodeNull || r == keycodeError {
+			break
+		}
+		seq = append(seq, r)
+		// sequences are terminated with a letter or '~'
+		if len(seq) >= 2 && (unicode.IsLetter(r) || r == '~') {
+			break
+		}
 	}
-	// check for unexpected errors
-	err := l.scanner.Err()
+	return string(seq)
+}
+
+// KeyInspector reads key presses in raw mode and passes the decoded key
+// events to a callback function. It returns when the callback returns false.
+func (l *Linenoise) KeyInspector(fn func(KeyEvent) bool) error {
+	// set rawmode for the terminal
+	restore, err := l.keyMode()
 	if err != nil {
-		return "", err
+		return err
 	}
-	// get the line string
-	return l.scanner.Text(), nil
-}
+	defer restore()
 
-// Read a line. Return nil on EOF/quit.
-func (l *Linenoise) Read(prompt, init string) (string, error) {
-	if !isatty.IsTerminal(uintptr(syscall.Stdin)) {
-		// Not a tty, read from a file or pipe.
-		return l.readBasic()
-	} else if unsupportedTerm() {
-		// Not a terminal we know about, so basic line reading.
-		fmt.Printf(prompt)
-		s, err := l.readBasic()
-		if err == ErrQuit {
-			fmt.Printf("\n")
+	for {
+		k, err := l.readKeyFd(stdinFd, 0)
+		if err != nil {
+			return err
+		}
+		if !fn(k) {
+			return nil
 		}
-		return s, err
-	} else {
-		// A command line on stdin, our raison d'etre.
-		return l.readRaw(prompt, init)
 	}
 }
 
 //-----------------------------------------------------------------------------
+// Key Reading
 
-// Loop calls the provided function in a loop.
-// Exit when the function returns true or when the exit key is pressed.
-// Returns true when the loop function completes, false for early exit.
-func (l *Linenoise) Loop(fn func() bool, exitKey rune) bool {
-
-	// set rawmode for stdin
-	err := l.enableRawMode(syscall.Stdin)
-	if err != nil {
-		log.Printf("enable rawmode error %s\n", err)
-		return false
-	}
+// ErrTimeout is returned when no key is pressed within the timeout.
+var ErrTimeout = errors.New("timeout")
 
+// Read a key event from a file descriptor.
+func (l *Linenoise) readKeyFd(fd int, timeout time.Duration) (KeyEvent, error) {
 	u := utf8{}
-	rc := false
-	looping := true
-
-	for looping {
-		// get a rune
-		r := u.getRune(syscall.Stdin, &timeoutZero)
-		if r == exitKey {
-			// the loop has been cancelled
-			rc = false
-			looping = false
-		} else {
-			if fn() {
-				// the loop function has completed
-				rc = true
-				looping = false
+	deadline := time.Now().Add(timeout)
+	for {
+		var tv *syscall.Timeval
+		if timeout > 0 {
+			remain := time.Until(deadline)
+			if remain <= 0 {
+				return KeyEvent{}, ErrTimeout
 			}
+			t := syscall.NsecToTimeval(remain.Nanoseconds())
+			tv = &t
+		}
+		r := l.getRune(&u, fd, tv)
+		if r == keycodeAsync {
+			// no line is being edited, the wake up is stale
+			l.asyncLock.Lock()
+			l.drainWake()
+			l.asyncLock.Unlock()
+			continue
+		}
+		if r == KeycodeNull {
+			continue
 		}
+		if r == keycodeError {
+			return KeyEvent{}, l.ioErr
+		}
+		k := KeyEvent{Rune: r}
+		if r == KeycodeESC && !l.wouldBlock(fd, &timeout20ms) {
+			// escape sequence
+			k.Seq = l.getEscSeq(&u, fd)
+		}
+		return k, nil
+	}
+}
+
+// ReadKey waits for a key press and returns the decoded key event.
+// A timeout of 0 waits forever, otherwise ErrTimeout is returned if no
+// key is pressed within the timeout. It's intended for custom interactions
+// outside of line editing, Eg. "press any key to continue".
+func (l *Linenoise) ReadKey(timeout time.Duration) (KeyEvent, error) {
+	restore, err := l.keyMode()
+	if err != nil {
+		return KeyEvent{}, err
 	}
+	defer restore()
+	return l.readKeyFd(stdinFd, timeout)
+}
 
-	// restore the terminal mode for stdin
-	l.disableRawMode(syscall.Stdin)
-	return rc
+// ReadKeyContext waits for a key press like ReadKey. Reading stops when the
+// context is cancelled and ctx.Err() is returned.
+func (l *Linenoise) ReadKeyContext(ctx context.Context, timeout time.Duration) (KeyEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return KeyEvent{}, err
+	}
+	defer l.setContext(ctx)()
+	return l.ReadKey(timeout)
 }
 
-//-----------------------------------------------------------------------------
-// Key Code Debugging
+// ReadKeycode waits for a key press and returns the key code.
+// Escape sequences are returned as KeycodeESC, use ReadKey to decode them.
+// The timeout is the same as for ReadKey.
+func (l *Linenoise) ReadKeycode(timeout time.Duration) (rune, error) {
+	k, err := l.ReadKey(timeout)
+	return k.Rune, err
+}
 
 // PrintKeycodes prints scan codes on the screen for debugging/development purposes.
 func (l *Linenoise) PrintKeycodes() {
@@ -988,54 +2749,18 @@ func (l *Linenoise) PrintKeycodes() {
 	fmt.Printf("Linenoise key codes debugging mode.\n")
 	fmt.Printf("Press keys to see scan codes. Type 'quit' at any time to exit.\n")
 
-	// set rawmode for stdin
-	err := l.enableRawMode(syscall.Stdin)
-	if err != nil {
-		log.Printf("enable rawmode error %s\n", err)
-		return
-	}
-
-	u := utf8{}
 	var cmd [4]rune
-	running := true
-
-	for running {
-		// get a rune
-		r := u.getRune(syscall.Stdin, nil)
-		if r == KeycodeNull {
-			continue
-		}
-		// display the character
-		var s string
-		if unicode.IsPrint(r) {
-			s = string(r)
-		} else {
-			switch r {
-			case KeycodeCR:
-				s = "\\r"
-			case KeycodeTAB:
-				s = "\\t"
-			case KeycodeESC:
-				s = "ESC"
-			case KeycodeLF:
-				s = "\\n"
-			case KeycodeBS:
-				s = "BS"
-			default:
-				s = "?"
-			}
-		}
-		fmt.Printf("'%s' 0x%x (%d)\r\n", s, int32(r), int32(r))
+	err := l.KeyInspector(func(k KeyEvent) bool {
+		// display the key
+		fmt.Printf("%s\r\n", k)
 		// check for quit
 		copy(cmd[:], cmd[1:])
-		cmd[3] = r
-		if string(cmd[:]) == "quit" {
-			running = false
-		}
+		cmd[3] = k.Rune
+		return string(cmd[:]) != "quit"
+	})
+	if err != nil {
+		log.Printf("key inspector error %s\n", err)
 	}
-
-	// restore the terminal mode for stdin
-	l.disableRawMode(syscall.Stdin)
 }
 
 //-----------------------------------------------------------------------------
@@ -1052,11 +2777,191 @@ func (l *Linenoise) SetCompletionCallback(fn func(string) []string) {
 	l.completionCallback = fn
 }
 
+// SetCompletionLabelCallback sets a callback for the label shown after a
+// completion while cycling through the completions, Eg. to mark its source.
+// The callback returns nil for no label.
+func (l *Linenoise) SetCompletionLabelCallback(fn func(completion string) *Hint) {
+	l.completionLabel = fn
+}
+
+// SetWordCompletionCallback sets a word completion callback function.
+// The callback is passed the line and the word being completed at the end of
+// the line (possibly empty), and returns the candidate words. The line editor
+// replaces the word with each candidate to form the line completions.
+func (l *Linenoise) SetWordCompletionCallback(fn func(line, word string) []string) {
+	if fn == nil {
+		l.completionCallback = nil
+		return
+	}
+	l.completionCallback = func(line string) []string {
+		return wordCompletions(line, fn)
+	}
+}
+
+// Return the line completions for a word completion callback.
+func wordCompletions(line string, fn func(line, word string) []string) []string {
+	// the word being completed follows the last space
+	start := strings.LastIndexByte(line, ' ') + 1
+	words := fn(line, line[start:])
+	lc := make([]string, len(words))
+	for i, w := range words {
+		lc[i] = line[:start] + w
+	}
+	return lc
+}
+
+// SetCompletionPadding sets display padding for line completions.
+// Completions are padded with spaces to the width of the line buffer while
+// they are displayed. The padding is not inserted into the line buffer.
+func (l *Linenoise) SetCompletionPadding(mode bool) {
+	l.completionPad = mode
+}
+
+// SetMaskChar sets the character displayed for each character of a masked
+// line ('*' by default). Use 0 to echo nothing.
+func (l *Linenoise) SetMaskChar(r rune) {
+	l.maskChar = r
+}
+
+// SetBracketedPaste sets bracketed paste mode (on by default). Pasted text is
+// inserted literally rather than being interpreted as keystrokes, line breaks
+// in the text are inserted as spaces.
+func (l *Linenoise) SetBracketedPaste(enable bool) {
+	l.bracketedPaste = enable
+}
+
+// SetOverwrite sets overwrite mode, typed characters replace the
+// characters at the cursor. The Insert key toggles the mode.
+func (l *Linenoise) SetOverwrite(overwrite bool) {
+	l.overwrite = overwrite
+}
+
+// Overwrite returns true in overwrite mode.
+func (l *Linenoise) Overwrite() bool {
+	return l.overwrite
+}
+
+// SetOverwriteCallback sets a function called when the overwrite mode is
+// toggled while editing. It returns the prompt showing the mode, "" keeps
+// the prompt.
+func (l *Linenoise) SetOverwriteCallback(fn func(overwrite bool) string) {
+	l.overwriteCallback = fn
+}
+
 // SetHintsCallback sets the hints callback function.
 func (l *Linenoise) SetHintsCallback(fn func(string) *Hint) {
 	l.hintsCallback = fn
 }
 
+// Columns returns the number of columns for the terminal.
+// Returns 0 if the output is not a terminal and the columns have not been set.
+func (l *Linenoise) Columns() int {
+	if cols, _ := l.size(); cols > 0 {
+		return cols
+	}
+	if l.term != nil {
+		cols, _ := l.term.Size()
+		return cols
+	}
+	if !isTerminal(stdoutFd) {
+		return 0
+	}
+	return getColumns(stdinFd, stdoutFd)
+}
+
+// Rows returns the number of rows for the terminal.
+// Returns 0 if the output is not a terminal and the rows have not been set.
+func (l *Linenoise) Rows() int {
+	_, rows := l.size()
+	return l.detectRows(rows)
+}
+
+// Return the rows if they are set, or detect them.
+func (l *Linenoise) detectRows(rows int) int {
+	if rows > 0 {
+		return rows
+	}
+	if l.term != nil {
+		_, rows := l.term.Size()
+		return rows
+	}
+	return termRows()
+}
+
+// Get the number of columns for line editing.
+func (l *Linenoise) columns(ifd, ofd int) int {
+	cols, _ := l.size()
+	return l.detectColumns(cols, ifd, ofd)
+}
+
+// Return the columns for line editing if they are set, or detect them.
+func (l *Linenoise) detectColumns(cols, ifd, ofd int) int {
+	if cols > 0 {
+		return cols
+	}
+	if l.term != nil {
+		if cols, _ := l.term.Size(); cols > 0 {
+			return cols
+		}
+	}
+	if l.input != nil {
+		return defaultCols
+	}
+	return getColumns(ifd, ofd)
+}
+
+// Return the columns and rows set for the terminal (0 if not set).
+func (l *Linenoise) size() (int, int) {
+	l.asyncLock.Lock()
+	defer l.asyncLock.Unlock()
+	return l.cols, l.rows
+}
+
+// SetColumns sets the number of terminal columns, Eg. from a telnet NAWS
+// or ssh window-change message. Use 0 to detect the columns.
+// A line being edited is redrawn. It's safe to call from other goroutines.
+func (l *Linenoise) SetColumns(cols int) {
+	l.injectInit()
+	l.asyncLock.Lock()
+	defer l.asyncLock.Unlock()
+	l.cols = cols
+	if !l.canWake() {
+		return
+	}
+	l.asyncResize = true
+	l.wakeEdit()
+}
+
+// Watch for terminal resizes (SIGWINCH) while a line is edited.
+// Returns a function to stop watching.
+func (l *Linenoise) watchResize() func() {
+	ch := make(chan os.Signal, 1)
+	notifyResize(ch)
+	go func() {
+		for range ch {
+			l.asyncLock.Lock()
+			if l.editing && l.wakePipe != nil && l.cols == 0 {
+				l.asyncResize = true
+				// wake up the edit loop
+				writeAll(l.wakePipe[1], []byte{0})
+			}
+			l.asyncLock.Unlock()
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(ch)
+	}
+}
+
+// SetRows sets the number of terminal rows. Use 0 to detect the rows.
+// It's safe to call from other goroutines.
+func (l *Linenoise) SetRows(rows int) {
+	l.asyncLock.Lock()
+	defer l.asyncLock.Unlock()
+	l.rows = rows
+}
+
 // SetMultiline sets multiline editing mode.
 func (l *Linenoise) SetMultiline(mode bool) {
 	l.mlmode = mode
@@ -1068,9 +2973,174 @@ func (l *Linenoise) SetHotkey(key rune) {
 	l.hotkey = key
 }
 
+// SetHelpCallback sets a key and a callback function for inline help.
+// When the key is pressed the callback is passed the line up to the cursor
+// and the returned help is displayed below the line. The line is then redrawn
+// unchanged.
+func (l *Linenoise) SetHelpCallback(key rune, fn func(string) string) {
+	l.helpKey = key
+	l.helpCallback = fn
+}
+
+// SetTrace sets a writer for debug tracing of decoded keys, escape sequences,
+// line refreshes and editor state changes. Use nil to disable tracing.
+func (l *Linenoise) SetTrace(w io.Writer) {
+	l.trace = w
+}
+
+// Write a debug trace message.
+func (l *Linenoise) tracef(format string, a ...interface{}) {
+	if l.trace != nil {
+		fmt.Fprintf(l.trace, format+"\n", a...)
+	}
+}
+
+// EditStats are the statistics of the edit loop.
+type EditStats struct {
+	Keys      uint64 // keys read
+	Refreshes uint64 // line refreshes
+	Writes    uint64 // output writes
+	Bytes     uint64 // output bytes
+}
+
+// SetStats enables or disables counting of edit loop statistics.
+// Enabling the statistics resets them.
+func (l *Linenoise) SetStats(enable bool) {
+	if enable {
+		l.stats = &EditStats{}
+	} else {
+		l.stats = nil
+	}
+}
+
+// Stats returns the edit loop statistics.
+// It's safe to call from other goroutines.
+func (l *Linenoise) Stats() EditStats {
+	s := l.stats
+	if s == nil {
+		return EditStats{}
+	}
+	return EditStats{
+		Keys:      atomic.LoadUint64(&s.Keys),
+		Refreshes: atomic.LoadUint64(&s.Refreshes),
+		Writes:    atomic.LoadUint64(&s.Writes),
+		Bytes:     atomic.LoadUint64(&s.Bytes),
+	}
+}
+
+// Count an edit loop statistic.
+func (l *Linenoise) count(x *uint64, n int) {
+	if l.stats != nil {
+		atomic.AddUint64(x, uint64(n))
+	}
+}
+
+// SetIdleTimeout sets an idle timeout for line editing.
+// If there is no input for the duration Read returns ErrIdle.
+// A zero duration disables the timeout.
+func (l *Linenoise) SetIdleTimeout(d time.Duration) {
+	l.idleTimeout = d
+	l.idleDeadline = time.Time{}
+}
+
+// SetEscMode sets the behavior of a single ESC key press.
+func (l *Linenoise) SetEscMode(mode EscMode) {
+	l.escMode = mode
+}
+
+// SetNextLine preloads the line buffer for the next call to Read.
+// It takes precedence over the initial line passed to Read.
+func (l *Linenoise) SetNextLine(line string) {
+	l.nextLine = line
+}
+
 //-----------------------------------------------------------------------------
 // Command History
 
+// HistoryFormat is the file format for saved history.
+type HistoryFormat int
+
+// History file formats
+const (
+	HistoryPlain          HistoryFormat = iota // one entry per line (default)
+	HistoryLegacy                              // newline separated entries, no final newline
+	HistoryExtended                            // zsh timestamped entries ": <seconds>:0;<entry>"
+	HistoryExtendedStatus                      // timestamped entries with the result ": <seconds>:0:<status>;<entry>"
+)
+
+// historyEntry is a single command history entry.
+type historyEntry struct {
+	line   string        // command line
+	time   time.Time     // time the entry was added, zero if unknown
+	status historyStatus // result of the command
+}
+
+// historyStatus is the result of a command in the history.
+type historyStatus int
+
+const (
+	statusUnknown historyStatus = iota // no result recorded
+	statusOK                           // the command succeeded
+	statusFailed                       // the command failed
+)
+
+// status names for the extended history format with results
+var statusNames = map[historyStatus]string{
+	statusOK:     "ok",
+	statusFailed: "fail",
+}
+
+// Return the history status for a command result.
+func resultStatus(err error) historyStatus {
+	if err != nil {
+		return statusFailed
+	}
+	return statusOK
+}
+
+// Parse a history entry from a line of a history file.
+func parseHistoryEntry(s string) historyEntry {
+	// extended format: ": <seconds>:<duration>[:<status>];<entry>"
+	if strings.HasPrefix(s, ": ") {
+		i := strings.IndexByte(s, ';')
+		if i > 0 {
+			x := strings.Split(s[2:i], ":")
+			secs, err := strconv.ParseInt(x[0], 10, 64)
+			if err == nil && (len(x) == 2 || len(x) == 3) {
+				e := historyEntry{line: strings.TrimSpace(s[i+1:])}
+				if secs != 0 {
+					e.time = time.Unix(secs, 0)
+				}
+				if len(x) == 3 {
+					for k, v := range statusNames {
+						if x[2] == v {
+							e.status = k
+						}
+					}
+				}
+				return e
+			}
+		}
+	}
+	// plain format
+	return historyEntry{line: s}
+}
+
+// Return the history file string for a history entry.
+func (e *historyEntry) String(format HistoryFormat) string {
+	if format != HistoryExtended && format != HistoryExtendedStatus {
+		return e.line
+	}
+	var secs int64
+	if !e.time.IsZero() {
+		secs = e.time.Unix()
+	}
+	if name, ok := statusNames[e.status]; ok && format == HistoryExtendedStatus {
+		return fmt.Sprintf(": %d:0:%s;%s", secs, name, e.line)
+	}
+	return fmt.Sprintf(": %d:0;%s", secs, e.line)
+}
+
 // pop an entry from the history list
 func (l *Linenoise) historyPop(idx int) string {
 	if idx < 0 {
@@ -1078,7 +3148,7 @@ func (l *Linenoise) historyPop(idx int) string {
 		idx = len(l.history) - 1
 	}
 	if idx >= 0 && idx < len(l.history) {
-		s := l.history[idx]
+		s := l.history[idx].line
 		l.history = append(l.history[:idx], l.history[idx+1:]...)
 		return s
 	}
@@ -1086,23 +3156,36 @@ func (l *Linenoise) historyPop(idx int) string {
 	return ""
 }
 
+// push an entry onto the history list without any checks
+func (l *Linenoise) historyPush(line string) {
+	l.history = append(l.history, historyEntry{line: line})
+}
+
 // Set a history entry by index number.
 func (l *Linenoise) historySet(idx int, line string) {
-	l.history[len(l.history)-1-idx] = line
+	l.history[len(l.history)-1-idx].line = line
 }
 
 // Get a history entry by index number.
 func (l *Linenoise) historyGet(idx int) string {
-	return l.history[len(l.history)-1-idx]
+	return l.history[len(l.history)-1-idx].line
 }
 
 // Return the full history list.
 func (l *Linenoise) historyList() []string {
-	return l.history
+	h := make([]string, len(l.history))
+	for i := range h {
+		h[i] = l.history[i].line
+	}
+	return h
 }
 
 // Return next history item.
 func (l *Linenoise) historyNext(ls *linestate) string {
+	if l.masked {
+		// no history for masked input
+		return ls.String()
+	}
 	if len(l.history) == 0 {
 		return ""
 	}
@@ -1118,6 +3201,10 @@ func (l *Linenoise) historyNext(ls *linestate) string {
 
 // Return previous history item.
 func (l *Linenoise) historyPrev(ls *linestate) string {
+	if l.masked {
+		// no history for masked input
+		return ls.String()
+	}
 	if len(l.history) == 0 {
 		return ""
 	}
@@ -1131,13 +3218,26 @@ func (l *Linenoise) historyPrev(ls *linestate) string {
 	return l.historyGet(ls.historyIndex)
 }
 
+// Return the full history with the entry details.
+func (l *Linenoise) historyEntries() []historyEntry {
+	return append([]historyEntry(nil), l.history...)
+}
+
 // HistoryAdd adds a new entry to the history.
 func (l *Linenoise) HistoryAdd(line string) {
+	l.historyAdd(line, statusUnknown)
+}
+
+// Add a new entry to the history with the result of the command.
+func (l *Linenoise) historyAdd(line string, status historyStatus) {
 	if l.historyMaxlen == 0 {
 		return
 	}
-	// don't re-add the last entry
-	if len(l.history) != 0 && line == l.history[len(l.history)-1] {
+	// don't re-add the last entry, update its result
+	if n := len(l.history); n != 0 && line == l.history[n-1].line {
+		if status != statusUnknown {
+			l.history[n-1].status = status
+		}
 		return
 	}
 	// add the line to the history
@@ -1145,7 +3245,21 @@ func (l *Linenoise) HistoryAdd(line string) {
 		// remove the first entry
 		l.historyPop(0)
 	}
-	l.history = append(l.history, line)
+	l.history = append(l.history, historyEntry{line, time.Now(), status})
+}
+
+// HistoryDelete deletes a history entry. Index 0 is the latest entry.
+// An index out of range is ignored.
+func (l *Linenoise) HistoryDelete(idx int) {
+	if idx < 0 || idx >= len(l.history) {
+		return
+	}
+	l.historyPop(len(l.history) - 1 - idx)
+}
+
+// HistoryClear deletes all history entries.
+func (l *Linenoise) HistoryClear() {
+	l.history = nil
 }
 
 // HistorySetMaxlen sets the maximum length for the history.
@@ -1162,55 +3276,119 @@ func (l *Linenoise) HistorySetMaxlen(n int) {
 	}
 }
 
+// Merge two history lists and return the latest maxlen entries.
+// Duplicate entries are removed, keeping the latest.
+func historyMerge(a, b []historyEntry, maxlen int) []historyEntry {
+	h := append(append([]historyEntry{}, a...), b...)
+	seen := make(map[string]bool)
+	merged := make([]historyEntry, len(h))
+	n := len(merged)
+	for i := len(h) - 1; i >= 0 && n > len(merged)-maxlen; i-- {
+		if !seen[h[i].line] {
+			seen[h[i].line] = true
+			n--
+			merged[n] = h[i]
+		}
+	}
+	return merged[n:]
+}
+
+// SetHistoryMerge sets merge-on-save mode.
+// When saving, the history in the file is merged with the current history
+// rather than being overwritten by it.
+func (l *Linenoise) SetHistoryMerge(mode bool) {
+	l.historyMerge = mode
+}
+
+// SetHistoryFormat sets the file format used when saving history.
+// Loading history accepts any of the formats.
+func (l *Linenoise) SetHistoryFormat(format HistoryFormat) {
+	l.historyFormat = format
+}
+
 // HistorySave saves the history to a file.
+// The history is written to a temporary file which is then renamed, so the
+// file is never left partially written.
 func (l *Linenoise) HistorySave(fname string) {
-	if len(l.history) == 0 {
-		return
+	history := l.history
+	if l.historyMerge {
+		h, _ := historyRead(fname)
+		history = historyMerge(h, history, l.historyMaxlen)
 	}
-	f, err := os.Create(fname)
+	// build the file contents
+	s := make([]string, len(history))
+	for i := range history {
+		s[i] = history[i].String(l.historyFormat)
+	}
+	buf := strings.Join(s, "\n")
+	if l.historyFormat != HistoryLegacy && len(buf) != 0 {
+		buf += "\n"
+	}
+	f, err := ioutil.TempFile(filepath.Dir(fname), filepath.Base(fname)+".tmp")
 	if err != nil {
 		log.Printf("error opening %s\n", fname)
 		return
 	}
-	_, err = f.WriteString(strings.Join(l.history, "\n"))
+	_, err = f.WriteString(buf)
+	if err == nil {
+		err = f.Sync()
+	}
+	f.Close()
 	if err != nil {
 		log.Printf("%s error writing %s\n", fname, err)
+		os.Remove(f.Name())
+		return
+	}
+	err = os.Rename(f.Name(), fname)
+	if err != nil {
+		log.Printf("%s error on rename %s\n", fname, err)
+		os.Remove(f.Name())
 	}
-	f.Close()
 }
 
-// HistoryLoad loads history from a file.
-func (l *Linenoise) HistoryLoad(fname string) {
+// Read the history entries from a file.
+// Return false if the history could not be read.
+func historyRead(fname string) ([]historyEntry, bool) {
 	info, err := os.Stat(fname)
 	if err != nil {
-		return
+		return nil, false
 	}
 	if !info.Mode().IsRegular() {
 		log.Printf("%s is not a regular file\n", fname)
-		return
+		return nil, false
 	}
 	f, err := os.Open(fname)
 	if err != nil {
 		log.Printf("%s error on open %s\n", fname, err)
-		return
+		return nil, false
 	}
+	defer f.Close()
 	b := bufio.NewReader(f)
-	l.history = make([]string, 0, l.historyMaxlen)
+	history := make([]historyEntry, 0, 32)
 	for {
 		s, err := b.ReadString('\n')
 		if err == nil || err == io.EOF {
 			s = strings.TrimSpace(s)
 			if len(s) != 0 {
-				l.history = append(l.history, s)
+				history = append(history, parseHistoryEntry(s))
 			}
 			if err == io.EOF {
 				break
 			}
 		} else {
 			log.Printf("%s error on read %s\n", fname, err)
+			break
 		}
 	}
-	f.Close()
+	return history, true
+}
+
+// HistoryLoad loads history from a file.
+func (l *Linenoise) HistoryLoad(fname string) {
+	history, ok := historyRead(fname)
+	if ok {
+		l.history = history
+	}
 }
 
 //-----------------------------------------------------------------------------
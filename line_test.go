@@ -0,0 +1,806 @@
+package cli
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ReadBasic(t *testing.T) {
+	l := NewLineNoise()
+	l.scanner = bufio.NewScanner(strings.NewReader("show\n\nexit"))
+	for i, v := range []string{"show", "", "exit"} {
+		s, err := l.readBasic()
+		if err != nil || s != v {
+			t.Errorf("%d: FAIL expected (%q, nil) != actual (%q, %v)", i, v, s, err)
+		}
+	}
+	_, err := l.readBasic()
+	if err != ErrEOF {
+		t.Errorf("FAIL expected ErrEOF != actual %v", err)
+	}
+}
+
+func Test_HistorySave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "history.txt")
+
+	l0 := NewLineNoise()
+	for _, s := range []string{"a", "b", "c"} {
+		l0.HistoryAdd(s)
+	}
+	l0.HistorySave(fname)
+
+	l1 := NewLineNoise()
+	l1.SetHistoryMerge(true)
+	for _, s := range []string{"b", "d"} {
+		l1.HistoryAdd(s)
+	}
+	l1.HistorySave(fname)
+
+	l2 := NewLineNoise()
+	l2.HistoryLoad(fname)
+	h := strings.Join(l2.historyList(), " ")
+	if h != "a c b d" {
+		t.Errorf("FAIL expected (a c b d) != actual (%s)", h)
+	}
+	// no temporary files left behind
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("FAIL expected 1 file != actual %d", len(files))
+	}
+}
+
+func Test_HistoryDeleteIndex(t *testing.T) {
+	l := NewLineNoise()
+	for _, s := range []string{"a", "b", "c"} {
+		l.HistoryAdd(s)
+	}
+	tests := []struct {
+		idx  int
+		hist string
+	}{
+		{-1, "a b c"},
+		{3, "a b c"},
+		{0, "a b"},
+		{1, "b"},
+		{1, "b"},
+		{0, ""},
+	}
+	for i, v := range tests {
+		l.HistoryDelete(v.idx)
+		h := strings.Join(l.historyList(), " ")
+		if h != v.hist {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.hist, h)
+		}
+	}
+}
+
+func Test_HistoryMerge(t *testing.T) {
+	a := []historyEntry{{line: "a"}, {line: "b"}, {line: "c"}}
+	b := []historyEntry{{line: "a"}, {line: "d"}}
+	s := []string{}
+	for _, e := range historyMerge(a, b, 3) {
+		s = append(s, e.line)
+	}
+	h := strings.Join(s, " ")
+	if h != "c a d" {
+		t.Errorf("FAIL expected (c a d) != actual (%s)", h)
+	}
+}
+
+func Test_HistoryFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "history.txt")
+
+	tests := []struct {
+		format HistoryFormat
+		file   string
+	}{
+		{HistoryPlain, "a\nb c\n"},
+		{HistoryLegacy, "a\nb c"},
+		{HistoryExtended, ": 1000:0;a\n: 0:0;b c\n"},
+		{HistoryExtendedStatus, ": 1000:0;a\n: 0:0:fail;b c\n"},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		l.history = []historyEntry{{line: "a", time: time.Unix(1000, 0)}, {line: "b c", status: statusFailed}}
+		l.SetHistoryFormat(v.format)
+		l.HistorySave(fname)
+		buf, _ := ioutil.ReadFile(fname)
+		if string(buf) != v.file {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.file, buf)
+		}
+		// read it back
+		l = NewLineNoise()
+		l.HistoryLoad(fname)
+		if len(l.history) != 2 || l.history[0].line != "a" || l.history[1].line != "b c" {
+			t.Errorf("%d: FAIL bad history load %v", i, l.history)
+		}
+		if v.format >= HistoryExtended && l.history[0].time.Unix() != 1000 {
+			t.Errorf("%d: FAIL bad history time %v", i, l.history[0].time)
+		}
+		if v.format == HistoryExtendedStatus && l.history[1].status != statusFailed {
+			t.Errorf("%d: FAIL bad history status %v", i, l.history[1].status)
+		}
+	}
+}
+
+func Test_KeyEvent(t *testing.T) {
+	tests := []struct {
+		k    KeyEvent
+		name string
+	}{
+		{KeyEvent{Rune: 'a'}, "a"},
+		{KeyEvent{Rune: KeycodeCR}, "\\r"},
+		{KeyEvent{Rune: KeycodeESC}, "ESC"},
+		{KeyEvent{Rune: KeycodeESC, Seq: "[A"}, "<up>"},
+		{KeyEvent{Rune: KeycodeESC, Seq: "[1;5C"}, "ESC [1;5C"},
+	}
+	for i, v := range tests {
+		if v.k.Name() != v.name {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.name, v.k.Name())
+		}
+	}
+}
+
+// decode a byte string with the utf8 decoder
+func utf8Decode(buf []byte) string {
+	u := utf8{}
+	s := []rune{}
+	for i := 0; i < len(buf); {
+		c, ok := u.next()
+		if !ok {
+			c = buf[i]
+			i++
+		}
+		r, size := u.add(c)
+		if size != 0 {
+			s = append(s, r)
+		}
+	}
+	if c, ok := u.next(); ok {
+		r, _ := u.add(c)
+		s = append(s, r)
+	}
+	if u.more != 0 {
+		// truncated sequence
+		s = append(s, '�')
+	}
+	return string(s)
+}
+
+func Test_UTF8(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"abc", "abc"},
+		{"é", "é"},
+		{"€", "€"},
+		{"\U0001f600", "\U0001f600"},
+		{"\U0010ffff", "\U0010ffff"},
+		{"a世b\U00010348c", "a世b\U00010348c"},
+		// invalid lead bytes
+		{"\x80", "�"},
+		{"a\xffb", "a�b"},
+		{"\xf5\x80", "��"},
+		// overlong encodings
+		{"\xc0\xaf", "��"},
+		{"\xc1\xbf", "��"},
+		{"\xe0\x80\xaf", "���"},
+		{"\xf0\x80\x80\xaf", "����"},
+		// surrogates
+		{"\xed\xa0\x80", "���"},
+		{"\xed\xbf\xbf", "���"},
+		// > U+10FFFF
+		{"\xf4\x90\x80\x80", "����"},
+		// interrupted sequences
+		{"\xe2\x82a", "�a"},
+		{"\xf0\x9f\x98\xe2\x82\xac", "�€"},
+		{"\xc3\xc3\xa9", "�é"},
+		// truncated sequence
+		{"a\xe2\x82", "a�"},
+	}
+	for i, v := range tests {
+		out := utf8Decode([]byte(v.in))
+		if out != v.out {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.out, out)
+		}
+	}
+}
+
+func Test_PauseResume(t *testing.T) {
+	l := NewLineNoise()
+	l.Resume()
+	l.Pause()
+	l.Pause()
+	resumed := make(chan bool)
+	go func() {
+		l.waitResume()
+		resumed <- true
+	}()
+	select {
+	case <-resumed:
+		t.Errorf("FAIL resumed before Resume()")
+	case <-time.After(10 * time.Millisecond):
+	}
+	l.Resume()
+	select {
+	case <-resumed:
+	case <-time.After(time.Second):
+		t.Errorf("FAIL not resumed after Resume()")
+	}
+
+	// editing stops before the edit loop pauses
+	l.setEditing(true)
+	paused := make(chan bool)
+	go func() {
+		l.Pause()
+		paused <- true
+	}()
+	time.Sleep(10 * time.Millisecond)
+	l.setEditing(false)
+	select {
+	case <-paused:
+	case <-time.After(time.Second):
+		t.Errorf("FAIL Pause() did not return")
+	}
+	l.Resume()
+}
+
+func Test_SetColumns(t *testing.T) {
+	l := NewLineNoise()
+	l.SetColumns(40)
+	l.SetRows(12)
+	ls := newLineState(-1, -1, "> ", l)
+	if ls.cols != 40 || l.Columns() != 40 {
+		t.Errorf("FAIL expected (40) != actual (%d, %d)", ls.cols, l.Columns())
+	}
+	if l.Rows() != 12 {
+		t.Errorf("FAIL expected (12) != actual (%d)", l.Rows())
+	}
+	// a resize while editing is flagged for the edit loop
+	l.setEditing(true)
+	l.SetColumns(100)
+	if !l.asyncResize {
+		t.Errorf("FAIL resize not flagged")
+	}
+	l.setEditing(false)
+	if l.asyncResize {
+		t.Errorf("FAIL resize not cleared")
+	}
+}
+
+func Test_HelpKeyEscape(t *testing.T) {
+	help := 0
+	l := NewLineNoise()
+	l.SetHelpCallback('?', func(s string) string {
+		help++
+		return ""
+	})
+	line, err := l.Edit(NewByteSource([]byte("a\\?\r")), ioutil.Discard, "> ", "")
+	if line != "a\\?" || err != nil || help != 0 {
+		t.Errorf("FAIL expected (%q, 0) != actual (%q, %d)", "a\\?", line, help)
+	}
+	line, _ = l.Edit(NewByteSource([]byte("a?\r")), ioutil.Discard, "> ", "")
+	if line != "a" || help != 1 {
+		t.Errorf("FAIL expected (%q, 1) != actual (%q, %d)", "a", line, help)
+	}
+}
+
+func Test_EditSource(t *testing.T) {
+	tests := []struct {
+		in   string
+		init string
+		line string
+		err  error
+	}{
+		{"abc\r", "", "abc", nil},
+		{"abc\x02X\r", "", "abXc", nil},
+		{"\x1b[D\x1b[DX\r", "abc", "aXbc", nil},
+		{"é世\x08\r", "", "é", nil},
+		{"abc", "", "", io.EOF},
+		{"\x03", "", "", ErrQuit},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		var out strings.Builder
+		line, err := l.Edit(NewByteSource([]byte(v.in)), &out, "> ", v.init)
+		if line != v.line || err != v.err {
+			t.Errorf("%d: FAIL expected (%q, %v) != actual (%q, %v)", i, v.line, v.err, line, err)
+		}
+	}
+}
+
+func Test_EditFuzz(t *testing.T) {
+	// random input must not panic the editor
+	rnd := rand.New(rand.NewSource(1))
+	keys := []byte("ab \x01\x02\x03\x04\x05\x06\x08\x09\x0b\x0c\x0e\x10\x12\x14\x15\x17\x1b[]ABCDHF~0123456789;\x7f\r\xc3\xa9\xe4\xb8")
+	l := NewLineNoise()
+	l.SetMultiline(true)
+	l.SetCompletionCallback(func(s string) []string { return []string{s + "x", s + "yy"} })
+	for i := 0; i < 2000; i++ {
+		buf := make([]byte, rnd.Intn(64))
+		for j := range buf {
+			if rnd.Intn(8) == 0 {
+				buf[j] = byte(rnd.Intn(256))
+			} else {
+				buf[j] = keys[rnd.Intn(len(keys))]
+			}
+		}
+		l.SetColumns(1 + rnd.Intn(20))
+		l.Edit(NewByteSource(buf), ioutil.Discard, "> ", "")
+	}
+}
+
+func Test_EditStats(t *testing.T) {
+	l := NewLineNoise()
+	l.SetColumns(80)
+	l.Edit(NewByteSource([]byte("abc\r")), ioutil.Discard, "> ", "")
+	if l.Stats() != (EditStats{}) {
+		t.Errorf("FAIL stats counted when disabled")
+	}
+	l.SetStats(true)
+	var out strings.Builder
+	l.Edit(NewByteSource([]byte("abc\r")), &out, "> ", "")
+	st := l.Stats()
+	if st.Keys != 4 || st.Refreshes != 4 || st.Writes != 4 || st.Bytes != uint64(out.Len()) {
+		t.Errorf("FAIL bad stats %+v (%d bytes)", st, out.Len())
+	}
+}
+
+// benchmark the edit of a long line
+func benchmarkEdit(b *testing.B, multi bool) {
+	line := strings.Repeat("abcdefghij", 20)
+	in := []byte(line + "\x01" + strings.Repeat("\x06", len(line)) + "\r")
+	l := NewLineNoise()
+	l.SetColumns(80)
+	l.SetMultiline(multi)
+	l.SetStats(true)
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		l.Edit(NewByteSource(in), ioutil.Discard, "> ", "")
+	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+	st := l.Stats()
+	b.ReportMetric(float64(st.Bytes)/float64(b.N), "bytes/edit")
+	b.ReportMetric(float64(elapsed.Nanoseconds())/float64(st.Keys), "ns/key")
+}
+
+func Benchmark_EditSingleline(b *testing.B) {
+	benchmarkEdit(b, false)
+}
+
+func Benchmark_EditMultiline(b *testing.B) {
+	benchmarkEdit(b, true)
+}
+
+func Test_RefreshAllocs(t *testing.T) {
+	l := NewLineNoise()
+	l.SetColumns(20)
+	l.output = ioutil.Discard
+	for _, multi := range []bool{false, true} {
+		l.SetMultiline(multi)
+		ls := newLineState(-1, -1, "> ", l)
+		ls.buf = []rune("0123456789abcdef世界0123456789")
+		ls.pos = 12
+		n := testing.AllocsPerRun(100, func() {
+			ls.refreshLine()
+			ls.editMoveLeft()
+			ls.editMoveRight()
+		})
+		if n != 0 {
+			t.Errorf("multiline %t: FAIL expected (0) != actual (%v) allocations", multi, n)
+		}
+	}
+}
+
+func Test_KeyNames(t *testing.T) {
+	tests := []struct {
+		k    Key
+		name string
+	}{
+		{'a', "a"},
+		{KeycodeCR, "\\r"},
+		{KeycodeTAB, "\\t"},
+		{KeycodeESC, "ESC"},
+		{KeycodeCtrlA, "Ctrl-A"},
+		{KeycodeCtrlZ, "Ctrl-Z"},
+		{KeycodeCtrlD, "Ctrl-D"},
+		{KeycodeNull, "?"},
+	}
+	for i, v := range tests {
+		if v.k.String() != v.name {
+			t.Errorf("%d: FAIL expected (%s) != actual (%s)", i, v.name, v.k)
+		}
+	}
+}
+
+func Test_WordCompletion(t *testing.T) {
+	colors := []string{"red", "green", "grey"}
+	var lastWord string
+	l := NewLineNoise()
+	l.SetWordCompletionCallback(func(line, word string) []string {
+		lastWord = word
+		var words []string
+		for _, s := range colors {
+			if strings.HasPrefix(s, word) {
+				words = append(words, s)
+			}
+		}
+		return words
+	})
+	tests := []struct {
+		line string
+		word string
+		lc   []string
+	}{
+		{"set gr", "gr", []string{"set green", "set grey"}},
+		{"set ", "", []string{"set red", "set green", "set grey"}},
+		{"r", "r", []string{"red"}},
+		{"set color b", "b", nil},
+	}
+	for i, v := range tests {
+		lc := l.completionCallback(v.line)
+		if lastWord != v.word {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.word, lastWord)
+		}
+		if strings.Join(lc, ",") != strings.Join(v.lc, ",") {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.lc, lc)
+		}
+	}
+	l.SetWordCompletionCallback(nil)
+	if l.completionCallback != nil {
+		t.Errorf("FAIL completion callback not cleared")
+	}
+}
+
+func Test_HistorySearch(t *testing.T) {
+	tests := []struct {
+		in   string
+		line string
+	}{
+		{"\x12sh\r", "show stats"},
+		{"\x12sh\x12\r", "shutdown now"},
+		{"\x12sh\x12\x12\r", "shutdown now"},
+		{"\x12now\x7f\x7f\x7f\x7fex\r", "exit"},
+		{"ab\x12sh\x07\r", "ab"},
+		{"\x12qq\r", ""},
+		{"\x12stat\x05X\r", "show statsX"},
+		{"\x12exit\x01\x1b[CY\r", "eYxit"},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		for _, s := range []string{"exit", "shutdown now", "show stats"} {
+			l.HistoryAdd(s)
+		}
+		line, err := l.Edit(NewByteSource([]byte(v.in)), ioutil.Discard, "> ", "")
+		if line != v.line || err != nil {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q, %v)", i, v.line, line, err)
+		}
+	}
+}
+
+func Test_HistoryExpand(t *testing.T) {
+	tests := []struct {
+		in   string
+		line string
+		ok   bool
+		err  bool
+	}{
+		{"show stats", "show stats", false, false},
+		{"!", "!", false, false},
+		{"!!", "show stats", true, false},
+		{" !2 ", "exit", true, false},
+		{"!-2", "shutdown now", true, false},
+		{"!3", "", true, true},
+		{"!x", "", true, true},
+	}
+	l := NewLineNoise()
+	for _, s := range []string{"exit", "shutdown now", "show stats"} {
+		l.HistoryAdd(s)
+	}
+	for i, v := range tests {
+		line, ok, err := l.historyExpand(v.in)
+		if line != v.line || ok != v.ok || (err != nil) != v.err {
+			t.Errorf("%d: FAIL expected (%q, %v, %v) != actual (%q, %v, %v)", i, v.line, v.ok, v.err, line, ok, err)
+		}
+	}
+}
+
+func Test_Overwrite(t *testing.T) {
+	tests := []struct {
+		in   string
+		init string
+		line string
+	}{
+		{"\x01\x1b[2~xy\r", "abcd", "xycd"},
+		{"\x01\x1b[2~xyz12\r", "abc", "xyz12"},
+		{"\x01\x1b[2~x\x1b[2~y\r", "abc", "xybc"},
+		{"\x01\x1b[2~xy\x1f\r", "abcd", "abcd"},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		line, err := l.Edit(NewByteSource([]byte(v.in)), ioutil.Discard, "> ", v.init)
+		if line != v.line || err != nil {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q, %v)", i, v.line, line, err)
+		}
+	}
+}
+
+func Test_CharSearch(t *testing.T) {
+	tests := []struct {
+		in   string
+		line string
+	}{
+		{"\x01\x1dcX\r", "abXc abc"},
+		{"\x01\x1daX\r", "abc Xabc"},
+		{"\x01\x1dzX\r", "Xabc abc"},
+		{"\x1b\x1daX\r", "abc Xabc"},
+		{"\x1b\x1da\x1b\x1daX\r", "Xabc abc"},
+		{"\x1b\x1dzX\r", "abc abcX"},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		line, err := l.Edit(NewByteSource([]byte(v.in)), ioutil.Discard, "> ", "abc abc")
+		if line != v.line || err != nil {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q, %v)", i, v.line, line, err)
+		}
+	}
+	// grapheme clusters and undo
+	tests2 := []struct {
+		init string
+		in   string
+		line string
+	}{
+		{"ae\u0301e", "\x01\x1deX\r", "ae\u0301Xe"},
+		{"eae\u0301", "\x1b\x1deX\r", "Xeae\u0301"},
+		{"e\u0301", "\x01\x1d\u0301X\r", "Xe\u0301"},
+		{"a\u00fc a", "\x01\x1d\u00fcX\r", "aX\u00fc a"},
+		{"\u00fc a", "\x1b\x1d\u00fcX\r", "X\u00fc a"},
+		{"", "xy\x1b\x1dxz\x1f\r", "xy"},
+	}
+	for i, v := range tests2 {
+		l := NewLineNoise()
+		line, err := l.Edit(NewByteSource([]byte(v.in)), ioutil.Discard, "> ", v.init)
+		if line != v.line || err != nil {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q, %v)", i, v.line, line, err)
+		}
+	}
+}
+
+func Test_YankLastArg(t *testing.T) {
+	tests := []struct {
+		in   string
+		line string
+	}{
+		{"\x1b.\r", "10.0.0.1"},
+		{"\x1b.\x1b.\r", "fast"},
+		{"x \x1b.\x1b.\x1b.\r", "x eth0"},
+		{"x \x1b.\x1b.\x1b.\x1b.\r", "x eth0"},
+		{"\x1b.a\x1b.\r", "10.0.0.1a10.0.0.1"},
+		{"\x1b.\x1b.\x1f\r", ""},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		for _, s := range []string{"show interface eth0", "set mode fast", "", "ping 10.0.0.1"} {
+			l.HistoryAdd(s)
+		}
+		line, err := l.Edit(NewByteSource([]byte(v.in)), ioutil.Discard, "> ", "")
+		if line != v.line || err != nil {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q, %v)", i, v.line, line, err)
+		}
+	}
+}
+
+func Test_NumericArg(t *testing.T) {
+	tests := []struct {
+		in   string
+		init string
+		line string
+	}{
+		{"\x1b3x\r", "", "xxx"},
+		{"\x1b12-\r", "", "------------"},
+		{"\x1b3\x02X\r", "abcdef", "abcXdef"},
+		{"\x1b2\x7f\r", "abcdef", "abcd"},
+		{"\x1b3\x01\x04\r", "abcdef", "bcdef"},
+		{"\x1b2\x01\x1b3\x04\r", "abcdef", "def"},
+		{"\x1b2\x17\r", "a b c", "a "},
+		{"\x1b5\r", "abc", "abc"},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		line, err := l.Edit(NewByteSource([]byte(v.in)), ioutil.Discard, "> ", v.init)
+		if line != v.line || err != nil {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q, %v)", i, v.line, line, err)
+		}
+	}
+}
+
+func Test_SwapWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		line string
+	}{
+		{"\x1bt\r", "one three  two"},
+		{"\x1bt\x1bt\r", "one two  three"},
+		{"\x01\x1bt\r", "one two  three"},
+		{"\x01\x06\x06\x06\x06\x06\x1btX\r", "two oneX  three"},
+		{"\x01\x06\x06\x06\x06\x06\x06\x06\x06\x06\x1btX\r", "one three  twoX"},
+		{"\x1bt\x1f\r", "one two  three"},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		line, err := l.Edit(NewByteSource([]byte(v.in)), ioutil.Discard, "> ", "one two  three")
+		if line != v.line || err != nil {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q, %v)", i, v.line, line, err)
+		}
+	}
+}
+
+func Test_UndoRedo(t *testing.T) {
+	tests := []struct {
+		in   string
+		init string
+		line string
+	}{
+		{"abc def\x17\x1f\r", "", "abc def"},
+		{"abc\x15\x1f\r", "", "abc"},
+		{"abc\x1f\r", "", ""},
+		{"abc\x1f\x1e\r", "", "abc"},
+		{"ab\x02X\x1f\r", "", "ab"},
+		{"\x1f\r", "xyz", "xyz"},
+		{"a\x1f\x1f\x1e\x1e\r", "", "a"},
+		{"abc\x15\x1f\x1e\r", "", ""},
+		{"abc\x0b\x01\x0b\x1f\x1f\r", "xy", "xy"},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		line, err := l.Edit(NewByteSource([]byte(v.in)), ioutil.Discard, "> ", v.init)
+		if line != v.line || err != nil {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q, %v)", i, v.line, line, err)
+		}
+	}
+}
+
+func Test_BracketedPaste(t *testing.T) {
+	tests := []struct {
+		in   string
+		line string
+	}{
+		{"ab\x1b[200~x\ny\r\nz\tw\x1b[201~c\r", "abx y z wc"},
+		{"\x1b[200~a\x1b[20b\x1b\x1b[201~\r", "a[20b"},
+		{"ac\x02\x1b[200~b\r\x1b[201~\r", "ab c"},
+		{"ab\x1b[200~cd\x1b[201~\x1f\r", "ab"},
+		{"ab\x1b[200~\x1b[201~\r", "ab"},
+		{"ab\x1b[200~cd", ""},
+		{"abc\x1b[D\x1b[3~\r", "ab"},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		line, _ := l.Edit(NewByteSource([]byte(v.in)), ioutil.Discard, "> ", "")
+		if line != v.line {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.line, line)
+		}
+	}
+}
+
+func Test_ReadPassword(t *testing.T) {
+	for _, mask := range []rune{'*', 0} {
+		l := NewLineNoise()
+		l.SetMaskChar(mask)
+		l.HistoryAdd("old")
+		var out strings.Builder
+		l.masked = true
+		line, err := l.Edit(NewByteSource([]byte("secret\x08T\r")), &out, "pw: ", "")
+		l.masked = false
+		if line != "secreT" || err != nil {
+			t.Errorf("mask %q: FAIL expected (%q) != actual (%q, %v)", mask, "secreT", line, err)
+		}
+		s := out.String()
+		if strings.ContainsAny(s, "secrT") {
+			t.Errorf("mask %q: FAIL secret displayed %q", mask, s)
+		}
+		if strings.Contains(s, "*") != (mask != 0) {
+			t.Errorf("mask %q: FAIL bad masked display %q", mask, s)
+		}
+		if h := strings.Join(l.historyList(), ","); h != "old" {
+			t.Errorf("mask %q: FAIL expected (%q) != actual (%q)", mask, "old", h)
+		}
+	}
+	// piped input
+	l := NewLineNoise()
+	l.scanner = bufio.NewScanner(strings.NewReader("secret\n"))
+	l.SetNextLine("next")
+	line, err := l.ReadPassword("password: ")
+	if line != "secret" || err != nil || l.nextLine != "next" || len(l.history) != 0 {
+		t.Errorf("FAIL expected (%q) != actual (%q, %v)", "secret", line, err)
+	}
+}
+
+// chunkSource is an input source with a pause after each chunk.
+type chunkSource struct {
+	chunks []string
+}
+
+func (s *chunkSource) ReadByte() (byte, error) {
+	for len(s.chunks) != 0 && s.chunks[0] == "" {
+		s.chunks = s.chunks[1:]
+	}
+	if len(s.chunks) == 0 {
+		return 0, io.EOF
+	}
+	c := s.chunks[0][0]
+	s.chunks[0] = s.chunks[0][1:]
+	return c, nil
+}
+
+func (s *chunkSource) Pending() bool {
+	return len(s.chunks) != 0 && s.chunks[0] != ""
+}
+
+func Test_EscMode(t *testing.T) {
+	tests := []struct {
+		mode EscMode
+		in   []string
+		line string
+	}{
+		// a single escape
+		{EscAbandon, []string{"\x1b", "x\r"}, ""},
+		{EscIgnore, []string{"\x1b", "x\r"}, "abcx"},
+		{EscClear, []string{"\x1b", "x\r"}, "x"},
+		{EscViNormal, []string{"\x1b", "x\r"}, "ab"},
+		// an escape sequence (left arrow)
+		{EscAbandon, []string{"\x1b[D", "x\r"}, "abxc"},
+		{EscIgnore, []string{"\x1b[D", "x\r"}, "abxc"},
+		{EscClear, []string{"\x1b[D", "x\r"}, "abxc"},
+		{EscViNormal, []string{"\x1b[D", "x\r"}, "abxc"},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		l.SetEscMode(v.mode)
+		line, err := l.Edit(&chunkSource{append([]string(nil), v.in...)}, ioutil.Discard, "> ", "abc")
+		if line != v.line || err != nil {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q, %v)", i, v.line, line, err)
+		}
+	}
+}
+
+func Test_Trace(t *testing.T) {
+	var trace strings.Builder
+	l := NewLineNoise()
+	l.SetTrace(&trace)
+	line, err := l.Edit(NewByteSource([]byte("a\r")), ioutil.Discard, "> ", "")
+	if line != "a" || err != nil {
+		t.Errorf("FAIL expected (a) != actual (%q, %v)", line, err)
+	}
+	for _, s := range []string{"key 'a' 0x61\n", "refresh multiline false buf \"a\" pos 1", "edit done \"a\"\n"} {
+		if !strings.Contains(trace.String(), s) {
+			t.Errorf("FAIL expected (%q) in trace %q", s, trace.String())
+		}
+	}
+	// masked input isn't traced
+	trace.Reset()
+	l.masked = true
+	l.Edit(NewByteSource([]byte("s\r")), ioutil.Discard, "> ", "")
+	if strings.Contains(trace.String(), "key 's'") || strings.Contains(trace.String(), "buf \"s\"") {
+		t.Errorf("FAIL masked key traced %q", trace.String())
+	}
+}
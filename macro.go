@@ -0,0 +1,217 @@
+//-----------------------------------------------------------------------------
+/*
+
+Command Macros
+
+The commands run during a recording are saved as a named macro that can be
+replayed later. Macros are stored as scripts (one command per line) in a
+macros directory next to the history file.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+
+// RecordHelp is help for the record command.
+var RecordHelp = []Help{
+	{"<cr>", "display all macros"},
+	{"start <name>", "start recording commands as a macro"},
+	{"stop", "stop recording and save the macro"},
+	{"delete <name>", "delete a macro"},
+}
+
+// RunHelp is help for the run command.
+var RunHelp = []Help{
+	{"<name> [args]", "run a recorded macro, the arguments are $1..$9"},
+}
+
+// macro recording and playback state
+type macros struct {
+	dir       string              // directory for macro scripts
+	scripts   map[string][]string // macro scripts by name
+	recording string              // name of the macro being recorded
+	lines     []string            // lines recorded so far
+}
+
+//-----------------------------------------------------------------------------
+
+// Return an error if the macro name is not valid.
+func macroName(name string) error {
+	if name == "" || strings.ContainsAny(name, "/\\. ") {
+		return errors.New("invalid macro name")
+	}
+	return nil
+}
+
+// Load the macro scripts from a directory.
+func (c *CLI) macroLoad(dir string) {
+	c.macro.dir = dir
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		if f.IsDir() || macroName(f.Name()) != nil {
+			continue
+		}
+		buf, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			continue
+		}
+		c.setMacro(f.Name(), strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n"))
+	}
+}
+
+// Save a macro script to the macro directory.
+func (c *CLI) macroSave(name string) error {
+	if c.macro.dir == "" {
+		return nil
+	}
+	err := os.MkdirAll(c.macro.dir, 0700)
+	if err != nil {
+		return err
+	}
+	s := strings.Join(c.macro.scripts[name], "\n") + "\n"
+	return ioutil.WriteFile(filepath.Join(c.macro.dir, name), []byte(s), 0600)
+}
+
+func (c *CLI) setMacro(name string, lines []string) {
+	if c.macro.scripts == nil {
+		c.macro.scripts = make(map[string][]string)
+	}
+	c.macro.scripts[name] = lines
+}
+
+// Record a command line run during the recording of a macro.
+// The recording must have been in progress before the line was run.
+// Lines run by scripts are not recorded.
+func (c *CLI) macroRecord(recording, line string) {
+	if recording != "" && recording == c.macro.recording && c.scriptDepth == 0 {
+		c.macro.lines = append(c.macro.lines, line)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// RecordStart starts recording commands as a named macro.
+func (c *CLI) RecordStart(name string) error {
+	if err := macroName(name); err != nil {
+		return err
+	}
+	if c.macro.recording != "" {
+		return fmt.Errorf("already recording \"%s\"", c.macro.recording)
+	}
+	c.macro.recording = name
+	c.macro.lines = nil
+	return nil
+}
+
+// RecordStop stops recording and saves the macro.
+func (c *CLI) RecordStop() error {
+	name := c.macro.recording
+	if name == "" {
+		return errors.New("not recording")
+	}
+	c.macro.recording = ""
+	if len(c.macro.lines) == 0 {
+		return errors.New("no commands recorded")
+	}
+	c.setMacro(name, c.macro.lines)
+	c.macro.lines = nil
+	return c.macroSave(name)
+}
+
+// RunMacro runs the commands of a named macro with optional arguments.
+func (c *CLI) RunMacro(name string, args ...string) error {
+	lines, ok := c.macro.scripts[name]
+	if !ok {
+		return fmt.Errorf("no macro \"%s\"", name)
+	}
+	return c.RunScript(lines, args...)
+}
+
+// Delete a macro.
+func (c *CLI) deleteMacro(name string) error {
+	if _, ok := c.macro.scripts[name]; !ok {
+		return fmt.Errorf("no macro \"%s\"", name)
+	}
+	delete(c.macro.scripts, name)
+	if c.macro.dir != "" {
+		return os.Remove(filepath.Join(c.macro.dir, name))
+	}
+	return nil
+}
+
+// display the macros
+func (c *CLI) displayMacros() {
+	if len(c.macro.scripts) == 0 {
+		c.Put("no macros\n")
+		return
+	}
+	names := make([]string, 0, len(c.macro.scripts))
+	for k := range c.macro.scripts {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	s := make([][]string, len(names))
+	for i, k := range names {
+		s[i] = []string{k, strings.Join(c.macro.scripts[k], "; ")}
+	}
+	c.Put(TableString(s, []int{16, 0}, 1) + "\n")
+}
+
+// RecordCmd is a leaf function helper for a record command.
+func (c *CLI) RecordCmd(args []string) {
+	var err error
+	if len(args) == 0 {
+		c.displayMacros()
+		return
+	}
+	switch args[0] {
+	case "start":
+		err = CheckArgc(args, []int{2})
+		if err == nil {
+			err = c.RecordStart(args[1])
+		}
+	case "stop":
+		err = c.RecordStop()
+	case "delete":
+		err = CheckArgc(args, []int{2})
+		if err == nil {
+			err = c.deleteMacro(args[1])
+		}
+	default:
+		err = errors.New("invalid argument")
+	}
+	if err != nil {
+		c.Put(fmt.Sprintf("%s\n", err))
+	}
+}
+
+// RunCmd is a leaf function helper for a run command.
+func (c *CLI) RunCmd(args []string) {
+	var err error
+	if len(args) == 0 {
+		err = errors.New("bad number of arguments")
+	} else {
+		err = c.RunMacro(args[0], args[1:]...)
+	}
+	if err != nil {
+		c.Put(fmt.Sprintf("%s\n", err))
+	}
+	c.SetResult(err)
+}
+
+//-----------------------------------------------------------------------------
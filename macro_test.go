@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Macro(t *testing.T) {
+	dir, err := ioutil.TempDir("", "macro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var ran []string
+	menu := Menu{
+		{"record", Leaf{"record", func(c *CLI, args []string) { c.RecordCmd(args) }}},
+		{"run", Leaf{"run", func(c *CLI, args []string) { c.RunCmd(args) }}},
+		{"show", Leaf{"show", func(c *CLI, args []string) { ran = append(ran, args[0]) }}},
+	}
+	c := NewCLI(&testUser{})
+	c.SetRoot(menu)
+	c.HistoryLoad(filepath.Join(dir, "history.txt"))
+	for _, s := range []string{"record start m", "show a", "sh b", "record stop", "show c"} {
+		c.parseCmdline(s)
+	}
+
+	// replay in a new cli
+	c = NewCLI(&testUser{})
+	c.SetRoot(menu)
+	c.HistoryLoad(filepath.Join(dir, "history.txt"))
+	ran = nil
+	c.parseCmdline("run m")
+	if len(ran) != 2 || ran[0] != "a" || ran[1] != "b" {
+		t.Errorf("FAIL expected ([a b]) != actual (%v)", ran)
+	}
+}
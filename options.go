@@ -0,0 +1,165 @@
+//-----------------------------------------------------------------------------
+/*
+
+CLI Options
+
+Functional options for configuring a CLI when it's created. Eg.
+
+	c := cli.NewCLI(user,
+		cli.WithRoot(menuRoot),
+		cli.WithPrompt("cli> "),
+		cli.WithHistoryFile("history.txt"),
+		cli.WithMultiline(true),
+	)
+
+Each option has an equivalent Set* method for changes after creation.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import "time"
+
+//-----------------------------------------------------------------------------
+
+// Option configures a CLI.
+type Option func(c *CLI)
+
+// WithRoot sets the menu root.
+func WithRoot(root Menu) Option {
+	return func(c *CLI) { c.SetRoot(root) }
+}
+
+// WithStandardCommands adds the standard commands to the root menu.
+// It must follow WithRoot.
+func WithStandardCommands(opts StandardCommands) Option {
+	return func(c *CLI) { c.AddStandardCommands(opts) }
+}
+
+// WithPrompt sets the command prompt.
+func WithPrompt(prompt string) Option {
+	return func(c *CLI) { c.SetPrompt(prompt) }
+}
+
+// WithHistoryFile loads the command history from a file.
+// The history is saved to the file when the CLI stops running.
+func WithHistoryFile(path string) Option {
+	return func(c *CLI) {
+		c.HistoryLoad(path)
+		c.historyPath = path
+	}
+}
+
+// WithMultiline sets multiline editing mode.
+func WithMultiline(mode bool) Option {
+	return func(c *CLI) { c.ln.SetMultiline(mode) }
+}
+
+// WithBracketMatch enables highlighting of matching brackets and quotes.
+func WithBracketMatch(enable bool) Option {
+	return func(c *CLI) { c.ln.SetBracketMatch(enable) }
+}
+
+// WithAutoPair enables the insertion of closing brackets and quotes.
+func WithAutoPair(enable bool) Option {
+	return func(c *CLI) { c.ln.SetAutoPair(enable) }
+}
+
+// WithBanner sets a banner string displayed when the CLI starts.
+func WithBanner(s string) Option {
+	return func(c *CLI) { c.SetBanner(s) }
+}
+
+// WithOutputMode sets the encoding used for table output.
+func WithOutputMode(mode OutputMode) Option {
+	return func(c *CLI) { c.SetOutputMode(mode) }
+}
+
+// WithCompletionPadding sets how completions are padded.
+func WithCompletionPadding(displayOnly bool) Option {
+	return func(c *CLI) { c.SetCompletionPadding(displayOnly) }
+}
+
+// WithCompletionSpace enables a trailing space after a unique completion.
+func WithCompletionSpace(enable bool) Option {
+	return func(c *CLI) { c.SetCompletionSpace(enable) }
+}
+
+// WithHelpChar sets the trailing character that asks for command help.
+func WithHelpChar(r rune) Option {
+	return func(c *CLI) { c.SetHelpChar(r) }
+}
+
+// WithParser sets the command line parser.
+func WithParser(p LineParser) Option {
+	return func(c *CLI) { c.SetParser(p) }
+}
+
+// WithCommentPrefix sets the prefix for comment lines.
+func WithCommentPrefix(prefix string) Option {
+	return func(c *CLI) { c.SetCommentPrefix(prefix) }
+}
+
+// WithColor sets the use of ANSI colors for the session.
+func WithColor(m ColorMode) Option {
+	return func(c *CLI) { c.SetColor(m) }
+}
+
+// WithCharset sets the terminal character set for the session.
+func WithCharset(cs Charset) Option {
+	return func(c *CLI) { c.SetCharset(cs) }
+}
+
+// WithTermType sets the session color capability from a terminal type.
+func WithTermType(term string) Option {
+	return func(c *CLI) { c.SetTermType(term) }
+}
+
+// WithArgHints enables hints for the next expected argument of a leaf command.
+func WithArgHints(enable bool) Option {
+	return func(c *CLI) { c.SetArgHints(enable) }
+}
+
+// WithHistoryIgnorePrefix sets the prefix for command lines not added to history.
+func WithHistoryIgnorePrefix(prefix string) Option {
+	return func(c *CLI) { c.SetHistoryIgnorePrefix(prefix) }
+}
+
+// WithHistoryCompletion enables history entries as a completion source.
+func WithHistoryCompletion(enable bool) Option {
+	return func(c *CLI) { c.SetHistoryCompletion(enable) }
+}
+
+// WithPreRead sets a function called before each command line is read.
+func WithPreRead(fn PreReadFunc) Option {
+	return func(c *CLI) { c.SetPreRead(fn) }
+}
+
+// WithCommandTimeout sets the execution timeout for leaf commands.
+func WithCommandTimeout(d time.Duration) Option {
+	return func(c *CLI) { c.SetCommandTimeout(d) }
+}
+
+// WithRateLimit limits the session to perSecond commands per second.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(c *CLI) { c.SetRateLimit(perSecond, burst) }
+}
+
+// WithIdleTimeout sets an idle timeout and the action taken when it expires.
+func WithIdleTimeout(d time.Duration, action IdleAction) Option {
+	return func(c *CLI) { c.SetIdleTimeout(d, action) }
+}
+
+// WithPrefsFile loads user preferences from a file.
+// The preferences are saved to the file when the CLI stops running.
+func WithPrefsFile(path string) Option {
+	return func(c *CLI) { c.SetPrefsPath(path) }
+}
+
+// WithAuthenticator sets the user authentication function.
+func WithAuthenticator(fn Authenticator) Option {
+	return func(c *CLI) { c.SetAuthenticator(fn) }
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Options(t *testing.T) {
+	dir, err := ioutil.TempDir("", "options")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	hpath := filepath.Join(dir, "history.txt")
+	err = ioutil.WriteFile(hpath, []byte("show\nexit\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCLI(&testUser{},
+		WithRoot(testMenu),
+		WithPrompt("test> "),
+		WithHistoryFile(hpath),
+		WithMultiline(true),
+		WithOutputMode(OutputJSON),
+		WithHistoryCompletion(true),
+	)
+	if len(c.root) != len(testMenu) {
+		t.Errorf("FAIL root not set")
+	}
+	if c.prompt != "test> " {
+		t.Errorf("FAIL expected (test> ) != actual (%s)", c.prompt)
+	}
+	if !c.ln.mlmode {
+		t.Errorf("FAIL multiline not set")
+	}
+	if c.OutputMode() != OutputJSON {
+		t.Errorf("FAIL expected (%v) != actual (%v)", OutputJSON, c.OutputMode())
+	}
+	if !c.historyComplete {
+		t.Errorf("FAIL history completion not set")
+	}
+	if len(c.ln.historyList()) != 2 || c.historyPath != hpath {
+		t.Errorf("FAIL history not loaded")
+	}
+}
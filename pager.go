@@ -0,0 +1,68 @@
+//-----------------------------------------------------------------------------
+/*
+
+Output Paging
+
+Output longer than the terminal height is displayed one screen at a time.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import "strings"
+
+//-----------------------------------------------------------------------------
+
+const morePrompt = "--More-- (space: next page, enter: next line, q: quit)"
+
+// page outputs the lines of a string one page at a time.
+// The more function is called between pages and returns the key pressed.
+func (c *CLI) page(s string, rows int, more func() rune) {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if rows <= 1 || len(lines) < rows {
+		c.Put(s)
+		return
+	}
+	// leave a line for the more prompt
+	n := rows - 1
+	for {
+		if n > len(lines) {
+			n = len(lines)
+		}
+		c.Put(strings.Join(lines[:n], ""))
+		lines = lines[n:]
+		if len(lines) == 0 {
+			return
+		}
+		switch more() {
+		case ' ':
+			n = rows - 1
+		case KeycodeCR, KeycodeLF, 'j':
+			n = 1
+		default:
+			// q, ctrl-C, etc.
+			return
+		}
+	}
+}
+
+// Page outputs a string. If it's longer than the terminal height it is
+// displayed one screen at a time. Output is not paged when there is no
+// terminal to read keys from (Eg. piped input).
+func (c *CLI) Page(s string) {
+	rows := c.ln.Rows()
+	if rows > 0 && c.length != 0 {
+		// use the page length preference
+		rows = c.length
+	}
+	if c.ln.term == nil && !isTerminal(stdinFd) {
+		rows = 0
+	}
+	c.page(s, rows, func() rune { return c.ln.readKey(c.Context(), morePrompt) })
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Page(t *testing.T) {
+	text := "1\n2\n3\n4\n5\n6\n7\n"
+	tests := []struct {
+		rows  int
+		keys  string
+		out   string
+		pages int
+	}{
+		{0, "", text, 0},
+		{8, "", text, 0},
+		{4, "  ", text, 2},
+		{4, "\r\r\r\r", text, 4},
+		{4, "q", "1\n2\n3\n", 1},
+		{4, " q", "1\n2\n3\n4\n5\n6\n", 2},
+	}
+	for i, v := range tests {
+		user := &testUser{}
+		c := NewCLI(user)
+		keys := []rune(v.keys)
+		pages := 0
+		c.page(text, v.rows, func() rune {
+			r := keys[pages]
+			pages++
+			return r
+		})
+		out := user.out.String()
+		if out != v.out || pages != v.pages {
+			t.Errorf("%d: FAIL expected (%q, %d) != actual (%q, %d)", i, v.out, v.pages, out, pages)
+		}
+	}
+}
+
+func Test_PageInput(t *testing.T) {
+	text := strings.Repeat("x\n", 20)
+	// keys are read from the terminal
+	user := &testUser{}
+	c := NewCLI(user)
+	c.ln.SetTerminal(&testTerm{Reader: strings.NewReader("q")})
+	c.Page(text)
+	if out := user.out.String(); out != strings.Repeat("x\n", 11) {
+		t.Errorf("FAIL expected (%q) != actual (%q)", strings.Repeat("x\n", 11), out)
+	}
+	if isTerminal(stdinFd) {
+		return
+	}
+	// no terminal, the output isn't paged
+	user = &testUser{}
+	c = NewCLI(user)
+	c.ln.SetRows(12)
+	c.Page(text)
+	if out := user.out.String(); out != text {
+		t.Errorf("FAIL expected (%q) != actual (%q)", text, out)
+	}
+}
@@ -0,0 +1,187 @@
+//-----------------------------------------------------------------------------
+/*
+
+Bracket Matching and Auto-Pairing
+
+Optional editing aids for command lines with expressions or JSON arguments.
+The bracket or quote at (or before) the cursor has its match highlighted, and
+typing an opening bracket or quote inserts the closing character.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import "unicode"
+
+//-----------------------------------------------------------------------------
+
+// closing characters for the opening brackets and quotes
+var pairClose = map[rune]rune{
+	'(':  ')',
+	'[':  ']',
+	'{':  '}',
+	'"':  '"',
+	'\'': '\'',
+}
+
+// opening characters for the closing brackets
+var pairOpen = map[rune]rune{
+	')': '(',
+	']': '[',
+	'}': '{',
+}
+
+// SetBracketMatch enables highlighting of the bracket or quote matching the
+// one at (or before) the cursor.
+func (l *Linenoise) SetBracketMatch(enable bool) {
+	l.bracketMatch = enable
+}
+
+// SetAutoPair enables the insertion of closing brackets and quotes when the
+// opening character is typed.
+func (l *Linenoise) SetAutoPair(enable bool) {
+	l.autoPair = enable
+}
+
+//-----------------------------------------------------------------------------
+
+// Is the rune at buf[i] a quote escaped by a backslash?
+func escapedAt(buf []rune, i int) bool {
+	return i > 0 && buf[i-1] == '\\'
+}
+
+// Return the position of the character matching the bracket or quote at buf[i], or -1.
+func matchAt(buf []rune, i int) int {
+	r := buf[i]
+	if r == '"' || r == '\'' {
+		if escapedAt(buf, i) {
+			return -1
+		}
+		// an even number of quotes before an opening quote
+		n := 0
+		for k := 0; k < i; k++ {
+			if buf[k] == r && !escapedAt(buf, k) {
+				n++
+			}
+		}
+		if n&1 == 0 {
+			for k := i + 1; k < len(buf); k++ {
+				if buf[k] == r && !escapedAt(buf, k) {
+					return k
+				}
+			}
+			return -1
+		}
+		for k := i - 1; k >= 0; k-- {
+			if buf[k] == r && !escapedAt(buf, k) {
+				return k
+			}
+		}
+		return -1
+	}
+	if c, ok := pairClose[r]; ok {
+		// search forward for the closing bracket
+		depth := 0
+		for k := i + 1; k < len(buf); k++ {
+			if buf[k] == r {
+				depth++
+			} else if buf[k] == c {
+				if depth == 0 {
+					return k
+				}
+				depth--
+			}
+		}
+		return -1
+	}
+	if o, ok := pairOpen[r]; ok {
+		// search backward for the opening bracket
+		depth := 0
+		for k := i - 1; k >= 0; k-- {
+			if buf[k] == r {
+				depth++
+			} else if buf[k] == o {
+				if depth == 0 {
+					return k
+				}
+				depth--
+			}
+		}
+	}
+	return -1
+}
+
+// Return the buffer position of the character to highlight as the match
+// for the bracket or quote at (or before) the cursor, or -1.
+func (ls *linestate) bracketMatch() int {
+	if !ls.ts.bracketMatch || ls.ts.masked {
+		return -1
+	}
+	if ls.pos < len(ls.buf) {
+		if m := matchAt(ls.buf, ls.pos); m >= 0 {
+			return m
+		}
+	}
+	if ls.pos > 0 {
+		return matchAt(ls.buf, ls.pos-1)
+	}
+	return -1
+}
+
+// Insert a typed character, pairing brackets and quotes if enabled.
+func (ls *linestate) editSelfInsert(r rune) {
+	l := ls.ts
+	if !l.autoPair || l.masked || l.overwrite {
+		ls.editInsert(r)
+		return
+	}
+	var next rune
+	if ls.pos < len(ls.buf) {
+		next = ls.buf[ls.pos]
+		// type over a closing character
+		if m := matchAt(ls.buf, ls.pos); next == r && m >= 0 && m < ls.pos {
+			ls.pos++
+			ls.refreshLine()
+			return
+		}
+	}
+	c, ok := pairClose[r]
+	if !ok || !(next == 0 || unicode.IsSpace(next) || pairOpen[next] != 0) {
+		ls.editInsert(r)
+		return
+	}
+	if (r == '"' || r == '\'') && ls.pos > 0 {
+		// don't pair an apostrophe or a quote within a word
+		prev := ls.buf[ls.pos-1]
+		if unicode.IsLetter(prev) || unicode.IsDigit(prev) || prev == '\\' {
+			ls.editInsert(r)
+			return
+		}
+	}
+	ls.editInsert(r)
+	ls.buf = append(ls.buf, 0)
+	copy(ls.buf[ls.pos+1:], ls.buf[ls.pos:])
+	ls.buf[ls.pos] = c
+	ls.refreshLine()
+}
+
+// Delete an empty bracket or quote pair at the cursor with backspace.
+// Returns false if there is no pair to delete.
+func (ls *linestate) deletePair() bool {
+	l := ls.ts
+	if !l.autoPair || l.masked || ls.pos == 0 || ls.pos == len(ls.buf) {
+		return false
+	}
+	c, ok := pairClose[ls.buf[ls.pos-1]]
+	if !ok || ls.buf[ls.pos] != c {
+		return false
+	}
+	ls.saveUndo()
+	ls.buf = append(ls.buf[:ls.pos-1], ls.buf[ls.pos+1:]...)
+	ls.pos--
+	ls.refreshLine()
+	return true
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func Test_MatchAt(t *testing.T) {
+	tests := []struct {
+		s     string
+		i     int
+		match int
+	}{
+		{"(a(b)c)", 0, 6},
+		{"(a(b)c)", 6, 0},
+		{"(a(b)c)", 2, 4},
+		{"[1, {\"k\": 2}]", 4, 11},
+		{"\"a\" \"b\"", 4, 6},
+		{"\"a\" \"b\"", 2, 0},
+		{"\"a\\\"b\"", 0, 5},
+		{"(a", 0, -1},
+		{"a)", 1, -1},
+		{"abc", 1, -1},
+	}
+	for i, v := range tests {
+		if m := matchAt([]rune(v.s), v.i); m != v.match {
+			t.Errorf("%d: FAIL expected (%d) != actual (%d)", i, v.match, m)
+		}
+	}
+}
+
+func Test_AutoPair(t *testing.T) {
+	tests := []struct {
+		in   string
+		line string
+	}{
+		{"(a\r", "(a)"},
+		{"(a)\r", "(a)"},
+		{"f(\"x\r", "f(\"x\")"},
+		{"don't\r", "don't"},
+		{"(\x7f\r", ""},
+		{"{\"k\": [1\r", "{\"k\": [1]}"},
+		{"a)\r", "a)"},
+	}
+	for i, v := range tests {
+		l := NewLineNoise()
+		l.SetAutoPair(true)
+		line, err := l.Edit(NewByteSource([]byte(v.in)), ioutil.Discard, "> ", "")
+		if line != v.line || err != nil {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q, %v)", i, v.line, line, err)
+		}
+	}
+}
+
+func Test_BracketMatch(t *testing.T) {
+	l := NewLineNoise()
+	l.SetBracketMatch(true)
+	l.color = ColorOn
+	var out bytes.Buffer
+	line, err := l.Edit(NewByteSource([]byte("\x01\r")), &out, "> ", "(ab)")
+	if line != "(ab)" || err != nil {
+		t.Errorf("FAIL expected (%q) != actual (%q, %v)", "(ab)", line, err)
+	}
+	if !strings.Contains(out.String(), "(ab\x1b[1;4m)\x1b[0m") {
+		t.Errorf("FAIL bracket not highlighted %q", out.String())
+	}
+}
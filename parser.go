@@ -0,0 +1,49 @@
+//-----------------------------------------------------------------------------
+/*
+
+Command Line Parser
+
+The parser splits a command line into tokens and matches the tokens with
+menu items. Applications can replace it to support other grammars,
+Eg. key=value arguments or case insensitive commands. History, completion
+and the line editor work the same with any parser.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import "strings"
+
+//-----------------------------------------------------------------------------
+
+// LineParser splits command lines into tokens and matches tokens with menu items.
+type LineParser interface {
+	Split(line string) []string               // split a command line into tokens
+	Match(menu Menu, token string) []MenuItem // menu items matching a token, an exact match is the only match
+}
+
+// defaultParser splits on whitespace and matches menu names by prefix.
+type defaultParser struct{}
+
+func (defaultParser) Split(line string) []string {
+	return strings.Fields(line)
+}
+
+func (defaultParser) Match(menu Menu, token string) []MenuItem {
+	return menuMatches(menu, token)
+}
+
+// DefaultParser is the parser for a new CLI. Tokens are separated by
+// whitespace and a token matches the menu items it is a prefix of.
+var DefaultParser LineParser = defaultParser{}
+
+// SetParser sets the command line parser, nil for the default parser.
+func (c *CLI) SetParser(p LineParser) {
+	if p == nil {
+		p = DefaultParser
+	}
+	c.parser = p
+}
+
+//-----------------------------------------------------------------------------
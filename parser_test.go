@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// kvParser splits key=value arguments and matches commands ignoring case.
+type kvParser struct{}
+
+func (kvParser) Split(line string) []string {
+	return strings.FieldsFunc(line, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '='
+	})
+}
+
+func (kvParser) Match(menu Menu, token string) []MenuItem {
+	return menuMatches(menu, strings.ToLower(token))
+}
+
+func Test_Parser(t *testing.T) {
+	var got []string
+	set := Leaf{"set a value", func(c *CLI, args []string) { got = args }}
+	tests := []struct {
+		line string
+		args string
+		err  bool
+	}{
+		{"SET speed=10", "speed,10", false},
+		{"net Se duplex = full", "duplex,full", false},
+		{"Net", "", true},
+		{"get x=1", "", true},
+	}
+	for i, v := range tests {
+		c := NewCLI(&testUser{}, WithParser(kvParser{}))
+		c.SetRoot(Menu{
+			{"set", set},
+			{"net", Menu{{"set", set}}},
+		})
+		got = nil
+		c.parseCmdline(v.line)
+		args := strings.Join(got, ",")
+		if args != v.args || (c.result != nil) != v.err {
+			t.Errorf("%d: FAIL expected (%q, %v) != actual (%q, %v)", i, v.args, v.err, args, c.result)
+		}
+	}
+	// the default parser is restored
+	c := NewCLI(&testUser{}, WithParser(kvParser{}))
+	c.SetParser(nil)
+	c.SetRoot(Menu{{"set", set}})
+	got = nil
+	c.parseCmdline("set a=1")
+	if strings.Join(got, ",") != "a=1" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "a=1", got)
+	}
+	// resolution uses the parser
+	c.SetParser(kvParser{})
+	path, args, err := c.Resolve("SET a=1")
+	if strings.Join(path, " ") != "set" || strings.Join(args, ",") != "a,1" || err != nil {
+		t.Errorf("FAIL resolve (%q, %q, %v)", path, args, err)
+	}
+}
@@ -0,0 +1,146 @@
+//-----------------------------------------------------------------------------
+/*
+
+Text Plotting
+
+Horizontal bar charts and sparklines for "show stats" style commands.
+Unicode block glyphs are used when the terminal supports them, with an ASCII
+fallback when it doesn't.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+//-----------------------------------------------------------------------------
+
+// partial blocks in 1/8ths for bar charts
+var barBlocks = []rune(" ▏▎▍▌▋▊▉█")
+
+// sparkline levels
+var sparkUnicode = []rune("▁▂▃▄▅▆▇█")
+var sparkASCII = []rune("_.-:=+*#")
+
+// Bar is a labelled bar chart value.
+type Bar struct {
+	Label string
+	Value float64
+}
+
+// Return a bar of a given length in 1/8ths of a column.
+func barString(eighths int, unicode bool) string {
+	if !unicode {
+		return strings.Repeat("#", eighths/8)
+	}
+	s := strings.Repeat(string(barBlocks[8]), eighths/8)
+	if eighths%8 != 0 {
+		s += string(barBlocks[eighths%8])
+	}
+	return s
+}
+
+// Return a bar chart with lines that fit within cols.
+func barChart(bars []Bar, cols int, unicode bool) string {
+	if len(bars) == 0 {
+		return ""
+	}
+	// label and value widths, maximum value
+	labels := make([]string, len(bars))
+	values := make([]string, len(bars))
+	lWidth := 0
+	vWidth := 0
+	max := 0.0
+	for i, b := range bars {
+		labels[i] = b.Label
+		values[i] = fmt.Sprintf("%g", b.Value)
+		lWidth = maxInt(lWidth, runewidth.StringWidth(labels[i]))
+		vWidth = maxInt(vWidth, len(values[i]))
+		if isFinite(b.Value) {
+			max = math.Max(max, b.Value)
+		}
+	}
+	// columns available for the bar
+	avail := cols - lWidth - vWidth - 3
+	if avail < 1 {
+		avail = 1
+	}
+	lines := make([]string, len(bars))
+	for i, b := range bars {
+		eighths := 0
+		if max > 0 && b.Value > 0 {
+			// +Inf is a full bar
+			eighths = int(math.Min(math.Round(8*float64(avail)*b.Value/max), float64(8*avail)))
+		}
+		bar := barString(eighths, unicode)
+		pad := avail - runewidth.StringWidth(bar)
+		lines[i] = fmt.Sprintf("%s %s%s %*s", PadWidth(labels[i], lWidth), bar, repeat(' ', pad), vWidth, values[i])
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// BarChart returns a horizontal bar chart scaled to the terminal width.
+// Negative values are shown as empty bars.
+func BarChart(bars []Bar) string {
+	return barChart(bars, termColumns(), termUnicode())
+}
+
+// Return a sparkline using unicode or ASCII levels.
+func sparkline(values []float64, unicode bool) string {
+	levels := sparkASCII
+	if unicode {
+		levels = sparkUnicode
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	// the range of the finite values
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if isFinite(v) {
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+		}
+	}
+	top := float64(len(levels) - 1)
+	s := make([]rune, len(values))
+	for i, v := range values {
+		k := 0
+		if max > min {
+			// NaN is the lowest level, ±Inf the lowest or highest
+			f := math.Round(top * (v - min) / (max - min))
+			if f > 0 {
+				k = int(math.Min(f, top))
+			}
+		}
+		s[i] = levels[k]
+	}
+	return string(s)
+}
+
+// Sparkline returns a single line sparkline of the values.
+func Sparkline(values []float64) string {
+	return sparkline(values, termUnicode())
+}
+
+// Return true if a value is neither infinite nor NaN.
+func isFinite(x float64) bool {
+	return !math.IsInf(x, 0) && !math.IsNaN(x)
+}
+
+// Return the maximum of two integers.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+//-----------------------------------------------------------------------------
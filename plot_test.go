@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_BarChart(t *testing.T) {
+	bars := []Bar{{"a", 10}, {"bb", 5}, {"c", -1}}
+	s := barChart(bars, 20, false)
+	expected := "" +
+		"a  ############# 10\n" +
+		"bb ######         5\n" +
+		"c                -1\n"
+	if s != expected {
+		t.Errorf("FAIL expected\n%q\nactual\n%q", expected, s)
+	}
+	// non-finite values
+	tests := []struct {
+		bars []Bar
+		s    string
+	}{
+		{[]Bar{{"a", 10}, {"b", math.Inf(1)}, {"c", math.NaN()}},
+			"a ############   10\nb ############ +Inf\nc               NaN\n"},
+		{[]Bar{{"a", math.Inf(-1)}, {"b", math.Inf(1)}},
+			"a              -Inf\nb              +Inf\n"},
+	}
+	for i, v := range tests {
+		s := barChart(v.bars, 20, false)
+		if s != v.s {
+			t.Errorf("%d: FAIL expected\n%q\nactual\n%q", i, v.s, s)
+		}
+	}
+	s = barChart([]Bar{{"x", 4}, {"y", 1}}, 8, true)
+	expected = "" +
+		"x ███ 4\n" +
+		"y ▊   1\n"
+	if s != expected {
+		t.Errorf("FAIL expected\n%q\nactual\n%q", expected, s)
+	}
+}
+
+func Test_Sparkline(t *testing.T) {
+	values := []float64{0, 1, 2, 3, 4, 5, 6, 7}
+	tests := []struct {
+		unicode bool
+		s       string
+	}{
+		{true, "▁▂▃▄▅▆▇█"},
+		{false, "_.-:=+*#"},
+	}
+	for i, v := range tests {
+		s := sparkline(values, v.unicode)
+		if s != v.s {
+			t.Errorf("%d: FAIL expected (%s) != actual (%s)", i, v.s, s)
+		}
+	}
+	// non-finite values
+	tests = []struct {
+		unicode bool
+		s       string
+	}{
+		{false, "_#__#"},
+		{true, "▁█▁▁█"},
+	}
+	for i, v := range tests {
+		s := sparkline([]float64{0, math.Inf(1), math.NaN(), math.Inf(-1), 7}, v.unicode)
+		if s != v.s {
+			t.Errorf("%d: FAIL expected (%s) != actual (%s)", i, v.s, s)
+		}
+	}
+	if sparkline([]float64{math.NaN(), math.Inf(1)}, false) != "__" {
+		t.Errorf("FAIL non-finite sparkline")
+	}
+	if sparkline([]float64{3, 3}, true) != "▁▁" {
+		t.Errorf("FAIL flat sparkline")
+	}
+}
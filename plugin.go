@@ -0,0 +1,71 @@
+//-----------------------------------------------------------------------------
+/*
+
+Plugins
+
+Separately built modules can contribute commands to the root menu.
+A plugin is initialised when it is registered and closed when the CLI stops.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import "fmt"
+
+//-----------------------------------------------------------------------------
+
+// Plugin is a module that contributes commands to the CLI.
+type Plugin interface {
+	Name() string      // plugin name
+	Commands() Menu    // menu items added to the root menu
+	Init(c *CLI) error // called when the plugin is registered
+	Close()            // called when the CLI stops
+}
+
+// RegisterPlugin initialises a plugin and adds its commands to the root menu.
+// It is an error if the plugin name or a command name is already in use.
+// Plugins should be registered after the root menu has been set.
+func (c *CLI) RegisterPlugin(p Plugin) error {
+	for _, x := range c.plugins {
+		if x.Name() == p.Name() {
+			return fmt.Errorf("plugin \"%s\" is already registered", p.Name())
+		}
+	}
+	cmds := p.Commands()
+	names := make(map[string]bool)
+	for _, item := range c.root {
+		names[item[0].(string)] = true
+	}
+	for _, item := range cmds {
+		name := item[0].(string)
+		if names[name] {
+			return fmt.Errorf("plugin \"%s\": command \"%s\" is already in use", p.Name(), name)
+		}
+		names[name] = true
+	}
+	err := p.Init(c)
+	if err != nil {
+		return fmt.Errorf("plugin \"%s\": %s", p.Name(), err)
+	}
+	root := make(Menu, 0, len(c.root)+len(cmds))
+	c.root = append(append(root, c.root...), cmds...)
+	c.plugins = append(c.plugins, p)
+	return nil
+}
+
+// Plugins returns the registered plugins.
+func (c *CLI) Plugins() []Plugin {
+	return c.plugins
+}
+
+// ClosePlugins closes the registered plugins in the reverse order of registration.
+// It's called when the CLI stops running.
+func (c *CLI) ClosePlugins() {
+	for i := len(c.plugins) - 1; i >= 0; i-- {
+		c.plugins[i].Close()
+	}
+	c.plugins = nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"errors"
+	"testing"
+)
+
+type testPlugin struct {
+	name   string
+	cmds   Menu
+	err    error
+	closed *[]string
+}
+
+func (p *testPlugin) Name() string      { return p.name }
+func (p *testPlugin) Commands() Menu    { return p.cmds }
+func (p *testPlugin) Init(c *CLI) error { return p.err }
+func (p *testPlugin) Close()            { *p.closed = append(*p.closed, p.name) }
+
+func Test_Plugin(t *testing.T) {
+	var closed []string
+	c := NewCLI(&testUser{})
+	c.SetRoot(testMenu)
+	a := &testPlugin{"a", Menu{{"alpha", testLeaf}}, nil, &closed}
+	b := &testPlugin{"b", Menu{{"beta", testLeaf}}, nil, &closed}
+	if c.RegisterPlugin(a) != nil || c.RegisterPlugin(b) != nil {
+		t.Fatalf("FAIL could not register plugins")
+	}
+	if _, err := c.Find([]string{"beta"}); err != nil {
+		t.Errorf("FAIL plugin command not added")
+	}
+	tests := []*testPlugin{
+		{"a", Menu{{"gamma", testLeaf}}, nil, &closed},
+		{"c", Menu{{"show", testLeaf}}, nil, &closed},
+		{"d", Menu{{"delta", testLeaf}}, errors.New("init failed"), &closed},
+	}
+	for i, p := range tests {
+		if c.RegisterPlugin(p) == nil {
+			t.Errorf("%d: FAIL plugin %q registered", i, p.name)
+		}
+	}
+	if len(c.Plugins()) != 2 || len(c.root) != len(testMenu)+2 {
+		t.Errorf("FAIL bad registration state")
+	}
+	c.ClosePlugins()
+	if len(closed) != 2 || closed[0] != "b" || closed[1] != "a" {
+		t.Errorf("FAIL expected ([b a]) != actual (%v)", closed)
+	}
+}
@@ -0,0 +1,308 @@
+//-----------------------------------------------------------------------------
+/*
+
+User Preferences
+
+Per-user settings that can be changed at runtime (terminal length and width,
+output mode, color and command aliases). If a preferences file has been set they are
+loaded on startup and saved when the CLI stops running.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+
+// preferences file format
+type prefsFile struct {
+	Length  int               `json:"length"`
+	Width   int               `json:"width"`
+	Output  string            `json:"output"`
+	Color   string            `json:"color"`
+	Aliases map[string]string `json:"aliases,omitempty"`
+}
+
+// Return the index of a name in a list of names.
+func nameIndex(names []string, name string) (int, error) {
+	for i := range names {
+		if names[i] == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid value \"%s\", must be one of %s", name, strings.Join(names, ", "))
+}
+
+// SetPrefsPath sets the path of the user preferences file and loads it.
+// The preferences are saved to the file when the CLI stops running.
+func (c *CLI) SetPrefsPath(path string) error {
+	c.prefsPath = path
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// no preferences yet
+			return nil
+		}
+		return err
+	}
+	var p prefsFile
+	err = json.Unmarshal(buf, &p)
+	if err != nil {
+		return err
+	}
+	c.length = p.Length
+	c.ln.SetColumns(p.Width)
+	if mode, err := nameIndex(outputModeNames, p.Output); err == nil {
+		c.outputMode = OutputMode(mode)
+	}
+	if mode, err := nameIndex(colorModeNames, p.Color); err == nil {
+		c.SetColor(ColorMode(mode))
+	}
+	c.aliases = p.Aliases
+	return nil
+}
+
+// PrefsSave saves the user preferences to the preferences file.
+func (c *CLI) PrefsSave() error {
+	if c.prefsPath == "" {
+		return nil
+	}
+	width, _ := c.ln.size()
+	p := prefsFile{
+		Length:  c.length,
+		Width:   width,
+		Output:  c.outputMode.String(),
+		Color:   c.color.String(),
+		Aliases: c.aliases,
+	}
+	buf, err := json.MarshalIndent(&p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.prefsPath, append(buf, '\n'), 0600)
+}
+
+//-----------------------------------------------------------------------------
+// command aliases
+
+// SetAlias sets a command alias. An empty command removes the alias.
+func (c *CLI) SetAlias(name, cmd string) {
+	if cmd == "" {
+		delete(c.aliases, name)
+		return
+	}
+	if c.aliases == nil {
+		c.aliases = make(map[string]string)
+	}
+	c.aliases[name] = cmd
+}
+
+// Replace a leading alias in the command line with its command.
+func (c *CLI) expandAlias(line string) string {
+	s := strings.TrimLeft(line, " ")
+	name := s
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		name = s[:i]
+	}
+	if cmd, ok := c.aliases[name]; ok {
+		return cmd + s[len(name):]
+	}
+	return line
+}
+
+//-----------------------------------------------------------------------------
+// preferences menu
+
+// display the current preferences
+func (c *CLI) displayPrefs() {
+	length := "auto"
+	if c.length < 0 {
+		length = "0"
+	} else if c.length > 0 {
+		length = strconv.Itoa(c.length)
+	}
+	width := "auto"
+	if cols, _ := c.ln.size(); cols > 0 {
+		width = strconv.Itoa(cols)
+	}
+	s := [][]string{
+		{"length", length},
+		{"width", width},
+		{"output", c.outputMode.String()},
+		{"color", c.color.String()},
+	}
+	names := make([]string, 0, len(c.aliases))
+	for k := range c.aliases {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		s = append(s, []string{"alias " + k, c.aliases[k]})
+	}
+	c.Put(TableString(s, []int{16, 0}, 1) + "\n")
+}
+
+var cmdPrefsShow = Leaf{
+	Descr: "display the preferences",
+	F: func(c *CLI, args []string) {
+		c.displayPrefs()
+	},
+}
+
+var cmdPrefsLength = Leaf{
+	Descr: "set the number of lines per page",
+	F: func(c *CLI, args []string) {
+		err := CheckArgc(args, []int{1})
+		if err == nil {
+			if args[0] == "auto" {
+				c.length = 0
+			} else {
+				var n int
+				n, err = IntArg(args[0], [2]int{0, 512}, 10)
+				if err == nil {
+					if n == 0 {
+						// no paging
+						n = -1
+					}
+					c.length = n
+				}
+			}
+		}
+		if err != nil {
+			c.Put(fmt.Sprintf("%s\n", err))
+		}
+	},
+}
+
+var lengthHelp = []Help{
+	{"<lines>", "lines per page, 0 disables paging"},
+	{"auto", "use the terminal height (default)"},
+}
+
+var cmdPrefsWidth = Leaf{
+	Descr: "set the terminal width",
+	F: func(c *CLI, args []string) {
+		err := CheckArgc(args, []int{1})
+		if err == nil {
+			if args[0] == "auto" {
+				c.ln.SetColumns(0)
+			} else {
+				var n int
+				n, err = IntArg(args[0], [2]int{20, 512}, 10)
+				if err == nil {
+					c.ln.SetColumns(n)
+				}
+			}
+		}
+		if err != nil {
+			c.Put(fmt.Sprintf("%s\n", err))
+		}
+	},
+}
+
+var widthHelp = []Help{
+	{"<cols>", "columns for line editing and output layout"},
+	{"auto", "detect the terminal width (default)"},
+}
+
+var cmdPrefsOutput = Leaf{
+	Descr: "set the table output mode",
+	F: func(c *CLI, args []string) {
+		err := CheckArgc(args, []int{1})
+		if err == nil {
+			var mode int
+			mode, err = nameIndex(outputModeNames, args[0])
+			if err == nil {
+				c.outputMode = OutputMode(mode)
+			}
+		}
+		if err != nil {
+			c.Put(fmt.Sprintf("%s\n", err))
+		}
+	},
+}
+
+var outputHelp = []Help{
+	{"text", "aligned columns (default)"},
+	{"csv", "comma separated values"},
+	{"tsv", "tab separated values"},
+	{"json", "a JSON object per row"},
+}
+
+var cmdPrefsColor = Leaf{
+	Descr: "set the use of color",
+	F: func(c *CLI, args []string) {
+		err := CheckArgc(args, []int{1})
+		if err == nil {
+			var mode int
+			mode, err = nameIndex(colorModeNames, args[0])
+			if err == nil {
+				c.SetColor(ColorMode(mode))
+			}
+		}
+		if err != nil {
+			c.Put(fmt.Sprintf("%s\n", err))
+		}
+	},
+}
+
+var colorHelp = []Help{
+	{"auto", "color if the terminal supports it (default)"},
+	{"on", "always use color"},
+	{"off", "never use color"},
+}
+
+var cmdPrefsAlias = Leaf{
+	Descr: "set a command alias",
+	F: func(c *CLI, args []string) {
+		if len(args) == 0 {
+			c.displayPrefs()
+			return
+		}
+		if args[0] == "delete" {
+			if len(args) != 2 {
+				c.Put("bad number of arguments\n")
+				return
+			}
+			if _, ok := c.aliases[args[1]]; !ok {
+				c.Put("no such alias\n")
+				return
+			}
+			c.SetAlias(args[1], "")
+			return
+		}
+		if len(args) < 2 {
+			c.Put("bad number of arguments\n")
+			return
+		}
+		c.SetAlias(args[0], strings.Join(args[1:], " "))
+	},
+}
+
+var aliasHelp = []Help{
+	{"<cr>", "display the aliases"},
+	{"<name> <cmd>", "set <name> as an alias for <cmd>"},
+	{"delete <name>", "delete an alias"},
+}
+
+// PrefsMenu is a menu for changing the user preferences.
+var PrefsMenu = Menu{
+	{"alias", cmdPrefsAlias, aliasHelp},
+	{"color", cmdPrefsColor, colorHelp},
+	{"length", cmdPrefsLength, lengthHelp},
+	{"output", cmdPrefsOutput, outputHelp},
+	{"show", cmdPrefsShow},
+	{"width", cmdPrefsWidth, widthHelp},
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Prefs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prefs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "prefs.json")
+
+	c := NewCLI(&testUser{})
+	c.SetRoot(PrefsMenu)
+	if c.SetPrefsPath(path) != nil {
+		t.Fatalf("FAIL missing preferences file is an error")
+	}
+	for _, s := range []string{"length 20", "width 100", "output json", "color off", "alias o output"} {
+		c.parseCmdline(s)
+	}
+	if c.PrefsSave() != nil {
+		t.Fatalf("FAIL could not save preferences")
+	}
+
+	c = NewCLI(&testUser{})
+	c.SetRoot(PrefsMenu)
+	if err := c.SetPrefsPath(path); err != nil {
+		t.Fatal(err)
+	}
+	if c.length != 20 || c.ln.Columns() != 100 || c.OutputMode() != OutputJSON || c.color != ColorOff {
+		t.Errorf("FAIL preferences not loaded (%d, %d, %s, %s)", c.length, c.ln.Columns(), c.OutputMode(), c.color)
+	}
+	// the color and width preferences are per-session
+	other := NewCLI(&testUser{})
+	if colorMode != ColorAuto || other.color != ColorAuto {
+		t.Errorf("FAIL color preference is not per-session")
+	}
+	if cols, _ := other.ln.size(); cols != 0 {
+		t.Errorf("FAIL width preference is not per-session (%d)", cols)
+	}
+	c.parseCmdline("o csv")
+	if c.OutputMode() != OutputCSV {
+		t.Errorf("FAIL alias not expanded")
+	}
+}
@@ -0,0 +1,102 @@
+//-----------------------------------------------------------------------------
+/*
+
+Prompt Segments
+
+Status segments are composed in front of the prompt. Eg. "[alarm:2] cli> "
+The segments are refreshed before each command line is read, or on demand.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//-----------------------------------------------------------------------------
+
+// promptSegment is a status segment of the prompt.
+type promptSegment struct {
+	name  string        // segment name
+	fn    func() string // segment value provider, an empty value hides the segment
+	color int           // ANSI color, < 0 for no styling
+}
+
+// promptSegments stores the prompt segments.
+type promptSegments struct {
+	sync.Mutex
+	segs []*promptSegment
+}
+
+// AddPromptSegment adds a status segment displayed in front of the prompt
+// as "[name:value]". The segment is hidden if the provider returns "".
+// A color < 0 disables styling. Adding a segment with an existing name
+// replaces it.
+func (c *CLI) AddPromptSegment(name string, fn func() string, color int) {
+	ps := c.segments
+	ps.Lock()
+	defer ps.Unlock()
+	seg := &promptSegment{name, fn, color}
+	for i := range ps.segs {
+		if ps.segs[i].name == name {
+			ps.segs[i] = seg
+			return
+		}
+	}
+	ps.segs = append(ps.segs, seg)
+}
+
+// RemovePromptSegment removes a prompt segment.
+func (c *CLI) RemovePromptSegment(name string) {
+	ps := c.segments
+	ps.Lock()
+	defer ps.Unlock()
+	for i := range ps.segs {
+		if ps.segs[i].name == name {
+			ps.segs = append(ps.segs[:i], ps.segs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Return the prompt with the status segments.
+func (c *CLI) fullPrompt() string {
+	ps := c.segments
+	ps.Lock()
+	defer ps.Unlock()
+	s := make([]string, 0, len(ps.segs)+1)
+	for _, seg := range ps.segs {
+		val := seg.fn()
+		if val == "" {
+			continue
+		}
+		s = append(s, c.colorString(fmt.Sprintf("[%s:%s]", seg.name, val), seg.color, false))
+	}
+	s = append(s, c.prompt)
+	return strings.Join(s, " ")
+}
+
+// Return the edit mode segment, "ovr" in overwrite mode.
+func (c *CLI) editMode() string {
+	if c.ln.Overwrite() {
+		return "ovr"
+	}
+	return ""
+}
+
+// Return the prompt for the line being edited when the overwrite mode is toggled.
+func (c *CLI) overwriteCallback(overwrite bool) string {
+	return c.fullPrompt()
+}
+
+// InvalidatePrompt refreshes the prompt segments of the line being edited.
+// It's safe to call from other goroutines.
+func (c *CLI) InvalidatePrompt() {
+	c.ln.SetPromptAsync(c.fullPrompt())
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func Test_PromptSegments(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.SetPrompt("cli> ")
+	alarms := ""
+	c.AddPromptSegment("alarm", func() string { return alarms }, -1)
+	c.AddPromptSegment("link", func() string { return "up" }, -1)
+	tests := []struct {
+		alarms string
+		prompt string
+	}{
+		{"", "[link:up] cli> "},
+		{"2", "[alarm:2] [link:up] cli> "},
+	}
+	for i, v := range tests {
+		alarms = v.alarms
+		if p := c.fullPrompt(); p != v.prompt {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.prompt, p)
+		}
+	}
+	c.RemovePromptSegment("link")
+	if p := c.fullPrompt(); p != "[alarm:2] cli> " {
+		t.Errorf("FAIL segment not removed %q", p)
+	}
+	if w := promptWidth("\x1b[0;31;49m[alarm:2]\x1b[0m cli> "); w != 15 {
+		t.Errorf("FAIL expected (15) != actual (%d)", w)
+	}
+}
+
+func Test_OverwritePrompt(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.SetPrompt("cli> ")
+	c.AddPromptSegment("link", func() string { return "up" }, -1)
+	if p := c.fullPrompt(); p != "[link:up] cli> " {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "[link:up] cli> ", p)
+	}
+	// the insert key toggles the mode and the prompt
+	line, err := c.ln.Edit(NewByteSource([]byte("\x1b[2~ab\r")), ioutil.Discard, c.fullPrompt(), "")
+	if line != "ab" || err != nil || !c.ln.Overwrite() {
+		t.Errorf("FAIL expected (%q, true) != actual (%q, %v, %v)", "ab", line, err, c.ln.Overwrite())
+	}
+	if p := c.fullPrompt(); p != "[mode:ovr] [link:up] cli> " {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "[mode:ovr] [link:up] cli> ", p)
+	}
+}
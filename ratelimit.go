@@ -0,0 +1,80 @@
+//-----------------------------------------------------------------------------
+/*
+
+Rate Limiting
+
+Limit the rate at which a session runs commands to protect device backends
+from runaway scripted input. The limit is a token bucket, commands can be
+run in a burst and then at the sustained rate.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+
+// ErrRateLimit is the result of a command rejected by the rate limit.
+var ErrRateLimit = errors.New("rate limit exceeded")
+
+// rateLimiter is a token bucket.
+type rateLimiter struct {
+	sync.Mutex
+	rate   float64   // tokens added per second
+	burst  float64   // maximum tokens
+	tokens float64   // available tokens
+	last   time.Time // time tokens were last added
+}
+
+// Return true if a command can be run at this time.
+func (rl *rateLimiter) allow(now time.Time) bool {
+	rl.Lock()
+	defer rl.Unlock()
+	if rl.last.IsZero() {
+		rl.tokens = rl.burst
+	} else if now.After(rl.last) {
+		rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+		if rl.tokens > rl.burst {
+			rl.tokens = rl.burst
+		}
+	}
+	rl.last = now
+	if rl.tokens < 1 {
+		return false
+	}
+	rl.tokens--
+	return true
+}
+
+// SetRateLimit limits the session to perSecond commands per second with
+// bursts of up to burst commands. A rate <= 0 removes the limit.
+// Commands over the limit are not run and have ErrRateLimit as the result.
+func (c *CLI) SetRateLimit(perSecond float64, burst int) {
+	if perSecond <= 0 {
+		c.limiter = nil
+		return
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	c.limiter = &rateLimiter{rate: perSecond, burst: float64(burst)}
+}
+
+// Check the rate limit before running a command.
+// Returns false with an error message if the command can't be run.
+func (c *CLI) rateCheck() bool {
+	if c.limiter == nil || c.limiter.allow(time.Now()) {
+		return true
+	}
+	c.Put(c.colorString(ErrRateLimit.Error(), theme.Error, true) + "\n")
+	c.result = ErrRateLimit
+	return false
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_RateLimiter(t *testing.T) {
+	rl := &rateLimiter{rate: 2, burst: 3}
+	t0 := time.Unix(1000, 0)
+	tests := []struct {
+		dt    time.Duration
+		allow bool
+	}{
+		{0, true},
+		{0, true},
+		{0, true},
+		{0, false},
+		{400 * time.Millisecond, false},
+		{500 * time.Millisecond, true},
+		{500 * time.Millisecond, false},
+		{10 * time.Second, true},
+		{10 * time.Second, true},
+		{10 * time.Second, true},
+		{10 * time.Second, false},
+	}
+	for i, v := range tests {
+		allow := rl.allow(t0.Add(v.dt))
+		if allow != v.allow {
+			t.Errorf("%d: FAIL expected (%v) != actual (%v)", i, v.allow, allow)
+		}
+	}
+}
+
+func Test_RateLimit(t *testing.T) {
+	n := 0
+	user := &testUser{}
+	c := NewCLI(user)
+	c.SetRoot(Menu{
+		{"show", Leaf{"show", func(c *CLI, args []string) { n++ }}},
+	})
+	c.SetRateLimit(0.001, 2)
+	for i := 0; i < 4; i++ {
+		c.parseCmdline("show")
+	}
+	if n != 2 || c.result != ErrRateLimit {
+		t.Errorf("FAIL expected (2, %v) != actual (%d, %v)", ErrRateLimit, n, c.result)
+	}
+	if !strings.Contains(user.out.String(), "rate limit exceeded") {
+		t.Errorf("FAIL missing message %q", user.out.String())
+	}
+	c.SetRateLimit(0, 0)
+	c.parseCmdline("show")
+	if n != 3 {
+		t.Errorf("FAIL expected (3) != actual (%d)", n)
+	}
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// go test -run Test_Render -update
+var update = flag.Bool("update", false, "update the golden files")
+
+// writeRecorder records each write of the line editor output.
+type writeRecorder struct {
+	writes []string
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+// render test cases, the output is compared to testdata/render/<name>.golden
+var renderTests = []struct {
+	name  string // golden file name
+	in    string // key input
+	init  string // initial line
+	cols  int    // terminal columns
+	multi bool   // multiline mode
+	hint  bool   // show a hint
+}{
+	{"single_insert", "abc\r", "", 80, false, false},
+	{"single_move", "\x1b[D\x1b[DX\x01\x05\r", "abc", 80, false, false},
+	{"single_scroll", "0123456789abcdef\x01\r", "", 12, false, false},
+	{"single_wide", "世界\x02é\r", "", 80, false, false},
+	{"single_hint", "sh\r", "", 80, false, true},
+	{"multi_insert", "0123456789abcdef\r", "", 8, true, false},
+	{"multi_delete", "\x08\x08\x08\x08\x08\x01\x0b\r", "0123456789abcdef", 8, true, false},
+	{"multi_wide", "世界世界世界\x02\x02\x08\r", "", 8, true, false},
+}
+
+func Test_Render(t *testing.T) {
+	for _, v := range renderTests {
+		l := NewLineNoise()
+		l.SetColumns(v.cols)
+		l.SetMultiline(v.multi)
+		if v.hint {
+			l.SetHintsCallback(func(s string) *Hint {
+				if s == "sh" {
+					return &Hint{" <show|shutdown>", 35, false}
+				}
+				return nil
+			})
+		}
+		w := &writeRecorder{}
+		line, err := l.Edit(NewByteSource([]byte(v.in)), w, "> ", v.init)
+		if err != nil {
+			t.Errorf("%s: FAIL %s", v.name, err)
+			continue
+		}
+		// one quoted write per line
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "line %q\n", line)
+		for _, s := range w.writes {
+			fmt.Fprintf(&sb, "%q\n", s)
+		}
+		actual := sb.String()
+		path := filepath.Join("testdata", "render", v.name+".golden")
+		if *update {
+			err = ioutil.WriteFile(path, []byte(actual), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Errorf("%s: FAIL %s", v.name, err)
+			continue
+		}
+		if string(buf) != actual {
+			t.Errorf("%s: FAIL expected (%s) != actual (%s)", v.name, buf, actual)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+//-----------------------------------------------------------------------------
+/*
+
+Command Scheduling
+
+Commands can be scheduled to run after a delay, or repeatedly at an interval.
+Scheduled commands are run by the goroutine running the CLI, the command line
+being edited is interrupted while they run.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+
+// ScheduleHelp is help for the schedule command.
+var ScheduleHelp = []Help{
+	{"<cr>", "display all scheduled commands"},
+	{"<delay> <cmd>", "run a command after a delay - Eg. 30s, 5m"},
+	{"every <interval> <cmd>", "run a command repeatedly"},
+	{"cancel <id>", "cancel a scheduled command"},
+}
+
+// Job is a scheduled command.
+type Job struct {
+	ID       int           // job identifier
+	Line     string        // command line
+	When     time.Time     // time of the next run
+	Interval time.Duration // repeat interval, 0 for a single run
+	timer    *time.Timer
+}
+
+// scheduler stores the scheduled jobs.
+type scheduler struct {
+	sync.Mutex
+	jobs   map[int]*Job
+	nextID int
+}
+
+//-----------------------------------------------------------------------------
+
+// Run a scheduled job.
+func (c *CLI) runJob(j *Job) {
+	s := c.sched
+	s.Lock()
+	if _, ok := s.jobs[j.ID]; !ok {
+		// the job has been cancelled
+		s.Unlock()
+		return
+	}
+	if j.Interval != 0 {
+		// reschedule the job
+		j.When = j.When.Add(j.Interval)
+		j.timer = time.AfterFunc(time.Until(j.When), func() { c.runJob(j) })
+	} else {
+		delete(s.jobs, j.ID)
+	}
+	s.Unlock()
+	c.post(func() { c.runJobLine(j) })
+}
+
+// Run the command line of a scheduled job.
+func (c *CLI) runJobLine(j *Job) {
+	item, args, err := c.resolve(c.parser.Split(j.Line))
+	if err != nil {
+		c.Put(fmt.Sprintf("job %d: %s\n", j.ID, err))
+		return
+	}
+	c.result = nil
+	c.runLeaf(item, args)
+	if c.nextLine != "" {
+		// the job has set the line to edit
+		c.currentLine = c.nextLine
+		c.nextLine = ""
+	}
+}
+
+// Schedule schedules a command line to run after a delay.
+// If interval is non-zero the command is repeated at that interval.
+// Returns the job identifier.
+func (c *CLI) Schedule(line string, delay, interval time.Duration) (int, error) {
+	// check the command
+	_, _, err := c.resolve(c.parser.Split(line))
+	if err != nil {
+		return 0, err
+	}
+	if interval < 0 || delay < 0 {
+		return 0, errors.New("negative duration")
+	}
+	s := c.sched
+	s.Lock()
+	defer s.Unlock()
+	s.nextID++
+	j := &Job{
+		ID:       s.nextID,
+		Line:     line,
+		When:     time.Now().Add(delay),
+		Interval: interval,
+	}
+	// the job runs in the cli, not a copy of the session
+	o := c.owner()
+	j.timer = time.AfterFunc(delay, func() { o.runJob(j) })
+	s.jobs[j.ID] = j
+	return j.ID, nil
+}
+
+// Jobs returns the scheduled jobs sorted by identifier.
+func (c *CLI) Jobs() []Job {
+	s := c.sched
+	s.Lock()
+	defer s.Unlock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, *j)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].ID < jobs[k].ID })
+	return jobs
+}
+
+// CancelJob cancels a scheduled job.
+func (c *CLI) CancelJob(id int) error {
+	s := c.sched
+	s.Lock()
+	defer s.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return fmt.Errorf("no job %d", id)
+	}
+	j.timer.Stop()
+	delete(s.jobs, id)
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+
+// displayJobs displays the scheduled jobs.
+func (c *CLI) displayJobs() {
+	jobs := c.Jobs()
+	if len(jobs) == 0 {
+		c.Put("no scheduled commands\n")
+		return
+	}
+	now := time.Now()
+	s := make([][]string, len(jobs))
+	for i, j := range jobs {
+		every := ""
+		if j.Interval != 0 {
+			every = fmt.Sprintf("every %s", j.Interval)
+		}
+		when := j.When.Sub(now).Round(time.Second)
+		s[i] = []string{fmt.Sprintf("%d", j.ID), fmt.Sprintf("in %s", when), every, j.Line}
+	}
+	c.Put(TableString(s, nil, 2) + "\n")
+}
+
+// ScheduleCmd is a leaf function helper for a schedule command.
+func (c *CLI) ScheduleCmd(args []string) {
+	if len(args) == 0 {
+		c.displayJobs()
+		return
+	}
+	if args[0] == "cancel" {
+		err := CheckArgc(args, []int{2})
+		if err == nil {
+			var id int
+			id, err = IntArg(args[1], [2]int{1, int(^uint(0) >> 1)}, 10)
+			if err == nil {
+				err = c.CancelJob(id)
+			}
+		}
+		if err != nil {
+			c.Put(fmt.Sprintf("%s\n", err))
+		}
+		return
+	}
+	var delay, interval time.Duration
+	var err error
+	if args[0] == "every" {
+		// repeating command, the first run is after one interval
+		if len(args) < 3 {
+			c.Put("bad number of arguments\n")
+			return
+		}
+		interval, err = time.ParseDuration(args[1])
+		if err != nil || interval <= 0 {
+			c.Put("invalid interval\n")
+			return
+		}
+		delay = interval
+		args = args[2:]
+	} else {
+		// single run after a delay
+		if len(args) < 2 {
+			c.Put("bad number of arguments\n")
+			return
+		}
+		delay, err = time.ParseDuration(args[0])
+		if err != nil {
+			c.Put("invalid delay\n")
+			return
+		}
+		args = args[1:]
+	}
+	id, err := c.Schedule(strings.Join(args, " "), delay, interval)
+	if err != nil {
+		c.Put(fmt.Sprintf("%s\n", err))
+		return
+	}
+	c.Put(fmt.Sprintf("scheduled job %d\n", id))
+}
+
+//-----------------------------------------------------------------------------
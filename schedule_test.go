@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func Test_Schedule(t *testing.T) {
+	ran := make(chan []string, 1)
+	menu := Menu{
+		{"show", Leaf{"show", func(c *CLI, args []string) { ran <- args }}},
+	}
+	c := NewCLI(&testUser{})
+	c.SetRoot(menu)
+
+	_, err := c.Schedule("bogus", time.Millisecond, 0)
+	if err == nil {
+		t.Errorf("FAIL scheduled an unknown command")
+	}
+	id, err := c.Schedule("sh stats", time.Millisecond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case args := <-ran:
+		if len(args) != 1 || args[0] != "stats" {
+			t.Errorf("FAIL bad arguments %v", args)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("FAIL job %d did not run", id)
+	}
+	// wait for the job to be removed
+	time.Sleep(10 * time.Millisecond)
+	if len(c.Jobs()) != 0 {
+		t.Errorf("FAIL job not removed after running")
+	}
+
+	id, _ = c.Schedule("show", time.Hour, time.Hour)
+	if len(c.Jobs()) != 1 {
+		t.Errorf("FAIL job not listed")
+	}
+	if c.CancelJob(id) != nil || len(c.Jobs()) != 0 {
+		t.Errorf("FAIL job not cancelled")
+	}
+}
+
+func Test_ScheduleMainLoop(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	var line string
+	menu := Menu{
+		{"quit", Leaf{"quit", func(c *CLI, args []string) {
+			line = c.currentLine
+			c.Exit()
+		}}},
+	}
+	c := NewCLI(&testUser{})
+	c.SetRoot(menu)
+	c.ln.SetTerminal(&testTerm{Reader: r})
+	go w.Write([]byte("abc"))
+	if _, err := c.Schedule("quit", 50*time.Millisecond, 0); err != nil {
+		t.Fatal(err)
+	}
+	// the job interrupts the line being edited and runs on this goroutine
+	for c.Running() {
+		c.Run()
+	}
+	if line != "abc" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "abc", line)
+	}
+}
@@ -0,0 +1,182 @@
+//-----------------------------------------------------------------------------
+/*
+
+Screen Regions
+
+A minimal full screen layout for live dashboards run from a leaf function.
+The screen has a fixed header, a scrolling body and a fixed footer. It uses
+the alternate screen buffer so the command line is restored on exit.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+
+const (
+	altScreenOn  = "\x1b[?1049h\x1b[?25l" // alternate screen, hide cursor
+	altScreenOff = "\x1b[?25h\x1b[?1049l" // show cursor, normal screen
+)
+
+// frame redraws screen content, only changed lines are redrawn.
+type frame struct {
+	lines      []string // lines on the screen
+	rows, cols int      // terminal size of the last redraw
+}
+
+// Return the escape sequence to update the screen with new lines.
+func (f *frame) update(lines []string, rows, cols int) string {
+	var sb strings.Builder
+	if rows != f.rows || cols != f.cols {
+		// new or resized screen: redraw everything
+		f.lines = nil
+		f.rows, f.cols = rows, cols
+		sb.WriteString("\x1b[H\x1b[2J")
+	}
+	for i, l := range lines {
+		if i < len(f.lines) && f.lines[i] == l {
+			continue
+		}
+		fmt.Fprintf(&sb, "\x1b[%d;1H%s\x1b[0K", i+1, l)
+	}
+	if len(lines) < len(f.lines) {
+		// erase the old lines
+		fmt.Fprintf(&sb, "\x1b[%d;1H\x1b[J", len(lines)+1)
+	}
+	f.lines = lines
+	return sb.String()
+}
+
+// Return the lines of a string truncated to the terminal size.
+func screenLines(s string, rows, cols int) []string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if len(lines) > rows {
+		lines = lines[:rows]
+	}
+	for i := range lines {
+		lines[i] = TruncateWidth(lines[i], cols)
+	}
+	return lines
+}
+
+// Return the session terminal size for full screen output.
+func (c *CLI) screenSize() (int, int) {
+	rows := c.ln.Rows()
+	if rows <= 0 {
+		rows = 24
+	}
+	return rows, c.columns()
+}
+
+// LoopRender displays the string returned by the render function every
+// interval until one of the exit keys is pressed. The screen is cleared and
+// only the changed lines are redrawn. The command line is restored on exit.
+func (c *CLI) LoopRender(fn func() string, interval time.Duration, exitKeys []rune) {
+	puts(stdoutFd, altScreenOn)
+	defer puts(stdoutFd, altScreenOff)
+	var f frame
+	var next time.Time
+	c.loopKeys(func() bool {
+		if time.Now().Before(next) {
+			// wait a short time so we can poll the keyboard
+			time.Sleep(sleepPoll)
+			return false
+		}
+		next = time.Now().Add(interval)
+		rows, cols := c.screenSize()
+		puts(stdoutFd, f.update(screenLines(fn(), rows, cols), rows, cols))
+		return false
+	}, exitKeys)
+}
+
+// Dashboard is a full screen layout with a header, scrolling body and footer.
+type Dashboard struct {
+	c      *CLI
+	header []string // fixed lines at the top
+	body   []string // scrolling lines
+	footer []string // fixed lines at the bottom
+	f      frame    // screen content
+}
+
+// NewDashboard returns a new dashboard.
+func (c *CLI) NewDashboard() *Dashboard {
+	return &Dashboard{c: c}
+}
+
+// SetHeader sets the lines at the top of the screen.
+func (d *Dashboard) SetHeader(lines ...string) {
+	d.header = lines
+}
+
+// SetFooter sets the lines at the bottom of the screen.
+func (d *Dashboard) SetFooter(lines ...string) {
+	d.footer = lines
+}
+
+// Println adds a line to the scrolling body.
+func (d *Dashboard) Println(s string) {
+	d.body = append(d.body, strings.Split(strings.TrimSuffix(s, "\n"), "\n")...)
+}
+
+// Return the screen lines for a terminal size.
+func (d *Dashboard) lines(rows, cols int) []string {
+	n := rows - len(d.header) - len(d.footer)
+	if n < 0 {
+		n = 0
+	}
+	// keep the body lines that fit
+	if len(d.body) > n {
+		d.body = d.body[len(d.body)-n:]
+	}
+	lines := make([]string, 0, rows)
+	lines = append(lines, d.header...)
+	lines = append(lines, d.body...)
+	for len(lines) < rows-len(d.footer) {
+		lines = append(lines, "")
+	}
+	lines = append(lines, d.footer...)
+	if len(lines) > rows {
+		lines = lines[:rows]
+	}
+	for i := range lines {
+		lines[i] = TruncateWidth(lines[i], cols)
+	}
+	return lines
+}
+
+// Render draws the dashboard.
+func (d *Dashboard) Render() {
+	rows, cols := d.c.screenSize()
+	puts(stdoutFd, d.f.update(d.lines(rows, cols), rows, cols))
+}
+
+// Run displays the dashboard until the update function returns true or the
+// exit key is pressed. The update function is called every interval and the
+// dashboard is redrawn after each update. Returns true if the update function
+// completed, false if the exit key was pressed.
+func (d *Dashboard) Run(update func(d *Dashboard) bool, interval time.Duration, exitKey rune) bool {
+	puts(stdoutFd, altScreenOn)
+	defer puts(stdoutFd, altScreenOff)
+	d.f = frame{}
+	var next time.Time
+	return d.c.Loop(func() bool {
+		if time.Now().Before(next) {
+			// wait a short time so we can poll the keyboard
+			time.Sleep(sleepPoll)
+			return false
+		}
+		next = time.Now().Add(interval)
+		done := update(d)
+		d.Render()
+		return done
+	}, exitKey)
+}
+
+//-----------------------------------------------------------------------------
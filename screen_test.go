@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Dashboard(t *testing.T) {
+	d := NewCLI(&testUser{}).NewDashboard()
+	d.SetHeader("header")
+	d.SetFooter("footer")
+	for _, s := range []string{"1", "2", "3\n4"} {
+		d.Println(s)
+	}
+	tests := []struct {
+		rows, cols int
+		lines      string
+	}{
+		{8, 80, "header,1,2,3,4,,,footer"},
+		{4, 80, "header,3,4,footer"},
+		{3, 3, "hea,4,foo"},
+	}
+	for i, v := range tests {
+		lines := strings.Join(d.lines(v.rows, v.cols), ",")
+		if lines != v.lines {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.lines, lines)
+		}
+	}
+}
+
+func Test_FrameUpdate(t *testing.T) {
+	var f frame
+	tests := []struct {
+		lines      []string
+		rows, cols int
+		seq        string
+	}{
+		{[]string{"a", "b"}, 24, 80, "\x1b[H\x1b[2J\x1b[1;1Ha\x1b[0K\x1b[2;1Hb\x1b[0K"},
+		{[]string{"a", "c"}, 24, 80, "\x1b[2;1Hc\x1b[0K"},
+		{[]string{"a"}, 24, 80, "\x1b[2;1H\x1b[J"},
+		{[]string{"a"}, 24, 40, "\x1b[H\x1b[2J\x1b[1;1Ha\x1b[0K"},
+	}
+	for i, v := range tests {
+		seq := f.update(v.lines, v.rows, v.cols)
+		if seq != v.seq {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.seq, seq)
+		}
+	}
+}
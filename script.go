@@ -0,0 +1,313 @@
+//-----------------------------------------------------------------------------
+/*
+
+Command Scripts
+
+A script is a list of command lines with minimal control flow:
+
+	if <command>        run the block if the command succeeds
+	else                run the block if the command failed
+	end
+
+	repeat <n>          run the block n times
+	end
+
+	foreach <var> <item> <item> ...
+	  <command $var>    run the block with $var set to each item
+	end
+
+	on-error continue   ignore failed commands (default)
+	on-error stop       stop the script if a command fails
+	on-error goto <label>
+	label <label>       continue from a top level label if a command fails
+
+Script arguments are available as $1..$9 and $* (all arguments).
+The result of the last command is $?, it's empty if the command succeeded.
+Blank lines and lines starting with '#' are ignored.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+
+// maximum nesting of running scripts
+const scriptDepth = 8
+
+// script statement types
+const (
+	stmtCmd = iota
+	stmtIf
+	stmtRepeat
+	stmtForeach
+	stmtLabel
+	stmtOnError
+)
+
+// script statement
+type stmt struct {
+	kind   int     // statement type
+	lineno int     // line number in the script
+	line   string  // command line, or the statement arguments
+	body   []*stmt // statements for if/repeat/foreach
+	orelse []*stmt // statements for else
+}
+
+// Return the first word of a line and the rest of the line.
+func firstWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i:])
+	}
+	return s, ""
+}
+
+// Parse script lines into a list of statements.
+// Return the statements, the index of the next line and the keyword that ended the block.
+func parseBlock(lines []string, i int) ([]*stmt, int, string, error) {
+	var block []*stmt
+	for i < len(lines) {
+		lineno := i + 1
+		key, rest := firstWord(lines[i])
+		i++
+		if key == "" || strings.HasPrefix(key, "#") {
+			continue
+		}
+		switch key {
+		case "else", "end":
+			return block, i, key, nil
+		case "label", "on-error":
+			if rest == "" {
+				return nil, 0, "", fmt.Errorf("line %d: %s needs an argument", lineno, key)
+			}
+			kind := stmtLabel
+			if key == "on-error" {
+				kind = stmtOnError
+			}
+			block = append(block, &stmt{kind: kind, lineno: lineno, line: rest})
+		case "if", "repeat", "foreach":
+			if rest == "" {
+				return nil, 0, "", fmt.Errorf("line %d: %s needs an argument", lineno, key)
+			}
+			s := &stmt{lineno: lineno, line: rest}
+			var end string
+			var err error
+			s.body, i, end, err = parseBlock(lines, i)
+			if err != nil {
+				return nil, 0, "", err
+			}
+			if key == "if" {
+				s.kind = stmtIf
+				if end == "else" {
+					s.orelse, i, end, err = parseBlock(lines, i)
+					if err != nil {
+						return nil, 0, "", err
+					}
+				}
+			} else if key == "repeat" {
+				s.kind = stmtRepeat
+			} else {
+				s.kind = stmtForeach
+			}
+			if end != "end" {
+				return nil, 0, "", fmt.Errorf("line %d: %s has no end", lineno, key)
+			}
+			block = append(block, s)
+		default:
+			block = append(block, &stmt{kind: stmtCmd, lineno: lineno, line: strings.TrimSpace(lines[i-1])})
+		}
+	}
+	return block, i, "", nil
+}
+
+// Parse script lines into a list of statements.
+func parseScript(lines []string) ([]*stmt, error) {
+	block, i, end, err := parseBlock(lines, 0)
+	if err != nil {
+		return nil, err
+	}
+	if end != "" {
+		return nil, fmt.Errorf("line %d: unexpected %s", i, end)
+	}
+	return block, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// Return the index of a label statement in a block, -1 if not found.
+func labelIndex(block []*stmt, label string) int {
+	for i, s := range block {
+		if s.kind == stmtLabel && s.line == label {
+			return i
+		}
+	}
+	return -1
+}
+
+// Substitute $name and ${name} variables in a string.
+// Unknown variables are left unchanged.
+func substitute(s string, vars map[string]string) string {
+	return os.Expand(s, func(name string) string {
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return "${" + name + "}"
+	})
+}
+
+// script execution state
+type script struct {
+	vars    map[string]string // script variables
+	onError string            // action for a failed command: continue, stop or goto
+	label   string            // label for on-error goto
+}
+
+// errGoto is returned to unwind to a top level label.
+type errGoto struct {
+	label string
+}
+
+func (e *errGoto) Error() string {
+	return fmt.Sprintf("label \"%s\" not found", e.label)
+}
+
+// Run a command line and set $? to its result.
+func (c *CLI) runCmd(sc *script, line string) {
+	c.parseCmdline(line)
+	sc.vars["?"] = ""
+	if c.result != nil {
+		sc.vars["?"] = c.result.Error()
+	}
+}
+
+// Run a block of script statements.
+func (c *CLI) runBlock(sc *script, block []*stmt) error {
+	for _, s := range block {
+		if !c.running {
+			return nil
+		}
+		line := substitute(s.line, sc.vars)
+		switch s.kind {
+		case stmtCmd:
+			c.runCmd(sc, line)
+			if c.result != nil {
+				switch sc.onError {
+				case "stop":
+					return fmt.Errorf("line %d: %s", s.lineno, c.result)
+				case "goto":
+					return &errGoto{sc.label}
+				}
+			}
+		case stmtLabel:
+			// no-op
+		case stmtOnError:
+			args := strings.Fields(line)
+			switch {
+			case len(args) == 1 && (args[0] == "continue" || args[0] == "stop"):
+				sc.onError = args[0]
+			case len(args) == 2 && args[0] == "goto":
+				sc.onError = args[0]
+				sc.label = args[1]
+			default:
+				return fmt.Errorf("line %d: invalid on-error action", s.lineno)
+			}
+		case stmtIf:
+			c.runCmd(sc, line)
+			body := s.body
+			if c.result != nil {
+				body = s.orelse
+			}
+			if err := c.runBlock(sc, body); err != nil {
+				return err
+			}
+		case stmtRepeat:
+			n, err := strconv.Atoi(line)
+			if err != nil || n < 0 {
+				return fmt.Errorf("line %d: invalid repeat count", s.lineno)
+			}
+			for j := 0; j < n && c.running; j++ {
+				if err := c.runBlock(sc, s.body); err != nil {
+					return err
+				}
+			}
+		case stmtForeach:
+			items := strings.Fields(line)
+			if len(items) == 0 {
+				return fmt.Errorf("line %d: foreach needs a variable", s.lineno)
+			}
+			name := items[0]
+			for _, item := range items[1:] {
+				sc.vars[name] = item
+				if err := c.runBlock(sc, s.body); err != nil {
+					return err
+				}
+			}
+			delete(sc.vars, name)
+		}
+	}
+	return nil
+}
+
+// Return the variables for the script arguments.
+func scriptArgs(args []string) map[string]string {
+	vars := make(map[string]string)
+	for i := 1; i <= 9; i++ {
+		vars[strconv.Itoa(i)] = ""
+		if i <= len(args) {
+			vars[strconv.Itoa(i)] = args[i-1]
+		}
+	}
+	vars["*"] = strings.Join(args, " ")
+	vars["?"] = ""
+	return vars
+}
+
+// RunScript runs the command lines of a script with optional arguments.
+func (c *CLI) RunScript(lines []string, args ...string) error {
+	block, err := parseScript(lines)
+	if err != nil {
+		return err
+	}
+	if c.scriptDepth >= scriptDepth {
+		return errors.New("scripts nested too deeply")
+	}
+	c.scriptDepth++
+	defer func() { c.scriptDepth-- }()
+	sc := &script{vars: scriptArgs(args), onError: "continue"}
+	for {
+		err = c.runBlock(sc, block)
+		g, ok := err.(*errGoto)
+		if !ok {
+			return err
+		}
+		// continue from the top level label
+		i := labelIndex(block, g.label)
+		if i < 0 {
+			return err
+		}
+		block = block[i+1:]
+		// a failure after the label continues
+		sc.onError = "continue"
+	}
+}
+
+// RunScriptFile runs the command lines of a script file with optional arguments.
+func (c *CLI) RunScriptFile(path string, args ...string) error {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return c.RunScript(strings.Split(string(buf), "\n"), args...)
+}
+
+//-----------------------------------------------------------------------------
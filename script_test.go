@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_Script(t *testing.T) {
+	var ran []string
+	menu := Menu{
+		{"show", Leaf{"show", func(c *CLI, args []string) { ran = append(ran, strings.Join(args, " ")) }}},
+		{"fail", Leaf{"fail", func(c *CLI, args []string) { c.SetResult(errors.New("failed")) }}},
+	}
+	c := NewCLI(&testUser{})
+	c.SetRoot(menu)
+
+	tests := []struct {
+		script string
+		ran    string
+		err    string
+	}{
+		{"show a\n\n# comment\nshow b", "a,b", ""},
+		{"if show x\n show y\nelse\n show z\nend", "x,y", ""},
+		{"if fail\n show y\nelse\n show z\nend", "z", ""},
+		{"if bogus\n show y\nend", "", ""},
+		{"repeat 3\n show r\nend", "r,r,r", ""},
+		{"fail\nshow [$?]\nshow\nshow [$?]", "[failed],,[]", ""},
+		{"on-error stop\nshow a\nfail\nshow b", "a", "line 3: failed"},
+		{"on-error goto err\nfail\nshow a\nlabel err\nshow b\nfail\nshow c", "b,c", ""},
+		{"foreach x a b\n repeat 2\n  show $x ${x}\n end\nend\nshow $x", "a a,a a,b b,b b,${x}", ""},
+		{"foreach $1\nshow\nend", "", "line 1: foreach needs a variable"},
+	}
+	for i, v := range tests {
+		ran = nil
+		err := c.RunScript(strings.Split(v.script, "\n"))
+		e := ""
+		if err != nil {
+			e = err.Error()
+		}
+		if e != v.err || strings.Join(ran, ",") != v.ran {
+			t.Errorf("%d: FAIL expected (%q, %q) != actual (%q, %q)", i, v.ran, v.err, strings.Join(ran, ","), e)
+		}
+	}
+
+	ran = nil
+	c.RunScript([]string{"show $1 $2 $9", "show $*"}, "a", "b")
+	if strings.Join(ran, ",") != "a b,a b" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "a b,a b", strings.Join(ran, ","))
+	}
+
+	for i, s := range []string{"if show\nshow a", "end", "repeat\nend", "repeat x\nend", "on-error skip", "on-error stop\nfail", "on-error goto x\nfail"} {
+		if c.RunScript(strings.Split(s, "\n")) == nil {
+			t.Errorf("%d: FAIL expected an error for %q", i, s)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+//-----------------------------------------------------------------------------
+/*
+
+Remote Command API
+
+An HTTP handler that exposes the menu tree so automation can use the same
+command implementations as the interactive CLI.
+
+	GET  /commands          list the menus and commands (JSON)
+	GET  /help?cmd=<line>   display help for a command line (text)
+	POST /exec?cmd=<line>   run a command, the output is streamed (text)
+
+Requests are handled by the goroutine running the CLI, in turn with the command
+line. Commands are run in their own session with a line editor that has no
+input, so leaf functions that read from the terminal get an EOF error. Access
+control is left to the application (wrap the handler).
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+
+// httpUser streams output to an HTTP response.
+type httpUser struct {
+	w http.ResponseWriter
+}
+
+func (u httpUser) Put(s string) {
+	io.WriteString(u.w, s)
+	if f, ok := u.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// remoteTerm is the terminal for a remote command.
+// There is no input and the output is discarded.
+type remoteTerm struct{}
+
+func (remoteTerm) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (remoteTerm) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (remoteTerm) MakeRaw() (func() error, error) {
+	return nil, nil
+}
+
+func (remoteTerm) Size() (int, int) {
+	return 0, 0
+}
+
+// commandInfo describes a menu or command for the remote API.
+type commandInfo struct {
+	Path  string `json:"path"`
+	Descr string `json:"descr"`
+	Menu  bool   `json:"menu,omitempty"`
+	Help  []Help `json:"help,omitempty"`
+}
+
+//-----------------------------------------------------------------------------
+
+// Return the session for a remote command.
+// It has the menus and settings of the cli with output to an HTTP response.
+func (c *CLI) remoteSession(w http.ResponseWriter, r *http.Request) *CLI {
+	rc := *c
+	rc.origin = c.owner()
+	rc.User = httpUser{w}
+	rc.ln = NewLineNoise()
+	rc.ln.SetTerminal(remoteTerm{})
+	rc.currentLine = ""
+	rc.nextLine = ""
+	rc.out = nil
+	// plain text output for http clients
+	rc.color = ColorOff
+	rc.result = nil
+	rc.ctx = r.Context()
+	return &rc
+}
+
+func (c *CLI) httpCommands(w http.ResponseWriter, r *http.Request) {
+	var cmds []commandInfo
+	c.Walk(func(path []string, item MenuItem) {
+		ci := commandInfo{
+			Path:  strings.Join(path, " "),
+			Descr: itemDescr(item),
+		}
+		if _, ok := item[1].(Menu); ok {
+			ci.Menu = true
+		} else {
+			ci.Help = itemHelp(item)
+		}
+		cmds = append(cmds, ci)
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cmds)
+}
+
+func (c *CLI) httpHelp(w http.ResponseWriter, r *http.Request) {
+	cmd := strings.TrimSpace(r.FormValue("cmd"))
+	if cmd != "" {
+		cmd += " "
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, c.helpCallback(cmd))
+}
+
+func (c *CLI) httpExec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	item, args, err := c.resolve(c.parser.Split(c.expandAlias(r.FormValue("cmd"))))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if c.limiter != nil && !c.limiter.allow(time.Now()) {
+		http.Error(w, ErrRateLimit.Error(), http.StatusTooManyRequests)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Trailer", "X-Cli-Error")
+	rc := c.remoteSession(w, r)
+	defer rc.ln.Close()
+	rc.runLeaf(item, args)
+	if rc.result != nil {
+		w.Header().Set("X-Cli-Error", rc.result.Error())
+	}
+}
+
+// Return a handler that is run by the goroutine running the cli.
+func (c *CLI) httpPost(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		<-c.post(func() { fn(w, r) })
+	}
+}
+
+// HTTPHandler returns an HTTP handler for the remote command API.
+func (c *CLI) HTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/commands", c.httpPost(c.httpCommands))
+	mux.HandleFunc("/help", c.httpPost(c.httpHelp))
+	mux.HandleFunc("/exec", c.httpPost(c.httpExec))
+	return mux
+}
+
+//-----------------------------------------------------------------------------
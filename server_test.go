@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_HTTPHandler(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.SetRoot(Menu{
+		{"show", Menu{
+			{"stats", Leaf{"show stats", func(c *CLI, args []string) { c.Put("stats " + strings.Join(args, " ")) }}},
+		}, "show functions"},
+		{"fail", Leaf{"fail", func(c *CLI, args []string) { c.SetResult(errors.New("failed")) }}},
+		{"ask", Leaf{"ask", func(c *CLI, args []string) {
+			_, err := c.ReadLineDefault("name: ", "")
+			c.SetResult(err)
+		}}},
+	})
+	ts := httptest.NewServer(c.HTTPHandler())
+	defer ts.Close()
+
+	get := func(resp *http.Response, err error) (string, *http.Response) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		buf, _ := ioutil.ReadAll(resp.Body)
+		return string(buf), resp
+	}
+
+	s, _ := get(http.Get(ts.URL + "/commands"))
+	if !strings.Contains(s, `"path":"show stats"`) || !strings.Contains(s, `"menu":true`) {
+		t.Errorf("FAIL bad command list %q", s)
+	}
+	s, _ = get(http.Get(ts.URL + "/help?cmd=show"))
+	if !strings.Contains(s, "stats") {
+		t.Errorf("FAIL bad help %q", s)
+	}
+	s, resp := get(http.PostForm(ts.URL+"/exec", url.Values{"cmd": {"sh st 1 2"}}))
+	if resp.StatusCode != http.StatusOK || s != "stats 1 2" {
+		t.Errorf("FAIL bad exec output %d %q", resp.StatusCode, s)
+	}
+	_, resp = get(http.PostForm(ts.URL+"/exec", url.Values{"cmd": {"fail"}}))
+	if resp.Trailer.Get("X-Cli-Error") != "failed" {
+		t.Errorf("FAIL missing error trailer")
+	}
+	// there is no terminal input for a remote command
+	_, resp = get(http.PostForm(ts.URL+"/exec", url.Values{"cmd": {"ask"}}))
+	if resp.Trailer.Get("X-Cli-Error") != io.EOF.Error() {
+		t.Errorf("FAIL expected (%v) != actual (%q)", io.EOF, resp.Trailer.Get("X-Cli-Error"))
+	}
+	_, resp = get(http.PostForm(ts.URL+"/exec", url.Values{"cmd": {"bogus"}}))
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("FAIL unknown command status %d", resp.StatusCode)
+	}
+	c.SetRateLimit(0.001, 1)
+	get(http.PostForm(ts.URL+"/exec", url.Values{"cmd": {"sh st"}}))
+	_, resp = get(http.PostForm(ts.URL+"/exec", url.Values{"cmd": {"sh st"}}))
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("FAIL rate limited command status %d", resp.StatusCode)
+	}
+}
+
+func Test_HTTPMainLoop(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	c := NewCLI(&testUser{})
+	var line string
+	c.SetRoot(Menu{
+		{"quit", Leaf{"quit", func(rc *CLI, args []string) {
+			// the request is handled while the main loop is stopped
+			line = c.currentLine
+			c.Exit()
+		}}},
+	})
+	c.ln.SetTerminal(&testTerm{Reader: r})
+	ts := httptest.NewServer(c.HTTPHandler())
+	defer ts.Close()
+	go func() {
+		w.Write([]byte("abc"))
+		time.Sleep(50 * time.Millisecond)
+		resp, err := http.PostForm(ts.URL+"/exec", url.Values{"cmd": {"quit"}})
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	for c.Running() {
+		c.Run()
+	}
+	if line != "abc" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "abc", line)
+	}
+}
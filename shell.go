@@ -0,0 +1,88 @@
+//-----------------------------------------------------------------------------
+/*
+
+Child Shells
+
+A leaf function can run a child CLI with its own menu and prompt.
+Eg. a per-device debug shell. The child shares the line editor and terminal
+of the parent and has its own command history. The parent prompt returns
+when the child exits.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+//-----------------------------------------------------------------------------
+
+// lineSettings are the line editor settings made by a CLI.
+type lineSettings struct {
+	completion    func(string) []string // completion callback
+	help          func(string) string   // help callback
+	helpKey       rune                  // help hotkey
+	hints         func(string) *Hint    // hints callback
+	completionPad bool                  // completion padding for display only
+	overwrite     func(bool) string     // overwrite mode callback
+}
+
+// Save the line editor settings.
+func (l *Linenoise) saveSettings() lineSettings {
+	return lineSettings{l.completionCallback, l.helpCallback, l.helpKey, l.hintsCallback, l.completionPad, l.overwriteCallback}
+}
+
+// Restore the line editor settings.
+func (l *Linenoise) restoreSettings(s lineSettings) {
+	l.completionCallback = s.completion
+	l.helpCallback = s.help
+	l.helpKey = s.helpKey
+	l.hintsCallback = s.hints
+	l.completionPad = s.completionPad
+	l.overwriteCallback = s.overwrite
+}
+
+// Shell runs a child CLI with its own menu and prompt from a leaf function.
+// The setup function (if not nil) configures the child before it runs.
+// Eg. child.AddStandardCommands(0) for an exit command. Shell returns when
+// the child exits, the error is nil if the user exited or quit the child.
+func (c *CLI) Shell(root Menu, prompt string, setup func(child *CLI)) error {
+	if c.out != nil {
+		// show pending output before the child prompt
+		c.out.flush()
+	}
+	child := NewCLI(c.User)
+	child.SetRoot(root)
+	child.SetPrompt(prompt)
+	child.outputMode = c.outputMode
+	child.length = c.length
+	child.SetHelpChar(c.helpChar)
+	child.parser = c.parser
+	child.color = c.color
+	child.charset = c.charset
+	child.cmdTimeout = c.cmdTimeout
+	// work for the parent is done while the child is running
+	child.work = c.work
+	// share the line editor, keep the parent settings and history
+	settings := c.ln.saveSettings()
+	history := c.ln.history
+	c.ln.restoreSettings(child.ln.saveSettings())
+	c.ln.history = nil
+	child.ln.Close()
+	child.ln = c.ln
+	child.sharedLn = true
+	defer func() {
+		c.ln.restoreSettings(settings)
+		c.ln.history = history
+	}()
+	if setup != nil {
+		setup(child)
+	}
+	for child.Running() {
+		child.Run()
+	}
+	if child.Err() == ErrQuit {
+		return nil
+	}
+	return child.Err()
+}
+
+//-----------------------------------------------------------------------------
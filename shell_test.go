@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func Test_Shell(t *testing.T) {
+	var ran []string
+	leaf := func(name string) Leaf {
+		return Leaf{name, func(c *CLI, args []string) { ran = append(ran, name) }}
+	}
+	childMenu := Menu{
+		{"ping", leaf("ping")},
+	}
+	var shellErr error
+	var shellClosed bool
+	c := NewCLI(&testUser{})
+	c.SetRoot(Menu{
+		{"debug", Leaf{"debug shell", func(c *CLI, args []string) {
+			shellErr = c.Shell(childMenu, "debug> ", func(child *CLI) {
+				child.AddStandardCommands(0)
+			})
+			// the child doesn't close the shared line editor
+			shellClosed = c.ln.wakePipe == nil
+		}}},
+		{"show", leaf("show")},
+	})
+	c.ln.HistoryAdd("old")
+	c.ln.injectInit()
+	c.ln.scanner = bufio.NewScanner(strings.NewReader("debug\nping\nshow\nexit\nshow\n"))
+	for c.Running() {
+		c.Run()
+	}
+	if s := strings.Join(ran, ","); s != "ping,show" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "ping,show", s)
+	}
+	if shellErr != nil {
+		t.Errorf("FAIL shell error %v", shellErr)
+	}
+	if shellClosed {
+		t.Errorf("FAIL child closed the parent line editor")
+	}
+	// the child history isn't added to the parent
+	if h := strings.Join(c.ln.historyList(), ","); h != "old,debug,show" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "old,debug,show", h)
+	}
+	// the parent callbacks are restored
+	if lc := c.Complete("de"); len(lc) != 1 || lc[0] != "debug" {
+		t.Errorf("FAIL bad parent completions %q", lc)
+	}
+	if lc := c.ln.completionCallback("de"); len(lc) != 1 || lc[0] != "debug" {
+		t.Errorf("FAIL bad line editor completions %q", lc)
+	}
+}
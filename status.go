@@ -0,0 +1,102 @@
+//-----------------------------------------------------------------------------
+/*
+
+Status Line
+
+A one line status bar on the bottom row of the terminal. The rows above it
+are set as the scrolling region so the status line is kept in place by the
+terminal as output scrolls. The scrolling region is reset when the terminal
+is resized.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+//-----------------------------------------------------------------------------
+
+// statusLine stores the status line state.
+type statusLine struct {
+	sync.Mutex
+	s     string         // status string
+	rows  int            // terminal rows when the status was drawn
+	winch chan os.Signal // terminal resize notification
+}
+
+// Return the escape sequence to draw the status line on the bottom row.
+func statusSeq(s string, rows, cols int) string {
+	s = TruncateWidth(s, cols)
+	// ensure the cursor is above the bottom row
+	seq := "\x1bD\x1b[1A"
+	// save the cursor, set the scrolling region, draw the status, restore the cursor
+	seq += fmt.Sprintf("\x1b7\x1b[1;%dr\x1b[%d;1H\x1b[2K\x1b[7m%s\x1b[0m\x1b8", rows-1, rows, s)
+	return seq
+}
+
+// Return the escape sequence to remove the status line.
+func statusClearSeq(rows int) string {
+	return fmt.Sprintf("\x1b7\x1b[r\x1b[%d;1H\x1b[2K\x1b8", rows)
+}
+
+// Draw the status line.
+func (c *CLI) drawStatus() {
+	st := c.status
+	rows := c.ln.Rows()
+	if rows < 2 {
+		return
+	}
+	if st.s == "" {
+		if st.rows != 0 {
+			puts(stdoutFd, statusClearSeq(st.rows))
+			st.rows = 0
+		}
+		return
+	}
+	puts(stdoutFd, statusSeq(st.s, rows, c.columns()))
+	st.rows = rows
+}
+
+// SetStatus sets the status line displayed on the bottom row of the terminal.
+// An empty string removes the status line. It's safe to call from other goroutines.
+func (c *CLI) SetStatus(s string) {
+	if !isTerminal(stdoutFd) {
+		return
+	}
+	st := c.status
+	st.Lock()
+	defer st.Unlock()
+	st.s = s
+	c.drawStatus()
+	if s != "" && st.winch == nil {
+		// redraw the status when the terminal is resized
+		st.winch = make(chan os.Signal, 1)
+		notifyResize(st.winch)
+		go func(ch chan os.Signal) {
+			for range ch {
+				st.Lock()
+				c.drawStatus()
+				st.Unlock()
+			}
+		}(st.winch)
+	} else if s == "" && st.winch != nil {
+		signal.Stop(st.winch)
+		close(st.winch)
+		st.winch = nil
+	}
+}
+
+// Status returns the status line.
+func (c *CLI) Status() string {
+	c.status.Lock()
+	defer c.status.Unlock()
+	return c.status.s
+}
+
+//-----------------------------------------------------------------------------
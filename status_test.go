@@ -0,0 +1,13 @@
+package cli
+
+import (
+	"testing"
+)
+
+func Test_StatusSeq(t *testing.T) {
+	s := statusSeq("link up, 2 alarms", 24, 8)
+	expect := "\x1bD\x1b[1A\x1b7\x1b[1;23r\x1b[24;1H\x1b[2K\x1b[7mlink up,\x1b[0m\x1b8"
+	if s != expect {
+		t.Errorf("FAIL expected (%q) != actual (%q)", expect, s)
+	}
+}
@@ -0,0 +1,219 @@
+//-----------------------------------------------------------------------------
+/*
+
+Streaming Tables
+
+A table builder for leaf functions. Rows are added as they are generated and
+the table is output in batches, with columns sized to fit the terminal.
+
+Tables can also be encoded as CSV, TSV or JSON rows so the output of commands
+can be consumed by scripts.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+//-----------------------------------------------------------------------------
+
+// OutputMode is the encoding used for table output.
+type OutputMode int
+
+// Output modes
+const (
+	OutputText OutputMode = iota // aligned columns (default)
+	OutputCSV                    // comma separated values
+	OutputTSV                    // tab separated values
+	OutputJSON                   // a JSON object (or array) per row
+)
+
+var outputModeNames = []string{"text", "csv", "tsv", "json"}
+
+// String returns the name of the output mode.
+func (m OutputMode) String() string {
+	if int(m) < len(outputModeNames) {
+		return outputModeNames[m]
+	}
+	return "unknown"
+}
+
+// EncodeTable returns the string encoding of table rows.
+// For JSON output the header (if any) provides the object keys.
+func EncodeTable(header []string, rows [][]string, mode OutputMode) string {
+	switch mode {
+	case OutputCSV, OutputTSV:
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		if mode == OutputTSV {
+			w.Comma = '\t'
+		}
+		if header != nil {
+			w.Write(header)
+		}
+		w.WriteAll(rows)
+		return b.String()
+	case OutputJSON:
+		s := make([]string, len(rows))
+		for i, r := range rows {
+			var buf []byte
+			if header != nil {
+				obj := make(map[string]string)
+				for j := range r {
+					if j < len(header) {
+						obj[header[j]] = r[j]
+					}
+				}
+				buf, _ = json.Marshal(obj)
+			} else {
+				buf, _ = json.Marshal(r)
+			}
+			s[i] = string(buf) + "\n"
+		}
+		return strings.Join(s, "")
+	}
+	// text
+	if header != nil {
+		rows = append([][]string{header}, rows...)
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+	return TableString(rows, nil, 1) + "\n"
+}
+
+//-----------------------------------------------------------------------------
+
+// rows are output in batches of this size
+const tableBatch = 64
+
+// Table is a streaming table builder.
+type Table struct {
+	c       *CLI       // output cli
+	header  []string   // column names
+	rows    [][]string // buffered rows
+	flushed bool       // has any output been flushed?
+	csize   []int      // column widths
+	cmargin int        // column to column margin
+}
+
+// NewTable returns a new table builder that outputs to the CLI.
+func NewTable(c *CLI) *Table {
+	return &Table{
+		c:       c,
+		cmargin: 1,
+	}
+}
+
+// SetMargin sets the column to column margin.
+func (t *Table) SetMargin(n int) *Table {
+	t.cmargin = n
+	return t
+}
+
+// SetHeader sets the column names.
+// Text and CSV/TSV output starts with a header row. JSON output uses the column
+// names as object keys.
+func (t *Table) SetHeader(cols ...string) *Table {
+	t.header = cols
+	return t
+}
+
+// AddRow adds a row of column strings to the table.
+func (t *Table) AddRow(cols ...string) *Table {
+	t.rows = append(t.rows, cols)
+	if len(t.rows) >= tableBatch {
+		t.Flush()
+	}
+	return t
+}
+
+// Return the number of columns in the buffered rows.
+func (t *Table) ncols() int {
+	n := len(t.csize)
+	for _, r := range t.rows {
+		if len(r) > n {
+			n = len(r)
+		}
+	}
+	return n
+}
+
+// Fit the buffered rows to the terminal width.
+func (t *Table) fit() {
+	ncols := t.ncols()
+	// pad the rows and column sizes to the same number of columns
+	for len(t.csize) < ncols {
+		t.csize = append(t.csize, 0)
+	}
+	for i := range t.rows {
+		for len(t.rows[i]) < ncols {
+			t.rows[i] = append(t.rows[i], "")
+		}
+	}
+	// size the columns
+	for _, r := range t.rows {
+		for j := range r {
+			w := runewidth.StringWidth(r[j]) + t.cmargin
+			if w > t.csize[j] {
+				t.csize[j] = w
+			}
+		}
+	}
+	// truncate the last column to fit the terminal
+	cols := t.c.ln.Columns()
+	if cols == 0 {
+		return
+	}
+	width := 0
+	for _, n := range t.csize[:ncols-1] {
+		width += n
+	}
+	avail := cols - width - 1
+	if avail < 4 || t.csize[ncols-1] <= avail {
+		// no room to truncate or no truncation needed
+		return
+	}
+	for i := range t.rows {
+		t.rows[i][ncols-1] = TruncateEllipsis(t.rows[i][ncols-1], avail)
+	}
+	t.csize[ncols-1] = avail
+}
+
+// Flush outputs the buffered rows.
+func (t *Table) Flush() {
+	if len(t.rows) == 0 {
+		return
+	}
+	header := t.header
+	if t.flushed {
+		// the header has already been output
+		header = nil
+	}
+	if t.c.outputMode != OutputText {
+		// encoded output
+		if t.c.outputMode == OutputJSON {
+			header = t.header
+		}
+		t.c.Put(EncodeTable(header, t.rows, t.c.outputMode))
+	} else {
+		// aligned text output
+		if header != nil {
+			t.rows = append([][]string{header}, t.rows...)
+		}
+		t.fit()
+		t.c.Put(TableString(t.rows, t.csize, t.cmargin) + "\n")
+	}
+	t.rows = nil
+	t.flushed = true
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,35 @@
+package cli
+
+import "testing"
+
+func Test_Table(t *testing.T) {
+	user := &testUser{}
+	c := NewCLI(user)
+	NewTable(c).AddRow("a", "bb").AddRow("ccc", "d", "e").Flush()
+	expected := "a   bb   \nccc d  e \n"
+	if user.out.String() != expected {
+		t.Errorf("FAIL expected (%q) != actual (%q)", expected, user.out.String())
+	}
+}
+
+func Test_EncodeTable(t *testing.T) {
+	header := []string{"name", "value"}
+	rows := [][]string{{"a", "1"}, {"b c", "2,3"}}
+	tests := []struct {
+		header []string
+		mode   OutputMode
+		s      string
+	}{
+		{header, OutputCSV, "name,value\na,1\nb c,\"2,3\"\n"},
+		{header, OutputTSV, "name\tvalue\na\t1\nb c\t2,3\n"},
+		{header, OutputJSON, "{\"name\":\"a\",\"value\":\"1\"}\n{\"name\":\"b c\",\"value\":\"2,3\"}\n"},
+		{nil, OutputJSON, "[\"a\",\"1\"]\n[\"b c\",\"2,3\"]\n"},
+		{nil, OutputText, "a   1   \nb c 2,3 \n"},
+	}
+	for i, v := range tests {
+		s := EncodeTable(v.header, rows, v.mode)
+		if s != v.s {
+			t.Errorf("%d: FAIL expected (%q) != actual (%q)", i, v.s, s)
+		}
+	}
+}
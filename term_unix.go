@@ -0,0 +1,167 @@
+//go:build !windows
+// +build !windows
+
+//-----------------------------------------------------------------------------
+/*
+
+Unix Terminal
+
+Terminal IO using termios raw mode, poll() and the window size ioctl.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/creack/termios/raw"
+	"github.com/mattn/go-isatty"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+
+// standard file descriptors
+var (
+	stdinFd  = syscall.Stdin
+	stdoutFd = syscall.Stdout
+	stderrFd = syscall.Stderr
+)
+
+// termMode is a saved terminal mode.
+type termMode = raw.Termios
+
+// Is the file descriptor a terminal?
+func isTerminal(fd int) bool {
+	return isatty.IsTerminal(uintptr(fd))
+}
+
+//-----------------------------------------------------------------------------
+// control the terminal mode
+
+// Set a tty terminal to raw mode.
+func setRawMode(fd int) (*termMode, error) {
+	// make sure this is a tty
+	if !isatty.IsTerminal(uintptr(fd)) {
+		return nil, fmt.Errorf("fd %d is not a tty", fd)
+	}
+	// get the terminal IO mode
+	originalMode, err := raw.TcGetAttr(uintptr(fd))
+	if err != nil {
+		return nil, err
+	}
+	// modify the original mode
+	newMode := *originalMode
+	newMode.Iflag &^= (syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON)
+	newMode.Oflag &^= syscall.OPOST
+	newMode.Lflag &^= (syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN)
+	newMode.Cflag &^= (syscall.CSIZE | syscall.PARENB)
+	newMode.Cflag |= syscall.CS8
+	newMode.Cc[syscall.VMIN] = 1
+	newMode.Cc[syscall.VTIME] = 0
+	err = raw.TcSetAttr(uintptr(fd), &newMode)
+	if err != nil {
+		return nil, err
+	}
+	return originalMode, nil
+}
+
+// Restore the terminal mode.
+func restoreMode(fd int, mode *termMode) error {
+	return raw.TcSetAttr(uintptr(fd), mode)
+}
+
+//-----------------------------------------------------------------------------
+// IO with retries for system calls interrupted by signals
+
+// Wait for one of the fds to be readable within the timeout period.
+// Return the last readable fd in the list, or -1 if nothing is readable.
+// timeout = nil : wait forever
+// poll() is used so there is no limit on the fd value.
+func selectRead(fds []int, timeout *syscall.Timeval) (int, error) {
+	pfds := make([]unix.PollFd, len(fds))
+	for i, x := range fds {
+		pfds[i] = unix.PollFd{Fd: int32(x), Events: unix.POLLIN}
+	}
+	ms := -1
+	if timeout != nil {
+		ms = int(timeout.Nano() / int64(time.Millisecond))
+	}
+	for {
+		n, err := unix.Poll(pfds, ms)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return -1, err
+		}
+		if n == 0 {
+			return -1, nil
+		}
+		// a hangup or error is readable, the read returns the error
+		for i := len(pfds) - 1; i >= 0; i-- {
+			if pfds[i].Revents&(unix.POLLIN|unix.POLLHUP|unix.POLLERR) != 0 {
+				return fds[i], nil
+			}
+		}
+		return -1, nil
+	}
+}
+
+// Read from the file descriptor.
+func readFd(fd int, buf []byte) (int, error) {
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		return n, err
+	}
+}
+
+// Write to the file descriptor.
+func writeFd(fd int, buf []byte) (int, error) {
+	for {
+		n, err := syscall.Write(fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		return n, err
+	}
+}
+
+// Create a pipe, p[0] is the read end and p[1] is the write end.
+func newPipe(p []int) error {
+	return syscall.Pipe(p)
+}
+
+// Close a file descriptor.
+func closeFd(fd int) error {
+	return syscall.Close(fd)
+}
+
+//-----------------------------------------------------------------------------
+
+// Return the size of the stdout terminal.
+func termSize() (cols, rows int, ok bool) {
+	var winsize [4]uint16
+	_, _, err := syscall.Syscall(syscall.SYS_IOCTL, uintptr(stdoutFd), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&winsize)))
+	if err != 0 {
+		return 0, 0, false
+	}
+	return int(winsize[1]), int(winsize[0]), true
+}
+
+// Send terminal resize signals to the channel.
+func notifyResize(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGWINCH)
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,182 @@
+//go:build !windows
+// +build !windows
+
+package cli
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func Test_Inject(t *testing.T) {
+	// use a pipe as the input file descriptor
+	p := make([]int, 2)
+	err := syscall.Pipe(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(p[0])
+	defer syscall.Close(p[1])
+
+	l := NewLineNoise()
+	err = l.Inject("aé")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := utf8{}
+	s := []rune{}
+	for {
+		r := l.getRune(&u, p[0], &timeoutZero)
+		if r == KeycodeNull && l.wouldBlock(p[0], &timeoutZero) {
+			break
+		}
+		if r != KeycodeNull {
+			s = append(s, r)
+		}
+	}
+	if string(s) != "aé" {
+		t.Errorf("FAIL expected (aé) != actual (%s)", string(s))
+	}
+}
+
+func Test_ReadErrors(t *testing.T) {
+	p := make([]int, 2)
+	err := syscall.Pipe(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(p[0])
+
+	// end of input is an error
+	syscall.Close(p[1])
+	u := utf8{}
+	r := u.getRune(p[0], nil)
+	if r != keycodeError || u.err != io.EOF {
+		t.Errorf("FAIL expected (%v) != actual (%v)", io.EOF, u.err)
+	}
+
+	// a shutdown stops reading
+	l := NewLineNoise()
+	l.Shutdown()
+	l.Shutdown()
+	u = utf8{}
+	r = l.getRune(&u, p[0], nil)
+	if r != keycodeError || l.ioErr != ErrShutdown {
+		t.Errorf("FAIL expected (%v) != actual (%v)", ErrShutdown, l.ioErr)
+	}
+}
+
+func Test_HighFd(t *testing.T) {
+	p := make([]int, 2)
+	err := syscall.Pipe(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(p[0])
+	defer syscall.Close(p[1])
+	// move the read end above FD_SETSIZE
+	const fd = 1500
+	err = unix.Dup2(p[0], fd)
+	if err != nil {
+		t.Skipf("can't dup fd %d: %s", fd, err)
+	}
+	defer syscall.Close(fd)
+
+	if !wouldBlock(fd, &timeoutZero) {
+		t.Errorf("FAIL empty fd %d is readable", fd)
+	}
+	syscall.Write(p[1], []byte("x"))
+	if wouldBlock(fd, &timeout20ms) {
+		t.Errorf("FAIL fd %d is not readable", fd)
+	}
+	u := utf8{}
+	r := u.getRune(fd, &timeout20ms)
+	if r != 'x' {
+		t.Errorf("FAIL expected (x) != actual (%c)", r)
+	}
+}
+
+func Test_ReadKey(t *testing.T) {
+	p := make([]int, 2)
+	err := syscall.Pipe(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(p[0])
+	defer syscall.Close(p[1])
+
+	l := NewLineNoise()
+	syscall.Write(p[1], []byte("é\x1b[Aq"))
+	tests := []KeyEvent{
+		{'é', ""},
+		{KeycodeESC, "[A"},
+		{'q', ""},
+	}
+	for i, v := range tests {
+		k, err := l.readKeyFd(p[0], time.Second)
+		if err != nil || k != v {
+			t.Errorf("%d: FAIL expected (%v) != actual (%v, %v)", i, v, k, err)
+		}
+	}
+	_, err = l.readKeyFd(p[0], 10*time.Millisecond)
+	if err != ErrTimeout {
+		t.Errorf("FAIL expected (%v) != actual (%v)", ErrTimeout, err)
+	}
+	// a stale wake up is drained, not spun on
+	l.injectInit()
+	syscall.Write(l.wakePipe[1], []byte{0})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		syscall.Write(p[1], []byte("x"))
+	}()
+	k, err := l.readKeyFd(p[0], 0)
+	if err != nil || k.Rune != 'x' || !wouldBlock(l.wakePipe[0], &timeoutZero) {
+		t.Errorf("FAIL wake pipe not drained (%v, %v)", k, err)
+	}
+}
+
+func Test_WatchResize(t *testing.T) {
+	l := NewLineNoise()
+	l.injectInit()
+	l.setEditing(true)
+	stop := l.watchResize()
+	syscall.Kill(os.Getpid(), syscall.SIGWINCH)
+	ok := false
+	for i := 0; i < 100 && !ok; i++ {
+		time.Sleep(10 * time.Millisecond)
+		l.asyncLock.Lock()
+		ok = l.asyncResize
+		l.asyncLock.Unlock()
+	}
+	stop()
+	if !ok {
+		t.Errorf("FAIL SIGWINCH resize not flagged")
+	}
+	if wouldBlock(l.wakePipe[0], &timeoutZero) {
+		t.Errorf("FAIL edit loop not woken")
+	}
+	l.setEditing(false)
+}
+
+func Test_Close(t *testing.T) {
+	c := NewCLI(&testUser{})
+	c.ln.injectInit()
+	fds := append(append([]int{}, c.ln.injectPipe...), c.ln.wakePipe...)
+	if len(fds) != 4 {
+		t.Fatalf("FAIL expected (4) != actual (%d) pipe fds", len(fds))
+	}
+	c.Close()
+	for i, fd := range fds {
+		if _, err := unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0); err != unix.EBADF {
+			t.Errorf("%d: FAIL fd %d not closed (%v)", i, fd, err)
+		}
+	}
+	if c.ln.Inject("x") == nil {
+		t.Errorf("FAIL inject after close")
+	}
+}
@@ -0,0 +1,343 @@
+//go:build windows
+// +build windows
+
+//-----------------------------------------------------------------------------
+/*
+
+Windows Terminal
+
+Terminal IO using the Windows console API. The console is set to virtual
+terminal mode (Windows 10 and later) so the line editor sees the same escape
+sequences as on a Unix terminal.
+
+There is no select() for console handles, so console input is read by a
+goroutine into an in-process pipe. The pipes for injected input and
+asynchronous output are also in-process, and selectRead waits on them all.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+)
+
+//-----------------------------------------------------------------------------
+
+// standard file descriptors
+var (
+	stdinFd  = 0
+	stdoutFd = 1
+	stderrFd = 2
+)
+
+// Return the handle for a standard file descriptor.
+func stdHandle(fd int) (windows.Handle, bool) {
+	var id uint32
+	switch fd {
+	case stdinFd:
+		id = windows.STD_INPUT_HANDLE
+	case stdoutFd:
+		id = windows.STD_OUTPUT_HANDLE
+	case stderrFd:
+		id = windows.STD_ERROR_HANDLE
+	default:
+		return 0, false
+	}
+	h, err := windows.GetStdHandle(id)
+	return h, err == nil && h != windows.InvalidHandle
+}
+
+// Is the file descriptor a console?
+func isTerminal(fd int) bool {
+	h, ok := stdHandle(fd)
+	if !ok {
+		return false
+	}
+	var mode uint32
+	return windows.GetConsoleMode(h, &mode) == nil
+}
+
+func init() {
+	// interpret escape sequences written to the console
+	if h, ok := stdHandle(stdoutFd); ok {
+		var mode uint32
+		if windows.GetConsoleMode(h, &mode) == nil {
+			windows.SetConsoleMode(h, mode|windows.ENABLE_PROCESSED_OUTPUT|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// control the terminal mode
+
+// termMode is a saved console input mode.
+type termMode struct {
+	mode uint32
+}
+
+// Set a console to raw mode.
+func setRawMode(fd int) (*termMode, error) {
+	// make sure this is a console
+	if !isTerminal(fd) {
+		return nil, fmt.Errorf("fd %d is not a console", fd)
+	}
+	h, _ := stdHandle(fd)
+	var original termMode
+	err := windows.GetConsoleMode(h, &original.mode)
+	if err != nil {
+		return nil, err
+	}
+	// no line editing, echo or ctrl-c handling, keys are sent as escape sequences
+	mode := original.mode &^ (windows.ENABLE_ECHO_INPUT | windows.ENABLE_LINE_INPUT | windows.ENABLE_PROCESSED_INPUT)
+	mode |= windows.ENABLE_VIRTUAL_TERMINAL_INPUT
+	err = windows.SetConsoleMode(h, mode)
+	if err != nil {
+		return nil, err
+	}
+	startConsole(h)
+	return &original, nil
+}
+
+// Restore the console mode.
+func restoreMode(fd int, mode *termMode) error {
+	h, ok := stdHandle(fd)
+	if !ok {
+		return syscall.EBADF
+	}
+	return windows.SetConsoleMode(h, mode.mode)
+}
+
+//-----------------------------------------------------------------------------
+// in-process pipes
+
+// memPipe is an in-process pipe.
+type memPipe struct {
+	buf    []byte // unread data
+	closed bool   // the write end is closed
+	err    error  // read error
+}
+
+// Is there data, an end of file or an error to read?
+func (p *memPipe) readable() bool {
+	return len(p.buf) != 0 || p.closed || p.err != nil
+}
+
+// pipeEnd is a file descriptor for one end of a pipe.
+type pipeEnd struct {
+	p     *memPipe
+	write bool
+}
+
+// pipe file descriptors
+var pipes = struct {
+	sync.Mutex
+	fds  map[int]pipeEnd
+	next int           // next file descriptor
+	wake chan struct{} // closed when a pipe becomes readable
+}{
+	fds:  make(map[int]pipeEnd),
+	next: 3,
+	wake: make(chan struct{}),
+}
+
+// Wake up the readers waiting on pipes. The pipes must be locked.
+func pipesWake() {
+	close(pipes.wake)
+	pipes.wake = make(chan struct{})
+}
+
+// Create a pipe, p[0] is the read end and p[1] is the write end.
+func newPipe(p []int) error {
+	pipes.Lock()
+	defer pipes.Unlock()
+	mp := &memPipe{}
+	p[0], p[1] = pipes.next, pipes.next+1
+	pipes.next += 2
+	pipes.fds[p[0]] = pipeEnd{p: mp}
+	pipes.fds[p[1]] = pipeEnd{p: mp, write: true}
+	return nil
+}
+
+// Close a file descriptor.
+func closeFd(fd int) error {
+	pipes.Lock()
+	defer pipes.Unlock()
+	e, ok := pipes.fds[fd]
+	if !ok {
+		return syscall.EBADF
+	}
+	if e.write {
+		e.p.closed = true
+		pipesWake()
+	}
+	delete(pipes.fds, fd)
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// console input
+
+var consoleOnce sync.Once
+
+// Start reading console input into the stdin pipe.
+func startConsole(h windows.Handle) {
+	consoleOnce.Do(func() {
+		p := &memPipe{}
+		pipes.Lock()
+		pipes.fds[stdinFd] = pipeEnd{p: p}
+		pipes.Unlock()
+		go readConsole(h, p)
+	})
+}
+
+// Read console input (utf16) and write it to a pipe (utf8).
+func readConsole(h windows.Handle, p *memPipe) {
+	buf := make([]uint16, 256)
+	var high uint16 // high surrogate waiting for the low surrogate
+	for {
+		var n uint32
+		err := windows.ReadConsole(h, &buf[0], uint32(len(buf)), &n, nil)
+		var b []byte
+		if err == nil {
+			u := buf[:n]
+			if high != 0 {
+				u = append([]uint16{high}, u...)
+				high = 0
+			}
+			if k := len(u); k != 0 && u[k-1] >= 0xd800 && u[k-1] < 0xdc00 {
+				high = u[k-1]
+				u = u[:k-1]
+			}
+			b = []byte(string(utf16.Decode(u)))
+		}
+		pipes.Lock()
+		p.buf = append(p.buf, b...)
+		p.err = err
+		pipesWake()
+		pipes.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// IO
+
+// Wait for one of the fds to be readable within the timeout period.
+// Return the last readable fd in the list, or -1 if nothing is readable.
+// timeout = nil : wait forever
+// A file descriptor that isn't a pipe is always readable.
+func selectRead(fds []int, timeout *syscall.Timeval) (int, error) {
+	var expired <-chan time.Time
+	if timeout != nil {
+		t := time.NewTimer(time.Duration(timeout.Nano()))
+		defer t.Stop()
+		expired = t.C
+	}
+	for {
+		pipes.Lock()
+		for i := len(fds) - 1; i >= 0; i-- {
+			e, ok := pipes.fds[fds[i]]
+			if !ok || (!e.write && e.p.readable()) {
+				pipes.Unlock()
+				return fds[i], nil
+			}
+		}
+		wake := pipes.wake
+		pipes.Unlock()
+		select {
+		case <-wake:
+		case <-expired:
+			return -1, nil
+		}
+	}
+}
+
+// Read from the file descriptor.
+func readFd(fd int, buf []byte) (int, error) {
+	pipes.Lock()
+	e, ok := pipes.fds[fd]
+	if !ok {
+		pipes.Unlock()
+		h, ok := stdHandle(fd)
+		if !ok {
+			return 0, syscall.EBADF
+		}
+		var n uint32
+		err := windows.ReadFile(h, buf, &n, nil)
+		return int(n), err
+	}
+	defer pipes.Unlock()
+	if e.write {
+		return 0, syscall.EBADF
+	}
+	for !e.p.readable() {
+		wake := pipes.wake
+		pipes.Unlock()
+		<-wake
+		pipes.Lock()
+	}
+	if len(e.p.buf) == 0 {
+		// end of file or error
+		return 0, e.p.err
+	}
+	n := copy(buf, e.p.buf)
+	e.p.buf = e.p.buf[n:]
+	return n, nil
+}
+
+// Write to the file descriptor.
+func writeFd(fd int, buf []byte) (int, error) {
+	pipes.Lock()
+	e, ok := pipes.fds[fd]
+	if !ok {
+		pipes.Unlock()
+		h, ok := stdHandle(fd)
+		if !ok {
+			return 0, syscall.EBADF
+		}
+		var n uint32
+		err := windows.WriteFile(h, buf, &n, nil)
+		return int(n), err
+	}
+	defer pipes.Unlock()
+	if !e.write || e.p.closed {
+		return 0, syscall.EBADF
+	}
+	e.p.buf = append(e.p.buf, buf...)
+	pipesWake()
+	return len(buf), nil
+}
+
+//-----------------------------------------------------------------------------
+
+// Return the size of the stdout console window.
+func termSize() (cols, rows int, ok bool) {
+	h, ok := stdHandle(stdoutFd)
+	if !ok {
+		return 0, 0, false
+	}
+	var info windows.ConsoleScreenBufferInfo
+	if windows.GetConsoleScreenBufferInfo(h, &info) != nil {
+		return 0, 0, false
+	}
+	w := info.Window
+	return int(w.Right-w.Left) + 1, int(w.Bottom-w.Top) + 1, true
+}
+
+// Send terminal resize signals to the channel.
+// The console has no resize signal, the size is read for each line.
+func notifyResize(ch chan<- os.Signal) {
+}
+
+//-----------------------------------------------------------------------------
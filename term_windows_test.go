@@ -0,0 +1,34 @@
+//go:build windows
+// +build windows
+
+package cli
+
+import (
+	"syscall"
+	"testing"
+)
+
+func Test_MemPipe(t *testing.T) {
+	p := make([]int, 2)
+	if err := newPipe(p); err != nil {
+		t.Fatal(err)
+	}
+	tv := syscall.Timeval{0, 1000}
+	if rfd, _ := selectRead([]int{p[0]}, &tv); rfd != -1 {
+		t.Errorf("FAIL empty pipe is readable")
+	}
+	writeFd(p[1], []byte("ab"))
+	if rfd, _ := selectRead([]int{p[0]}, &tv); rfd != p[0] {
+		t.Errorf("FAIL expected (%d) != actual (%d)", p[0], rfd)
+	}
+	buf := make([]byte, 8)
+	n, err := readFd(p[0], buf)
+	if string(buf[:n]) != "ab" || err != nil {
+		t.Errorf("FAIL expected (%q) != actual (%q, %v)", "ab", buf[:n], err)
+	}
+	closeFd(p[1])
+	if n, err := readFd(p[0], buf); n != 0 || err != nil {
+		t.Errorf("FAIL expected end of file, actual (%d, %v)", n, err)
+	}
+	closeFd(p[0])
+}
@@ -0,0 +1,244 @@
+//-----------------------------------------------------------------------------
+/*
+
+Terminal Interface
+
+Line editing on a terminal other than stdin/stdout. Eg. an embedded editor,
+a test harness or a remote session. The terminal is a byte stream with a raw
+mode and a size, the editor reads keys from it and writes the line to it.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+
+// Terminal is a byte stream terminal for line editing.
+type Terminal interface {
+	io.Reader
+	io.Writer
+	MakeRaw() (restore func() error, err error) // set raw mode, restore returns to the prior mode
+	Size() (cols, rows int)                     // terminal size, 0 if unknown
+}
+
+// terminal input wait for escape sequences
+const termWait = 20 * time.Millisecond
+
+// errWake is returned by a terminal read that has been woken up.
+var errWake = errors.New("wake")
+
+// termData is a buffer read from a terminal.
+type termData struct {
+	buf []byte
+	err error
+}
+
+// termSource is an input source for a terminal.
+// A goroutine reads from the terminal so reads can be timed out.
+type termSource struct {
+	ch       chan termData   // buffers read from the terminal
+	shutdown <-chan struct{} // closed to shut down line editing
+	stop     chan struct{}   // closed to stop the reader goroutine
+	wake     chan struct{}   // wakes up a blocked read
+	woken    bool            // a read has been woken up
+	ctx      context.Context // context for the line being read, nil for none
+	buf      []byte          // unread input
+	err      error           // read error
+}
+
+// Return an input source for a terminal.
+func newTermSource(r io.Reader, shutdown <-chan struct{}) *termSource {
+	s := &termSource{
+		ch:       make(chan termData),
+		shutdown: shutdown,
+		stop:     make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+	}
+	go func() {
+		for {
+			buf := make([]byte, 256)
+			n, err := r.Read(buf)
+			if n > 0 && !s.send(termData{buf: buf[:n]}) {
+				return
+			}
+			if err != nil {
+				s.send(termData{err: err})
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// Pass data to the reader. Returns false if the source has been stopped.
+func (s *termSource) send(d termData) bool {
+	select {
+	case s.ch <- d:
+		return true
+	case <-s.stop:
+		return false
+	}
+}
+
+// Wake up a blocked read. It's safe to call from other goroutines.
+func (s *termSource) wakeUp() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stop the reader goroutine. A blocked terminal read isn't interrupted,
+// the goroutine exits when the read returns.
+func (s *termSource) close() {
+	close(s.stop)
+}
+
+// Wait for input. A negative timeout waits forever.
+// Returns false if there is no input or error within the timeout.
+func (s *termSource) fill(timeout time.Duration) bool {
+	if len(s.buf) != 0 || s.err != nil || s.woken {
+		return true
+	}
+	var expired <-chan time.Time
+	if timeout >= 0 {
+		expired = time.After(timeout)
+	}
+	var cancel <-chan struct{}
+	if s.ctx != nil {
+		cancel = s.ctx.Done()
+	}
+	select {
+	case d := <-s.ch:
+		s.buf, s.err = d.buf, d.err
+	case <-s.shutdown:
+		s.err = ErrShutdown
+	case <-s.wake:
+		s.woken = true
+	case <-cancel:
+		// the read is cancelled, the error isn't kept for later reads
+	case <-expired:
+		return false
+	}
+	return true
+}
+
+func (s *termSource) ReadByte() (byte, error) {
+	s.fill(-1)
+	if len(s.buf) == 0 {
+		if s.woken {
+			s.woken = false
+			return 0, errWake
+		}
+		if s.err == nil && s.ctx != nil {
+			return 0, s.ctx.Err()
+		}
+		return 0, s.err
+	}
+	c := s.buf[0]
+	s.buf = s.buf[1:]
+	return c, nil
+}
+
+func (s *termSource) Pending() bool {
+	return s.fill(termWait)
+}
+
+//-----------------------------------------------------------------------------
+
+// SetTerminal sets the terminal used for line editing, key reads (Read,
+// ReadKey, Loop, LoopKeys and KeyInspector) and asynchronous output.
+// Use nil to edit on stdin/stdout.
+func (l *Linenoise) SetTerminal(t Terminal) {
+	if l.termInput != nil {
+		l.termInput.close()
+	}
+	var s *termSource
+	if t != nil {
+		s = newTermSource(t, l.shutdown)
+	}
+	l.asyncLock.Lock()
+	l.term = t
+	l.termInput = s
+	l.asyncLock.Unlock()
+}
+
+// Read a line from the terminal in raw mode.
+func (l *Linenoise) readTerm(prompt, init string) (string, error) {
+	restore, err := l.term.MakeRaw()
+	if err != nil {
+		return "", err
+	}
+	if restore != nil {
+		defer restore()
+	}
+	if l.bracketedPaste {
+		// pasted text is marked so it can be inserted literally
+		io.WriteString(l.term, pasteOn)
+		defer io.WriteString(l.term, pasteOff)
+	}
+	l.input = l.termInput
+	l.output = l.term
+	l.termInput.ctx = l.ctx
+	defer func() {
+		l.input = nil
+		l.output = nil
+		l.termInput.ctx = nil
+	}()
+	l.asyncLock.Lock()
+	l.editTerm = true
+	l.asyncLock.Unlock()
+	l.setEditing(true)
+	s, err := l.edit(-1, -1, prompt, init)
+	if err != ErrInterrupted {
+		io.WriteString(l.term, "\r\n")
+	}
+	l.setEditing(false)
+	return s, err
+}
+
+// Set raw mode for reading keys from the terminal, or stdin if there is no
+// terminal. Returns a function to restore the prior mode.
+func (l *Linenoise) keyMode() (func(), error) {
+	if l.term == nil {
+		if err := l.enableRawMode(stdinFd); err != nil {
+			return nil, err
+		}
+		return func() { l.disableRawMode(stdinFd) }, nil
+	}
+	restore, err := l.term.MakeRaw()
+	if err != nil {
+		return nil, err
+	}
+	input, output := l.input, l.output
+	l.input = l.termInput
+	l.output = l.term
+	l.termInput.ctx = l.ctx
+	return func() {
+		l.input = input
+		l.output = output
+		l.termInput.ctx = nil
+		if restore != nil {
+			restore()
+		}
+	}, nil
+}
+
+// Write a string to the terminal, or stdout if there is no terminal.
+func (l *Linenoise) putTerm(s string) {
+	if l.term != nil {
+		io.WriteString(l.term, s)
+		return
+	}
+	puts(stdoutFd, s)
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,280 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTerm is a terminal for testing.
+type testTerm struct {
+	io.Reader
+	out bytes.Buffer
+	raw bool
+}
+
+func (t *testTerm) Write(p []byte) (int, error) {
+	return t.out.Write(p)
+}
+
+func (t *testTerm) MakeRaw() (func() error, error) {
+	t.raw = true
+	return func() error {
+		t.raw = false
+		return nil
+	}, nil
+}
+
+func (t *testTerm) Size() (int, int) {
+	return 40, 12
+}
+
+func Test_Terminal(t *testing.T) {
+	term := &testTerm{Reader: strings.NewReader("abd\x1b[Dc\rxyz")}
+	l := NewLineNoise()
+	l.SetTerminal(term)
+	if l.Columns() != 40 || l.Rows() != 12 {
+		t.Errorf("FAIL expected (40, 12) != actual (%d, %d)", l.Columns(), l.Rows())
+	}
+	line, err := l.Read("> ", "")
+	if line != "abcd" || err != nil {
+		t.Errorf("FAIL expected (%q) != actual (%q, %v)", "abcd", line, err)
+	}
+	if term.raw {
+		t.Errorf("FAIL raw mode not restored")
+	}
+	if !strings.Contains(term.out.String(), "> abcd") {
+		t.Errorf("FAIL line not written to the terminal: %q", term.out.String())
+	}
+	// the input ends before the line is complete
+	line, err = l.Read("> ", "")
+	if err != io.EOF {
+		t.Errorf("FAIL expected (%v) != actual (%q, %v)", io.EOF, line, err)
+	}
+}
+
+func Test_TerminalShutdown(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	l := NewLineNoise()
+	l.SetTerminal(&testTerm{Reader: r})
+	go func() {
+		w.Write([]byte("abc"))
+		l.Shutdown()
+	}()
+	_, err := l.Read("> ", "")
+	if err != ErrShutdown {
+		t.Errorf("FAIL expected (%v) != actual (%v)", ErrShutdown, err)
+	}
+}
+
+func Test_ReadContext(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	l := NewLineNoise()
+	l.SetTerminal(&testTerm{Reader: r})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		w.Write([]byte("abc"))
+		cancel()
+	}()
+	_, err := l.ReadContext(ctx, "> ", "")
+	if err != context.Canceled {
+		t.Errorf("FAIL expected (%v) != actual (%v)", context.Canceled, err)
+	}
+	// the terminal is still usable
+	go w.Write([]byte("xyz\r"))
+	line, err := l.ReadContext(context.Background(), "> ", "")
+	if !strings.HasSuffix(line, "xyz") || err != nil {
+		t.Errorf("FAIL expected (%q) != actual (%q, %v)", "xyz", line, err)
+	}
+	// a cancelled context doesn't read
+	if _, err := l.ReadContext(ctx, "> ", ""); err != context.Canceled {
+		t.Errorf("FAIL expected (%v) != actual (%v)", context.Canceled, err)
+	}
+}
+
+func Test_RunContext(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	c := NewCLI(&testUser{})
+	c.SetRoot(testMenu)
+	c.ln.SetTerminal(&testTerm{Reader: r})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	for c.Running() {
+		c.RunContext(ctx)
+	}
+	if c.Err() != context.DeadlineExceeded {
+		t.Errorf("FAIL expected (%v) != actual (%v)", context.DeadlineExceeded, c.Err())
+	}
+}
+
+func Test_TerminalKeys(t *testing.T) {
+	term := &testTerm{Reader: strings.NewReader("x\x1b[Aq")}
+	l := NewLineNoise()
+	l.SetTerminal(term)
+	tests := []KeyEvent{
+		{'x', ""},
+		{KeycodeESC, "[A"},
+	}
+	for i, v := range tests {
+		k, err := l.ReadKey(time.Second)
+		if err != nil || k != v {
+			t.Errorf("%d: FAIL expected (%v) != actual (%v, %v)", i, v, k, err)
+		}
+	}
+	if term.raw {
+		t.Errorf("FAIL raw mode not restored")
+	}
+	if l.readKey(context.Background(), "more") != 'q' || !strings.HasPrefix(term.out.String(), "more") {
+		t.Errorf("FAIL prompt not written to the terminal: %q", term.out.String())
+	}
+	if _, err := l.ReadKey(0); err != io.EOF {
+		t.Errorf("FAIL expected (%v) != actual (%v)", io.EOF, err)
+	}
+}
+
+func Test_TerminalStop(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	n := runtime.NumGoroutine()
+	l := NewLineNoise()
+	l.SetTerminal(&testTerm{Reader: r})
+	l.SetTerminal(nil)
+	// the reader goroutine of the replaced terminal exits after its read
+	w.Write([]byte("abc"))
+	for i := 0; runtime.NumGoroutine() > n; i++ {
+		if i == 100 {
+			t.Fatalf("FAIL reader goroutine not stopped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_Interrupt(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	l := NewLineNoise()
+	l.SetTerminal(&testTerm{Reader: r})
+	go func() {
+		w.Write([]byte("abc\x1b[D"))
+		time.Sleep(50 * time.Millisecond)
+		l.Interrupt()
+	}()
+	line, err := l.Read("> ", "")
+	if line != "abc" || err != ErrInterrupted {
+		t.Errorf("FAIL expected (%q, %v) != actual (%q, %v)", "abc", ErrInterrupted, line, err)
+	}
+	// the line is edited again with the cursor restored
+	go w.Write([]byte("X\r"))
+	line, err = l.Read("> ", line)
+	if line != "abXc" || err != nil {
+		t.Errorf("FAIL expected (%q) != actual (%q, %v)", "abXc", line, err)
+	}
+	// an interrupt while no line is being edited stops the next edit
+	l.Interrupt()
+	line, err = l.Read("> ", "xyz")
+	if line != "xyz" || err != ErrInterrupted {
+		t.Errorf("FAIL expected (%q, %v) != actual (%q, %v)", "xyz", ErrInterrupted, line, err)
+	}
+}
+
+// Wait until a line is being edited.
+func waitEditing(t *testing.T, l *Linenoise) {
+	for i := 0; ; i++ {
+		l.asyncLock.Lock()
+		editing := l.editing
+		l.asyncLock.Unlock()
+		if editing {
+			return
+		}
+		if i == 100 {
+			t.Fatalf("FAIL line not being edited")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_TerminalAsync(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	term := &testTerm{Reader: r}
+	l := NewLineNoise()
+	l.SetTerminal(term)
+	done := make(chan struct{})
+	var line string
+	var err error
+	go func() {
+		line, err = l.Read("> ", "")
+		close(done)
+	}()
+	waitEditing(t, l)
+	l.PrintAsync("hello\n")
+	l.SetPromptAsync("$ ")
+	// wait for the edit loop to show the output
+	for i := 0; ; i++ {
+		l.asyncLock.Lock()
+		flushed := l.asyncBuf == nil && l.asyncPrompt == nil
+		l.asyncLock.Unlock()
+		if flushed {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("FAIL async output not flushed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	w.Write([]byte("x\r"))
+	<-done
+	if line != "x" || err != nil {
+		t.Errorf("FAIL expected (%q) != actual (%q, %v)", "x", line, err)
+	}
+	out := term.out.String()
+	if !strings.Contains(out, "hello\r\n") || !strings.Contains(out, "$ x") {
+		t.Errorf("FAIL async output not written to the terminal: %q", out)
+	}
+	// output while no line is edited goes to the terminal
+	term.out.Reset()
+	l.PrintAsync("bye\n")
+	if term.out.String() != "bye\n" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "bye\n", term.out.String())
+	}
+}
+
+func Test_TerminalResize(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	term := &testTerm{Reader: r}
+	l := NewLineNoise()
+	l.SetTerminal(term)
+	done := make(chan struct{})
+	go func() {
+		l.Read("> ", "abc")
+		close(done)
+	}()
+	waitEditing(t, l)
+	// Eg. a telnet NAWS message redraws the line
+	l.SetColumns(20)
+	for i := 0; ; i++ {
+		l.asyncLock.Lock()
+		resized := !l.asyncResize
+		l.asyncLock.Unlock()
+		if resized {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("FAIL resize not handled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	w.Write([]byte("\r"))
+	<-done
+	if n := strings.Count(term.out.String(), "> abc"); n != 2 {
+		t.Errorf("FAIL expected (2) != actual (%d) line draws: %q", n, term.out.String())
+	}
+}
@@ -0,0 +1,158 @@
+//-----------------------------------------------------------------------------
+/*
+
+Command Timeouts
+
+Leaf commands can be given an execution timeout, for the whole CLI or per
+menu item. The leaf context (see Context) is cancelled at the timeout and
+the session returns to the prompt, so a hung backend call can't freeze the
+session forever.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+
+// ErrCommandTimeout is the result of a command that ran longer than its timeout.
+var ErrCommandTimeout = errors.New("command timed out")
+
+// Timeout is the execution timeout of a leaf command. A Timeout value may be
+// added after the leaf func of a menu item to override the CLI command
+// timeout, a Timeout <= 0 disables it for the command.
+type Timeout time.Duration
+
+// time a leaf has to return after its context is cancelled
+var timeoutGrace = 500 * time.Millisecond
+
+// SetCommandTimeout sets the execution timeout for leaf commands, 0 for none.
+func (c *CLI) SetCommandTimeout(d time.Duration) {
+	c.cmdTimeout = d
+}
+
+// Context returns the context of the running leaf command. It's cancelled
+// when the command times out. Long running leaf functions should pass it to
+// backend calls.
+func (c *CLI) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// Return the execution timeout of a leaf menu item.
+func (c *CLI) itemTimeout(item MenuItem) time.Duration {
+	for _, x := range item[2:] {
+		if t, ok := x.(Timeout); ok {
+			return time.Duration(t)
+		}
+	}
+	return c.cmdTimeout
+}
+
+// leafUser is the user of a leaf session.
+// Output is discarded once the leaf has been abandoned.
+type leafUser struct {
+	sync.Mutex
+	user      USER
+	abandoned bool
+}
+
+func (u *leafUser) Put(s string) {
+	u.Lock()
+	defer u.Unlock()
+	if !u.abandoned {
+		u.user.Put(s)
+	}
+}
+
+// Stop the output of an abandoned leaf.
+func (u *leafUser) abandon() {
+	u.Lock()
+	defer u.Unlock()
+	u.abandoned = true
+}
+
+// Run a leaf function with coalesced output and the command timeout of its
+// menu item. In dry-run mode a state changing leaf must call Apply().
+func (c *CLI) runLeaf(item MenuItem, args []string) {
+	c.applied = false
+	c.runTimeout(item[1].(Leaf).F, args, c.itemTimeout(item))
+	if c.dryRun && itemFlags(item)&StateChanging != 0 && !c.applied && c.result == nil {
+		c.Put(c.colorString(ErrNoDryRun.Error(), theme.Error, true) + "\n")
+		c.result = ErrNoDryRun
+	}
+}
+
+// Run a leaf function with an execution timeout. The leaf runs on its own
+// goroutine in a copy of the session with its own context. If it returns in
+// time the session is updated from the copy. A leaf that doesn't return after
+// its context is cancelled is abandoned, it keeps running in the background
+// with its output discarded and interactive reads failing.
+func (c *CLI) runTimeout(leaf func(*CLI, []string), args []string, timeout time.Duration) {
+	if timeout <= 0 {
+		c.callLeaf(leaf, args)
+		return
+	}
+	parent := c.Context()
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+	user := &leafUser{user: c.User}
+	lc := *c
+	lc.origin = c.owner()
+	lc.User = user
+	lc.out = nil
+	lc.ctx = ctx
+	// the recovered panic of the leaf, nil for none
+	done := make(chan interface{}, 1)
+	go func() {
+		defer func() { done <- recover() }()
+		lc.callLeaf(leaf, args)
+	}()
+	select {
+	case p := <-done:
+		c.leafReturned(&lc, p)
+		return
+	case <-ctx.Done():
+	}
+	// give the leaf a chance to return
+	t := time.NewTimer(timeoutGrace)
+	defer t.Stop()
+	select {
+	case p := <-done:
+		c.leafReturned(&lc, p)
+	case <-t.C:
+		user.abandon()
+		go func() {
+			if p := <-done; p != nil {
+				log.Printf("abandoned leaf panic: %v\n", p)
+			}
+		}()
+	}
+	if parent.Err() == nil {
+		c.Put(c.colorString(ErrCommandTimeout.Error(), theme.Error, true) + "\n")
+		c.result = ErrCommandTimeout
+	}
+}
+
+// Update the session from the copy used by a leaf that has returned.
+// A panic in the leaf is raised again.
+func (c *CLI) leafReturned(lc *CLI, p interface{}) {
+	if p != nil {
+		panic(p)
+	}
+	user, out, ctx, origin := c.User, c.out, c.ctx, c.origin
+	*c = *lc
+	c.User, c.out, c.ctx, c.origin = user, out, ctx, origin
+}
+
+//-----------------------------------------------------------------------------
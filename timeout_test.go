@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_CommandTimeout(t *testing.T) {
+	grace := timeoutGrace
+	timeoutGrace = 10 * time.Millisecond
+	defer func() { timeoutGrace = grace }()
+	hung := make(chan struct{})
+	defer close(hung)
+	wait := Leaf{"wait for the context", func(c *CLI, args []string) { <-c.Context().Done() }}
+	sleep := Leaf{"sleep", func(c *CLI, args []string) { time.Sleep(30 * time.Millisecond) }}
+	hang := Leaf{"ignore the context", func(c *CLI, args []string) { <-hung }}
+	tests := []struct {
+		line    string
+		timeout bool
+	}{
+		{"wait", true},
+		{"sleep", false},
+		{"hang", true},
+		{"fast", false},
+	}
+	for i, v := range tests {
+		user := &testUser{}
+		c := NewCLI(user, WithCommandTimeout(10*time.Millisecond))
+		c.SetRoot(Menu{
+			{"wait", wait},
+			{"sleep", sleep, Timeout(0)},
+			{"hang", hang},
+			{"fast", testLeaf},
+		})
+		c.parseCmdline(v.line)
+		timeout := c.result == ErrCommandTimeout
+		msg := strings.Contains(user.out.String(), ErrCommandTimeout.Error())
+		if timeout != v.timeout || msg != v.timeout {
+			t.Errorf("%d: FAIL expected (%v) != actual (%v, %q)", i, v.timeout, c.result, user.out.String())
+		}
+		if c.Context().Err() != nil {
+			t.Errorf("%d: FAIL context not restored", i)
+		}
+	}
+}
+
+func Test_TimeoutSession(t *testing.T) {
+	grace := timeoutGrace
+	timeoutGrace = 10 * time.Millisecond
+	defer func() { timeoutGrace = grace }()
+	abandoned := make(chan struct{})
+	finished := make(chan error, 1)
+	user := &testUser{}
+	c := NewCLI(user, WithCommandTimeout(10*time.Millisecond))
+	c.SetRoot(Menu{
+		{"edit", Leaf{"set the next line", func(c *CLI, args []string) { c.SetLine("next") }}},
+		{"hang", Leaf{"ignore the context", func(c *CLI, args []string) {
+			ctx := c.Context()
+			<-abandoned
+			// the abandoned leaf keeps its own context and can't use the terminal
+			c.Put("late")
+			_, err := c.ReadKey(0)
+			if ctx.Err() == nil {
+				err = nil
+			}
+			finished <- err
+		}}},
+		{"panic", Leaf{"panic", func(c *CLI, args []string) { panic("oops") }}},
+	})
+	// changes made by a leaf that returns in time are kept
+	if s := c.parseCmdline("edit"); s != "next" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "next", s)
+	}
+	c.parseCmdline("hang")
+	close(abandoned)
+	if err := <-finished; err != context.DeadlineExceeded {
+		t.Errorf("FAIL expected (%v) != actual (%v)", context.DeadlineExceeded, err)
+	}
+	if strings.Contains(user.out.String(), "late") {
+		t.Errorf("FAIL output from an abandoned leaf %q", user.out.String())
+	}
+	// a panic is raised on the goroutine running the command
+	func() {
+		defer func() {
+			if p := recover(); p != "oops" {
+				t.Errorf("FAIL expected (%v) != actual (%v)", "oops", p)
+			}
+		}()
+		c.parseCmdline("panic")
+	}()
+}
@@ -0,0 +1,121 @@
+//-----------------------------------------------------------------------------
+/*
+
+Main Loop Work
+
+Work from other goroutines (Eg. scheduled commands, events, HTTP requests)
+is run on the goroutine running the CLI so it doesn't race with the command
+line. A command line being edited is interrupted for the work and then edited
+again. If the CLI isn't running the work is run by the goroutine submitting it,
+serialized with other work.
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import "sync"
+
+//-----------------------------------------------------------------------------
+
+// workQueue is work waiting for the goroutine running the cli.
+type workQueue struct {
+	sync.Mutex
+	fns     []func()   // queued work
+	looping bool       // the main loop is running and does the work
+	running bool       // a goroutine outside the main loop is doing the work
+	exec    sync.Mutex // held by the goroutine doing the work
+}
+
+// Run a function on the goroutine running the cli.
+// Returns a channel that is closed when the function has been run.
+func (c *CLI) post(fn func()) <-chan struct{} {
+	done := make(chan struct{})
+	q := c.work
+	q.Lock()
+	q.fns = append(q.fns, func() {
+		defer close(done)
+		fn()
+	})
+	if q.looping {
+		q.Unlock()
+		// stop the line being edited
+		c.ln.Interrupt()
+		return done
+	}
+	if q.running {
+		// the work is being done
+		q.Unlock()
+		return done
+	}
+	q.running = true
+	q.Unlock()
+	// no main loop, do the work now
+	q.exec.Lock()
+	defer q.exec.Unlock()
+	for {
+		q.Lock()
+		if len(q.fns) == 0 {
+			q.running = false
+			q.Unlock()
+			return done
+		}
+		fn := q.fns[0]
+		q.fns = q.fns[1:]
+		q.Unlock()
+		fn()
+	}
+}
+
+// Return the cli that owns the session state. A copy of the session (Eg. for
+// a remote command or a leaf with a timeout) is owned by the original cli.
+func (c *CLI) owner() *CLI {
+	if c.origin != nil {
+		return c.origin
+	}
+	return c
+}
+
+// Start doing the work in the main loop.
+// Returns false if an enclosing main loop (Eg. of a parent shell) does the work.
+func (c *CLI) workStart() bool {
+	q := c.work
+	q.Lock()
+	nested := q.looping
+	q.Unlock()
+	if nested {
+		return false
+	}
+	q.exec.Lock()
+	q.Lock()
+	q.looping = true
+	q.Unlock()
+	return true
+}
+
+// Stop doing the work in the main loop.
+func (c *CLI) workStop() {
+	q := c.work
+	q.Lock()
+	q.looping = false
+	q.Unlock()
+	q.exec.Unlock()
+}
+
+// Do the queued work in the main loop.
+func (c *CLI) doWork() {
+	q := c.work
+	for {
+		q.Lock()
+		if len(q.fns) == 0 {
+			q.Unlock()
+			return
+		}
+		fn := q.fns[0]
+		q.fns = q.fns[1:]
+		q.Unlock()
+		fn()
+	}
+}
+
+//-----------------------------------------------------------------------------
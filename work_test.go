@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Post(t *testing.T) {
+	c := NewCLI(&testUser{})
+	var order []string
+	// without a main loop the work is done now
+	done := c.post(func() {
+		order = append(order, "a")
+		// nested work is done after the current work
+		c.post(func() { order = append(order, "c") })
+		order = append(order, "b")
+	})
+	<-done
+	if s := strings.Join(order, ""); s != "abc" {
+		t.Errorf("FAIL expected (%q) != actual (%q)", "abc", s)
+	}
+}